@@ -0,0 +1,261 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBaseURL is the base URL of the GitHub API.
+const DefaultBaseURL = "https://api.github.com"
+
+// A Client is a client of the GitHub API.  Unlike SearchIssues, it
+// fetches every page of a result, waits when it exceeds its rate
+// limit, and makes conditional requests for results it has seen
+// before, which do not count against the rate limit.
+//
+// Its zero value is ready to use.  It is safe for concurrent use.
+type Client struct {
+	// BaseURL is the base URL of the API.  Empty means DefaultBaseURL.
+	BaseURL string
+
+	// Token, if non-empty, is an access token sent with each request.
+	Token string
+
+	// HTTPClient makes the requests.  Nil means http.DefaultClient.
+	HTTPClient *http.Client
+
+	// MaxWait is the longest the client will wait for its rate
+	// limit to be reset before giving up.  Zero means an hour.
+	MaxWait time.Duration
+
+	mu    sync.Mutex        // guards the following
+	cache map[string]cached // by URL
+	rate  Rate
+}
+
+// A cached is a response to a GET request.
+type cached struct {
+	etag string
+	body []byte
+	next string // URL of the next page
+}
+
+// A Rate is the state of a client's rate limit.
+type Rate struct {
+	Limit     int       // requests allowed per period
+	Remaining int       // requests remaining in the current period
+	Reset     time.Time // when the current period ends
+}
+
+// Rate returns the rate limit reported by the latest response.
+func (c *Client) Rate() Rate {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rate
+}
+
+// An Error is an error response from the API.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("github: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// A RateLimitError reports that the rate limit was exceeded and
+// would not be reset within MaxWait.
+type RateLimitError struct {
+	Reset time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("github: rate limit exceeded until %s", e.Reset.Format(time.RFC3339))
+}
+
+// SearchIssues queries the GitHub issue tracker, and returns every
+// page of the results.  GitHub returns at most 1000 results, so there
+// may be fewer Items than TotalCount.
+func (c *Client) SearchIssues(ctx context.Context, terms []string) (*IssuesSearchResult, error) {
+	q := url.QueryEscape(strings.Join(terms, " "))
+	next := "/search/issues?per_page=100&q=" + q
+	var result IssuesSearchResult
+	for next != "" {
+		var page IssuesSearchResult
+		var err error
+		if next, err = c.do(ctx, "GET", next, nil, &page); err != nil {
+			return nil, err
+		}
+		result.TotalCount = page.TotalCount
+		result.Items = append(result.Items, page.Items...)
+	}
+	return &result, nil
+}
+
+// maxAttempts is the number of times a request is made while rate
+// limited before giving up.
+const maxAttempts = 3
+
+// do sends a request to the API, with the JSON encoding of in, if
+// non-nil, as its body, and decodes the response into out, if non-nil.
+// It returns the URL of the next page of results, if any.  The URL
+// may be absolute or relative to BaseURL.
+func (c *Client) do(ctx context.Context, method, rawurl string, in, out interface{}) (next string, err error) {
+	if !strings.HasPrefix(rawurl, "http:") && !strings.HasPrefix(rawurl, "https:") {
+		base := c.BaseURL
+		if base == "" {
+			base = DefaultBaseURL
+		}
+		rawurl = strings.TrimSuffix(base, "/") + rawurl
+	}
+	var body []byte
+	if in != nil {
+		if body, err = json.Marshal(in); err != nil {
+			return "", err
+		}
+	}
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, rawurl, bytes.NewReader(body))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Accept", "application/vnd.github.v3+json")
+		if c.Token != "" {
+			req.Header.Set("Authorization", "token "+c.Token)
+		}
+		if in != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		c.mu.Lock()
+		prev, ok := c.cache[rawurl]
+		c.mu.Unlock()
+		if ok && method == "GET" {
+			req.Header.Set("If-None-Match", prev.etag)
+		}
+
+		client := c.HTTPClient
+		if client == nil {
+			client = http.DefaultClient
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		data, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return "", err
+		}
+		c.updateRate(resp.Header)
+
+		if reset, limited := rateLimited(resp); limited {
+			wait := time.Until(reset)
+			maxWait := c.MaxWait
+			if maxWait == 0 {
+				maxWait = time.Hour
+			}
+			if attempt == maxAttempts || wait > maxWait {
+				return "", &RateLimitError{reset}
+			}
+			select {
+			case <-time.After(wait):
+				continue
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusNotModified && ok:
+			data, next = prev.body, prev.next
+		case resp.StatusCode >= 200 && resp.StatusCode < 300:
+			next = nextLink(resp.Header.Get("Link"))
+			if etag := resp.Header.Get("ETag"); etag != "" && method == "GET" {
+				c.mu.Lock()
+				if c.cache == nil {
+					c.cache = make(map[string]cached)
+				}
+				c.cache[rawurl] = cached{etag, data, next}
+				c.mu.Unlock()
+			}
+		default:
+			e := &Error{StatusCode: resp.StatusCode}
+			var msg struct{ Message string }
+			if json.Unmarshal(data, &msg) == nil && msg.Message != "" {
+				e.Message = msg.Message
+			} else {
+				e.Message = strings.TrimSpace(string(data))
+			}
+			return "", e
+		}
+		if out != nil && len(data) > 0 {
+			if err := json.Unmarshal(data, out); err != nil {
+				return "", fmt.Errorf("decoding %s: %v", rawurl, err)
+			}
+		}
+		return next, nil
+	}
+}
+
+// updateRate records the rate limit reported by a response.
+func (c *Client) updateRate(h http.Header) {
+	limit, err1 := strconv.Atoi(h.Get("X-RateLimit-Limit"))
+	remaining, err2 := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	reset, err3 := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return
+	}
+	c.mu.Lock()
+	c.rate = Rate{limit, remaining, time.Unix(reset, 0)}
+	c.mu.Unlock()
+}
+
+// rateLimited reports whether resp reports that the rate limit was
+// exceeded, and if so, when it is worth trying again.
+func rateLimited(resp *http.Response) (time.Time, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return time.Time{}, false
+	}
+	// A secondary rate limit says how long to wait.
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return time.Now().Add(time.Duration(secs) * time.Second), true
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return time.Time{}, false // forbidden for some other reason
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return time.Now().Add(time.Minute), true
+	}
+	return time.Unix(reset, 0), true
+}
+
+// nextLink returns the URL of the link with rel="next" in the value
+// of a Link header, such as
+//
+//	<https://api.github.com/...&page=2>; rel="next", <...>; rel="last"
+func nextLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		parts := strings.Split(link, ";")
+		target := strings.TrimSpace(parts[0])
+		if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+			continue
+		}
+		for _, param := range parts[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return target[1 : len(target)-1]
+			}
+		}
+	}
+	return ""
+}
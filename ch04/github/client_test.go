@@ -0,0 +1,127 @@
+package github_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"gopl.io/ch04/github"
+	"gopl.io/ch04/github/githubtest"
+)
+
+// newServer returns a fake server holding n issues in repo a/b.
+func newServer(t *testing.T, n int) *githubtest.Server {
+	s := githubtest.NewServer()
+	t.Cleanup(s.Close)
+	for i := 0; i < n; i++ {
+		s.AddIssue("a/b", &github.Issue{
+			Title: fmt.Sprintf("issue %d", i+1),
+			User:  &github.User{Login: "gopher"},
+		})
+	}
+	s.AddIssue("c/d", &github.Issue{Title: "elsewhere"})
+	return s
+}
+
+func TestPagination(t *testing.T) {
+	s := newServer(t, 250)
+	c := s.Client()
+	result, err := c.SearchIssues(context.Background(), []string{"repo:a/b", "issue"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TotalCount != 250 || len(result.Items) != 250 {
+		t.Fatalf("got %d of %d issues, want 250", len(result.Items), result.TotalCount)
+	}
+	seen := make(map[int]bool)
+	for _, issue := range result.Items {
+		seen[issue.Number] = true
+	}
+	if len(seen) != 250 {
+		t.Errorf("got %d distinct issues, want 250", len(seen))
+	}
+	if n := s.Requests(); n != 3 {
+		t.Errorf("search took %d requests, want 3", n)
+	}
+}
+
+func TestETag(t *testing.T) {
+	s := newServer(t, 150)
+	c := s.Client()
+	ctx := context.Background()
+	terms := []string{"repo:a/b"}
+	first, err := c.SearchIssues(ctx, terms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := s.Requests()
+
+	// Unchanged results are not fetched again.
+	second, err := c.SearchIssues(ctx, terms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(second.Items) != len(first.Items) || s.Requests() != n {
+		t.Errorf("repeated search: %d issues and %d more requests, want %d and 0",
+			len(second.Items), s.Requests()-n, len(first.Items))
+	}
+
+	// Changed results are.
+	s.AddIssue("a/b", &github.Issue{Title: "new"})
+	third, err := c.SearchIssues(ctx, terms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(third.Items) != 151 {
+		t.Errorf("after a change, search found %d issues, want 151", len(third.Items))
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	s := newServer(t, 250)
+	s.SetRateLimit(2, time.Second)
+
+	// The client waits for the limit to be reset.
+	c := s.Client()
+	start := time.Now()
+	result, err := c.SearchIssues(context.Background(), []string{"repo:a/b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Items) != 250 {
+		t.Errorf("got %d issues, want 250", len(result.Items))
+	}
+	if time.Since(start) < 100*time.Millisecond {
+		t.Errorf("client did not wait for the rate limit")
+	}
+	if rate := c.Rate(); rate.Limit != 2 {
+		t.Errorf("Rate() = %+v, want limit 2", rate)
+	}
+
+	// Unless it would wait too long.
+	c = s.Client()
+	c.MaxWait = time.Millisecond
+	s.SetRateLimit(1, time.Hour)
+	_, err = c.SearchIssues(context.Background(), []string{"repo:a/b"})
+	var rlerr *github.RateLimitError
+	if !errors.As(err, &rlerr) {
+		t.Errorf("got error %v, want a RateLimitError", err)
+	}
+}
+
+func TestToken(t *testing.T) {
+	s := newServer(t, 1)
+	s.Token = "secret"
+	c := s.Client()
+	if _, err := c.SearchIssues(context.Background(), nil); err != nil {
+		t.Errorf("with token: %v", err)
+	}
+	c.Token = "wrong"
+	_, err := c.SearchIssues(context.Background(), nil)
+	var e *github.Error
+	if !errors.As(err, &e) || e.StatusCode != 401 || e.Message != "Bad credentials" {
+		t.Errorf("with wrong token: got error %v, want 401 Bad credentials", err)
+	}
+}
@@ -25,6 +25,14 @@ type Issue struct {
 	User      *User
 	CreatedAt time.Time `json:"created_at"`
 	Body      string    // in Markdown format
+	Labels    []*Label
+	Assignees []*User
+	Milestone *Milestone
+	Comments  int        // number of comments
+	UpdatedAt time.Time  `json:"updated_at"`
+	ClosedAt  *time.Time `json:"closed_at"` // nil if open
+
+	PullRequest *PullRequestLinks `json:"pull_request,omitempty"` // nil unless a pull request
 }
 
 type User struct {
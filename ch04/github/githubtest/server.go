@@ -0,0 +1,513 @@
+// Package githubtest provides a fake GitHub API server, for testing
+// clients of gopl.io/ch04/github without a network connection.
+//
+// The server holds issues in memory, and implements enough of the
+// API, in the manner of the real one, to exercise pagination, rate
+// limits and conditional requests.
+package githubtest
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopl.io/ch04/github"
+)
+
+// A Server is a fake GitHub API server.
+type Server struct {
+	*httptest.Server
+
+	// Token, if non-empty, is the access token that every request
+	// must present.
+	Token string
+
+	// Login is the login of the user making requests, who is the
+	// author of any issues and comments they create.
+	Login string
+
+	mu         sync.Mutex
+	repos      map[string]*repo // by "owner/name"
+	requests   int              // requests served, except 304s
+	rateLimit  int              // requests per window, or 0
+	rateWindow time.Duration
+	remaining  int
+	reset      time.Time
+}
+
+// NewServer starts and returns a new server, which the caller should
+// close when done.
+func NewServer() *Server {
+	s := &Server{Login: "gopher", repos: make(map[string]*repo)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Client returns a github.Client of the server.
+func (s *Server) Client() *github.Client {
+	return &github.Client{BaseURL: s.URL, Token: s.Token}
+}
+
+// SetRateLimit limits the server to n requests in each period of
+// length window.  Conditional requests that are answered with 304 Not
+// Modified do not count.
+func (s *Server) SetRateLimit(n int, window time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rateLimit, s.rateWindow = n, window
+	s.remaining, s.reset = n, resetTime(window)
+}
+
+// resetTime returns the end of a rate limit window that starts now.
+// Like GitHub's, it is a whole number of seconds since the epoch.
+func resetTime(window time.Duration) time.Time {
+	return time.Now().Add(window + time.Second - 1).Truncate(time.Second)
+}
+
+// Requests returns the number of requests served, excluding those
+// answered with 304 Not Modified.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// A repo is the state of a repository.
+type repo struct {
+	name       string
+	issues     []*github.Issue
+	comments   map[int][]*github.Comment // by issue number
+	milestones []*github.Milestone
+}
+
+// repo returns the named repository, creating it if necessary.
+// s.mu must be held.
+func (s *Server) repo(name string) *repo {
+	r := s.repos[name]
+	if r == nil {
+		r = &repo{name: name, comments: make(map[int][]*github.Comment)}
+		s.repos[name] = r
+	}
+	return r
+}
+
+// issue returns the issue with the given number, or nil.
+func (r *repo) issue(number int) *github.Issue {
+	if number < 1 || number > len(r.issues) {
+		return nil
+	}
+	return r.issues[number-1]
+}
+
+func now() time.Time { return time.Now().UTC().Truncate(time.Second) }
+
+// AddRepo adds an empty repository named name ("owner/name"), if
+// it does not exist.  Requests for other repositories fail.
+func (s *Server) AddRepo(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.repo(name)
+}
+
+// AddIssue adds an issue to the repository name ("owner/name"),
+// adding the repository if necessary.  It numbers the issue, and
+// fills in its URL, state and times, if zero.  An issue whose
+// PullRequest is not nil is a pull request, as on GitHub.
+func (s *Server) AddIssue(name string, issue *github.Issue) *github.Issue {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.repo(name)
+	issue.Number = len(r.issues) + 1
+	if issue.PullRequest != nil && issue.PullRequest.HTMLURL == "" {
+		issue.PullRequest.HTMLURL = fmt.Sprintf("https://github.com/%s/pull/%d", name, issue.Number)
+	}
+	if issue.HTMLURL == "" {
+		issue.HTMLURL = fmt.Sprintf("https://github.com/%s/issues/%d", name, issue.Number)
+		if issue.PullRequest != nil {
+			issue.HTMLURL = issue.PullRequest.HTMLURL
+		}
+	}
+	if issue.State == "" {
+		issue.State = "open"
+	}
+	if issue.CreatedAt.IsZero() {
+		issue.CreatedAt = now()
+	}
+	if issue.UpdatedAt.IsZero() {
+		issue.UpdatedAt = issue.CreatedAt
+	}
+	r.issues = append(r.issues, issue)
+	return issue
+}
+
+// AddMilestone adds a milestone to the repository name, adding the
+// repository if necessary.  It numbers the milestone, and fills in
+// its state and creation time, if zero.
+func (s *Server) AddMilestone(name string, m *github.Milestone) *github.Milestone {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.repo(name)
+	m.Number = len(r.milestones) + 1
+	if m.State == "" {
+		m.State = "open"
+	}
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = now()
+	}
+	r.milestones = append(r.milestones, m)
+	return m
+}
+
+// An errorResponse is the body of an error response.
+type errorResponse struct {
+	Message string `json:"message"`
+}
+
+func (s *Server) serve(w http.ResponseWriter, req *http.Request) {
+	if s.Token != "" && req.Header.Get("Authorization") != "token "+s.Token {
+		writeJSON(w, req, http.StatusUnauthorized, errorResponse{"Bad credentials"})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.rateLimit > 0 {
+		if now := time.Now(); !now.Before(s.reset) {
+			s.remaining, s.reset = s.rateLimit, resetTime(s.rateWindow)
+		}
+		h := w.Header()
+		h.Set("X-RateLimit-Limit", strconv.Itoa(s.rateLimit))
+		h.Set("X-RateLimit-Reset", strconv.FormatInt(s.reset.Unix(), 10))
+		if s.remaining == 0 {
+			h.Set("X-RateLimit-Remaining", "0")
+			writeJSON(w, req, http.StatusForbidden, errorResponse{"API rate limit exceeded"})
+			return
+		}
+		h.Set("X-RateLimit-Remaining", strconv.Itoa(s.remaining-1))
+	}
+
+	rec := &recorder{ResponseWriter: w}
+	s.route(rec, req)
+	if rec.status != http.StatusNotModified {
+		s.requests++
+		if s.rateLimit > 0 {
+			s.remaining--
+		}
+	}
+}
+
+// route serves a request.  s.mu is held.
+func (s *Server) route(w http.ResponseWriter, req *http.Request) {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	switch {
+	case req.URL.Path == "/search/issues" && req.Method == "GET":
+		s.search(w, req)
+	case len(parts) == 4 && parts[0] == "repos" && parts[3] == "milestones" && req.Method == "GET":
+		r := s.repos[parts[1]+"/"+parts[2]]
+		if r == nil {
+			notFound(w, req)
+			return
+		}
+		var milestones []*github.Milestone
+		for _, m := range r.milestones {
+			if hasState(req, m.State) {
+				milestones = append(milestones, m)
+			}
+		}
+		page := paginate(w, req, len(milestones))
+		writeJSON(w, req, http.StatusOK, milestones[page.start:page.end])
+	case len(parts) >= 4 && parts[0] == "repos" && parts[3] == "issues":
+		r := s.repos[parts[1]+"/"+parts[2]]
+		if r == nil {
+			notFound(w, req)
+			return
+		}
+		s.issues(w, req, r, parts[4:])
+	default:
+		notFound(w, req)
+	}
+}
+
+func notFound(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, req, http.StatusNotFound, errorResponse{"Not Found"})
+}
+
+// hasState reports whether state matches the state parameter of req,
+// which defaults to "open".
+func hasState(req *http.Request, state string) bool {
+	switch want := req.URL.Query().Get("state"); want {
+	case "all":
+		return true
+	case "":
+		return state == "open"
+	default:
+		return state == want
+	}
+}
+
+func invalid(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, req, http.StatusUnprocessableEntity, errorResponse{"Validation Failed"})
+}
+
+// issues serves /repos/{owner}/{name}/issues and the paths beneath
+// it, which follow it in rest.
+func (s *Server) issues(w http.ResponseWriter, req *http.Request, r *repo, rest []string) {
+	if len(rest) == 0 && req.Method == "GET" {
+		// List the issues, oldest first (the only order supported).
+		var issues []*github.Issue
+		for _, issue := range r.issues {
+			if hasState(req, issue.State) {
+				issues = append(issues, issue)
+			}
+		}
+		page := paginate(w, req, len(issues))
+		writeJSON(w, req, http.StatusOK, issues[page.start:page.end])
+		return
+	}
+	if len(rest) == 0 {
+		if req.Method != "POST" {
+			notFound(w, req)
+			return
+		}
+		var edit github.IssueEdit
+		if json.NewDecoder(req.Body).Decode(&edit) != nil || edit.Title == "" {
+			invalid(w, req)
+			return
+		}
+		issue := &github.Issue{
+			Number: len(r.issues) + 1,
+			Title:  edit.Title,
+			State:  "open",
+			User:   s.user(s.Login),
+		}
+		if !s.apply(r, issue, &edit) {
+			invalid(w, req)
+			return
+		}
+		issue.HTMLURL = fmt.Sprintf("https://github.com/%s/issues/%d", r.name, issue.Number)
+		issue.CreatedAt = issue.UpdatedAt
+		r.issues = append(r.issues, issue)
+		writeJSON(w, req, http.StatusCreated, issue)
+		return
+	}
+
+	number, _ := strconv.Atoi(rest[0])
+	issue := r.issue(number)
+	if issue == nil {
+		notFound(w, req)
+		return
+	}
+	switch {
+	case len(rest) == 1 && req.Method == "GET":
+		writeJSON(w, req, http.StatusOK, issue)
+	case len(rest) == 1 && req.Method == "PATCH":
+		var edit github.IssueEdit
+		if json.NewDecoder(req.Body).Decode(&edit) != nil || !s.apply(r, issue, &edit) {
+			invalid(w, req)
+			return
+		}
+		writeJSON(w, req, http.StatusOK, issue)
+	case len(rest) == 2 && rest[1] == "comments" && req.Method == "GET":
+		comments := r.comments[number]
+		page := paginate(w, req, len(comments))
+		writeJSON(w, req, http.StatusOK, comments[page.start:page.end])
+	case len(rest) == 2 && rest[1] == "comments" && req.Method == "POST":
+		var in struct{ Body string }
+		if json.NewDecoder(req.Body).Decode(&in) != nil || in.Body == "" {
+			invalid(w, req)
+			return
+		}
+		c := &github.Comment{
+			ID:        1000*number + len(r.comments[number]) + 1,
+			User:      s.user(s.Login),
+			Body:      in.Body,
+			CreatedAt: now(),
+		}
+		r.comments[number] = append(r.comments[number], c)
+		issue.Comments++
+		writeJSON(w, req, http.StatusCreated, c)
+	default:
+		notFound(w, req)
+	}
+}
+
+func (s *Server) user(login string) *github.User {
+	return &github.User{Login: login, HTMLURL: "https://github.com/" + login}
+}
+
+// apply applies edit to issue, or reports false, changing nothing,
+// if the edit is invalid.
+func (s *Server) apply(r *repo, issue *github.Issue, edit *github.IssueEdit) bool {
+	if edit.State != "" && edit.State != "open" && edit.State != "closed" {
+		return false
+	}
+	if edit.Milestone != 0 && (edit.Milestone < 0 || edit.Milestone > len(r.milestones)) {
+		return false
+	}
+	if edit.Title != "" {
+		issue.Title = edit.Title
+	}
+	if edit.Body != nil {
+		issue.Body = *edit.Body
+	}
+	if edit.Labels != nil {
+		issue.Labels = nil
+		for _, name := range *edit.Labels {
+			issue.Labels = append(issue.Labels, &github.Label{Name: name, Color: "ededed"})
+		}
+	}
+	if edit.Assignees != nil {
+		issue.Assignees = nil
+		for _, login := range *edit.Assignees {
+			issue.Assignees = append(issue.Assignees, s.user(login))
+		}
+	}
+	if edit.Milestone != 0 {
+		issue.Milestone = r.milestones[edit.Milestone-1]
+	}
+	issue.UpdatedAt = now()
+	if edit.State != "" && edit.State != issue.State {
+		issue.State = edit.State
+		issue.ClosedAt = nil
+		if edit.State == "closed" {
+			t := issue.UpdatedAt
+			issue.ClosedAt = &t
+		}
+	}
+	return true
+}
+
+// A recorder records the status of a response.
+type recorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// searchLimit is the number of results that a search returns at most,
+// however many match, as on GitHub.
+const searchLimit = 1000
+
+// search serves /search/issues.  It understands the qualifiers repo:,
+// is: and state:, including is:issue and is:pr, and otherwise matches
+// words in titles and bodies.
+func (s *Server) search(w http.ResponseWriter, req *http.Request) {
+	var matches []*github.Issue
+	terms := strings.Fields(req.URL.Query().Get("q"))
+	for name, r := range s.repos {
+	issue:
+		for _, issue := range r.issues {
+			for _, term := range terms {
+				var ok bool
+				switch {
+				case strings.HasPrefix(term, "repo:"):
+					ok = name == term[len("repo:"):]
+				case term == "is:issue", term == "is:pr":
+					ok = (issue.PullRequest != nil) == (term == "is:pr")
+				case strings.HasPrefix(term, "is:"), strings.HasPrefix(term, "state:"):
+					ok = issue.State == term[strings.Index(term, ":")+1:]
+				default:
+					text := strings.ToLower(issue.Title + " " + issue.Body)
+					ok = strings.Contains(text, strings.ToLower(term))
+				}
+				if !ok {
+					continue issue
+				}
+			}
+			matches = append(matches, issue)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].CreatedAt.After(matches[j].CreatedAt) ||
+			matches[i].CreatedAt.Equal(matches[j].CreatedAt) && matches[i].Number > matches[j].Number
+	})
+	total := len(matches)
+	if len(matches) > searchLimit {
+		matches = matches[:searchLimit]
+	}
+	page := paginate(w, req, len(matches))
+	writeJSON(w, req, http.StatusOK, github.IssuesSearchResult{
+		TotalCount: total,
+		Items:      matches[page.start:page.end],
+	})
+}
+
+// A page is the range of results for a request.
+type page struct{ start, end int }
+
+// paginate returns the range of n results requested by the page and
+// per_page parameters of req, and sets the Link header of the
+// response to refer to the next and last pages, as GitHub does.
+func paginate(w http.ResponseWriter, req *http.Request, n int) page {
+	q := req.URL.Query()
+	perPage, err := strconv.Atoi(q.Get("per_page"))
+	if err != nil || perPage <= 0 {
+		perPage = 30
+	} else if perPage > 100 {
+		perPage = 100
+	}
+	p, err := strconv.Atoi(q.Get("page"))
+	if err != nil || p < 1 {
+		p = 1
+	}
+	last := (n + perPage - 1) / perPage
+	if last == 0 {
+		last = 1
+	}
+	link := func(p int, rel string) string {
+		q.Set("page", strconv.Itoa(p))
+		u := url.URL{Scheme: "http", Host: req.Host, Path: req.URL.Path, RawQuery: q.Encode()}
+		return fmt.Sprintf(`<%s>; rel="%s"`, u.String(), rel)
+	}
+	var links []string
+	if p < last {
+		links = append(links, link(p+1, "next"), link(last, "last"))
+	}
+	if p > 1 {
+		links = append(links, link(1, "first"), link(p-1, "prev"))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+	start, end := (p-1)*perPage, p*perPage
+	if start > n {
+		start = n
+	}
+	if end > n {
+		end = n
+	}
+	return page{start, end}
+}
+
+// writeJSON writes the JSON encoding of v as the response, with an
+// ETag, or 304 Not Modified if the client already has it.
+func writeJSON(w http.ResponseWriter, req *http.Request, status int, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if status == http.StatusOK && req.Method == "GET" {
+		etag := fmt.Sprintf(`"%x"`, sha1.Sum(data))
+		w.Header().Set("ETag", etag)
+		if req.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(data)
+}
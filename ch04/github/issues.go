@@ -0,0 +1,120 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// PullRequestLinks are the links of an issue that is a pull request.
+// The issues API treats every pull request as an issue too.
+type PullRequestLinks struct {
+	HTMLURL string `json:"html_url"`
+}
+
+type Label struct {
+	Name  string
+	Color string // RGB in hexadecimal, without a leading '#'
+}
+
+type Milestone struct {
+	Number      int
+	Title       string
+	Description string
+	State       string
+	DueOn       *time.Time `json:"due_on"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type Comment struct {
+	ID        int
+	User      *User
+	Body      string    // in Markdown format
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// An IssueEdit is a request to create or change an issue.  Fields
+// with zero values are left unchanged.
+type IssueEdit struct {
+	Title     string    `json:"title,omitempty"`
+	Body      *string   `json:"body,omitempty"`
+	State     string    `json:"state,omitempty"` // "open" or "closed"
+	Labels    *[]string `json:"labels,omitempty"`
+	Assignees *[]string `json:"assignees,omitempty"`
+	Milestone int       `json:"milestone,omitempty"` // number
+}
+
+// The repo arguments of the following methods are of the form
+// "owner/name".
+
+// GetIssue returns the issue with the given number.
+func (c *Client) GetIssue(ctx context.Context, repo string, number int) (*Issue, error) {
+	var issue Issue
+	if _, err := c.do(ctx, "GET", fmt.Sprintf("/repos/%s/issues/%d", repo, number), nil, &issue); err != nil {
+		return nil, err
+	}
+	return &issue, nil
+}
+
+// CreateIssue creates an issue, which must have a title.
+func (c *Client) CreateIssue(ctx context.Context, repo string, edit *IssueEdit) (*Issue, error) {
+	var issue Issue
+	if _, err := c.do(ctx, "POST", fmt.Sprintf("/repos/%s/issues", repo), edit, &issue); err != nil {
+		return nil, err
+	}
+	return &issue, nil
+}
+
+// EditIssue changes an issue, and returns it as changed.
+func (c *Client) EditIssue(ctx context.Context, repo string, number int, edit *IssueEdit) (*Issue, error) {
+	var issue Issue
+	if _, err := c.do(ctx, "PATCH", fmt.Sprintf("/repos/%s/issues/%d", repo, number), edit, &issue); err != nil {
+		return nil, err
+	}
+	return &issue, nil
+}
+
+// Issues returns the issues of repo in the given state, "open",
+// "closed" or "all", oldest first.  They include pull requests, which
+// have a PullRequest field.
+func (c *Client) Issues(ctx context.Context, repo, state string) ([]*Issue, error) {
+	return getAll[*Issue](ctx, c, fmt.Sprintf("/repos/%s/issues?state=%s&sort=created&direction=asc&per_page=100",
+		repo, url.QueryEscape(state)))
+}
+
+// Milestones returns the milestones of repo, open and closed.
+func (c *Client) Milestones(ctx context.Context, repo string) ([]*Milestone, error) {
+	return getAll[*Milestone](ctx, c, fmt.Sprintf("/repos/%s/milestones?state=all&per_page=100", repo))
+}
+
+// Comments returns the comments on an issue, oldest first.
+func (c *Client) Comments(ctx context.Context, repo string, number int) ([]*Comment, error) {
+	return getAll[*Comment](ctx, c, fmt.Sprintf("/repos/%s/issues/%d/comments?per_page=100", repo, number))
+}
+
+// getAll returns the items of every page of the list at url.
+func getAll[T any](ctx context.Context, c *Client, url string) ([]T, error) {
+	var all []T
+	for url != "" {
+		var page []T
+		var err error
+		if url, err = c.do(ctx, "GET", url, nil, &page); err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+	}
+	return all, nil
+}
+
+// AddComment adds a comment to an issue.
+func (c *Client) AddComment(ctx context.Context, repo string, number int, body string) (*Comment, error) {
+	var comment Comment
+	in := struct {
+		Body string `json:"body"`
+	}{body}
+	if _, err := c.do(ctx, "POST", fmt.Sprintf("/repos/%s/issues/%d/comments", repo, number), in, &comment); err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
@@ -0,0 +1,124 @@
+package github_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"gopl.io/ch04/github"
+	"gopl.io/ch04/github/githubtest"
+)
+
+func TestIssues(t *testing.T) {
+	s := githubtest.NewServer()
+	defer s.Close()
+	s.AddRepo("a/b")
+	m := s.AddMilestone("a/b", &github.Milestone{Title: "v1"})
+	c := s.Client()
+	ctx := context.Background()
+
+	body := "It fails."
+	labels := []string{"bug"}
+	issue, err := c.CreateIssue(ctx, "a/b", &github.IssueEdit{
+		Title:     "crash",
+		Body:      &body,
+		Labels:    &labels,
+		Milestone: m.Number,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issue.Number != 1 || issue.State != "open" || issue.User.Login != "gopher" ||
+		len(issue.Labels) != 1 || issue.Milestone.Title != "v1" {
+		t.Errorf("created %+v", issue)
+	}
+
+	if _, err := c.AddComment(ctx, "a/b", 1, "Fixed."); err != nil {
+		t.Fatal(err)
+	}
+	assignees := []string{"alice"}
+	if _, err := c.EditIssue(ctx, "a/b", 1, &github.IssueEdit{State: "closed", Assignees: &assignees}); err != nil {
+		t.Fatal(err)
+	}
+
+	issue, err = c.GetIssue(ctx, "a/b", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issue.State != "closed" || issue.ClosedAt == nil || issue.Body != body ||
+		issue.Comments != 1 || issue.Assignees[0].Login != "alice" {
+		t.Errorf("after editing, issue is %+v", issue)
+	}
+	comments, err := c.Comments(ctx, "a/b", 1)
+	if err != nil || len(comments) != 1 || comments[0].Body != "Fixed." {
+		t.Errorf("Comments() = %v, %v", comments, err)
+	}
+
+	var e *github.Error
+	if _, err := c.GetIssue(ctx, "a/b", 2); !errors.As(err, &e) || e.StatusCode != 404 {
+		t.Errorf("GetIssue of missing issue: got error %v, want 404", err)
+	}
+	if _, err := c.CreateIssue(ctx, "a/b", &github.IssueEdit{}); !errors.As(err, &e) || e.StatusCode != 422 {
+		t.Errorf("CreateIssue without title: got error %v, want 422", err)
+	}
+}
+
+func TestLists(t *testing.T) {
+	s := newServer(t, 150)
+	s.AddMilestone("a/b", &github.Milestone{Title: "v1"})
+	s.AddMilestone("a/b", &github.Milestone{Title: "v0", State: "closed"})
+	c := s.Client()
+	ctx := context.Background()
+
+	if _, err := c.EditIssue(ctx, "a/b", 7, &github.IssueEdit{State: "closed"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		state string
+		want  int
+	}{{"open", 149}, {"closed", 1}, {"all", 150}} {
+		issues, err := c.Issues(ctx, "a/b", test.state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(issues) != test.want {
+			t.Errorf("Issues(%q) returned %d issues, want %d", test.state, len(issues), test.want)
+		}
+		for i := 1; i < len(issues); i++ {
+			if issues[i-1].Number >= issues[i].Number {
+				t.Errorf("Issues(%q) out of order: #%d before #%d",
+					test.state, issues[i-1].Number, issues[i].Number)
+				break
+			}
+		}
+	}
+
+	// Pull requests are listed too, and marked as such.
+	s.AddIssue("a/b", &github.Issue{Title: "fix", PullRequest: &github.PullRequestLinks{}})
+	issues, err := c.Issues(ctx, "a/b", "open")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr := issues[len(issues)-1]; pr.PullRequest == nil || !strings.HasSuffix(pr.PullRequest.HTMLURL, "/pull/151") {
+		t.Errorf("pull request listed as %+v", pr)
+	}
+	result, err := c.SearchIssues(ctx, []string{"repo:a/b", "is:pr"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TotalCount != 1 {
+		t.Errorf("search for pull requests found %d", result.TotalCount)
+	}
+
+	milestones, err := c.Milestones(ctx, "a/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(milestones) != 2 || milestones[1].State != "closed" {
+		t.Errorf("Milestones returned %+v", milestones)
+	}
+	if _, err := c.Issues(ctx, "x/y", "all"); err == nil {
+		t.Error("Issues of a missing repository succeeded")
+	}
+}
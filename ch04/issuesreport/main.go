@@ -4,6 +4,7 @@
 // See page 113.
 
 // Issuesreport prints a report of issues matching the search terms.
+// Issuestats counts them instead, by age, label, assignee and week.
 package main
 
 import (
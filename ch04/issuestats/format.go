@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// formats maps the name of each output format to its writer.
+var formats = map[string]func(w io.Writer, r *Report) error{
+	"text":     execute(textReport),
+	"markdown": execute(markdownReport),
+	"csv":      writeCSV,
+	"json":     writeJSON,
+}
+
+func execute(t *template.Template) func(w io.Writer, r *Report) error {
+	return func(w io.Writer, r *Report) error { return t.Execute(w, r) }
+}
+
+var funcs = template.FuncMap{
+	"date": func(t time.Time) string { return t.Format("2006-01-02") },
+	// md escapes the characters that would break a Markdown table.
+	"md": strings.NewReplacer("|", `\|`, "\n", " ").Replace,
+}
+
+var textReport = template.Must(template.New("text").Funcs(funcs).Parse(
+	`{{if .Incomplete}}WARNING: incomplete: the search returned only {{.Total}} of the {{.Matched}} matching issues.
+{{end}}{{.Total}} issues matching {{.Query}} as of {{date .Generated}}: {{.Open}} open, {{.Closed}} closed
+
+Week of      Opened  Closed    Open
+{{range .Weeks}}{{date .Start}}  {{printf "%6d  %6d  %6d" .Opened .Closed .Open}}
+{{end}}
+Open issues by age:
+{{range .Ages}}  {{printf "%-24.24s %5d" .Name .N}}
+{{end}}
+Open issues by label:
+{{range .Labels}}  {{printf "%-24.24s %5d" .Name .N}}
+{{else}}  none
+{{end}}
+Open issues by assignee:
+{{range .Assignees}}  {{printf "%-24.24s %5d" .Name .N}}
+{{else}}  none
+{{end}}
+{{with .TimeToClose}}{{if .N}}Days to close {{.N}} issues: mean {{.Mean}}, median {{.Median}}, 90th percentile {{.P90}}, max {{.Max}}
+{{else}}No closed issues.
+{{end}}{{end}}`))
+
+var markdownReport = template.Must(template.New("markdown").Funcs(funcs).Parse(
+	`# Issues matching {{md .Query}}
+
+As of {{date .Generated}}: **{{.Total}}** issues, **{{.Open}}** open, **{{.Closed}}** closed.
+{{if .Incomplete}}
+**Incomplete:** the search returned only {{.Total}} of the {{.Matched}} matching issues.
+{{end}}
+## Weekly
+
+| Week of | Opened | Closed | Open |
+|---|--:|--:|--:|
+{{range .Weeks}}| {{date .Start}} | {{.Opened}} | {{.Closed}} | {{.Open}} |
+{{end}}
+## Open issues by age
+
+| Age | Issues |
+|---|--:|
+{{range .Ages}}| {{.Name}} | {{.N}} |
+{{end}}
+## Open issues by label
+
+| Label | Issues |
+|---|--:|
+{{range .Labels}}| {{md .Name}} | {{.N}} |
+{{end}}
+## Open issues by assignee
+
+| Assignee | Issues |
+|---|--:|
+{{range .Assignees}}| {{md .Name}} | {{.N}} |
+{{end}}
+## Days to close
+{{with .TimeToClose}}
+| Issues | Mean | Median | 90th percentile | Max |
+|--:|--:|--:|--:|--:|
+| {{.N}} | {{.Mean}} | {{.Median}} | {{.P90}} | {{.Max}} |
+{{end}}`))
+
+// writeCSV writes r as rows of three columns, section, name and
+// value, which suit a spreadsheet's pivot table.
+func writeCSV(w io.Writer, r *Report) error {
+	cw := csv.NewWriter(w)
+	row := func(section, name string, value interface{}) {
+		cw.Write([]string{section, name, fmt.Sprint(value)})
+	}
+	row("section", "name", "value")
+	row("total", "matched", r.Matched)
+	row("total", "all", r.Total)
+	row("total", "open", r.Open)
+	row("total", "closed", r.Closed)
+	for _, wk := range r.Weeks {
+		start := wk.Start.Format("2006-01-02")
+		row("opened", start, wk.Opened)
+		row("closed", start, wk.Closed)
+		row("open", start, wk.Open)
+	}
+	for _, c := range r.Ages {
+		row("age", c.Name, c.N)
+	}
+	for _, c := range r.Labels {
+		row("label", c.Name, c.N)
+	}
+	for _, c := range r.Assignees {
+		row("assignee", c.Name, c.N)
+	}
+	s := r.TimeToClose
+	row("days to close", "issues", s.N)
+	for _, stat := range []struct {
+		name  string
+		value float64
+	}{{"mean", s.Mean}, {"median", s.Median}, {"p90", s.P90}, {"max", s.Max}} {
+		row("days to close", stat.name, strconv.FormatFloat(stat.value, 'f', -1, 64))
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeJSON(w io.Writer, r *Report) error {
+	data, err := json.MarshalIndent(r, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
@@ -0,0 +1,76 @@
+// Issuestats prints weekly statistics of the issues matching the
+// search terms, for a triage meeting.
+//
+// Where issuesreport lists the issues, issuestats counts them: the
+// numbers opened, closed and still open in each recent week; the open
+// issues by age (under a month, under a year, older), by label and by
+// assignee; and the time taken to close the closed ones.  The report
+// is printed as text, Markdown, CSV or JSON.
+//
+//	$ issuestats -format markdown -weeks 4 repo:golang/go label:Tools
+//
+// Unless the terms include is:open, the search finds closed issues
+// too, which the weekly numbers and times to close need.  Pull
+// requests are left out.  A search
+// returns at most 1000 issues; if more match, the report warns that
+// its numbers are incomplete, and the terms should be narrowed.
+// The access token, if any, is taken from $GITHUB_TOKEN.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gopl.io/ch04/github"
+)
+
+var (
+	format = flag.String("format", "text", "output `format`: text, markdown, csv or json")
+	weeks  = flag.Int("weeks", 8, "report the last `N` weeks")
+	api    = flag.String("api", github.DefaultBaseURL, "base `URL` of the GitHub API")
+)
+
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 || *weeks < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	c := &github.Client{BaseURL: *api, Token: os.Getenv("GITHUB_TOKEN")}
+	if err := run(context.Background(), c, flag.Args(), *format, *weeks, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "issuestats: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run searches for the issues matching terms and writes the report
+// of them in the named format to out.
+func run(ctx context.Context, c *github.Client, terms []string, format string, weeks int, out io.Writer) error {
+	write := formats[format]
+	if write == nil {
+		var names []string
+		for name := range formats {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown format %q (want %s)", format, strings.Join(names, ", "))
+	}
+	// GitHub searches pull requests too, unless told not to, and
+	// they would inflate both the counts and the number matched.
+	search := append([]string{"is:issue"}, terms...)
+	result, err := c.SearchIssues(ctx, search)
+	if err != nil {
+		return err
+	}
+	r := newReport(strings.Join(terms, " "), result.Items, time.Now(), weeks)
+	if result.TotalCount > r.Matched {
+		r.Matched = result.TotalCount // the report says it is incomplete
+	}
+	return write(out, r)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"gopl.io/ch04/github"
+	"gopl.io/ch04/github/githubtest"
+)
+
+func TestFormats(t *testing.T) {
+	s := githubtest.NewServer()
+	defer s.Close()
+	s.AddIssue("a/b", &github.Issue{Title: "new", Labels: []*github.Label{{Name: "a|b"}}})
+	closedAt := time.Now().UTC().Truncate(time.Second)
+	s.AddIssue("a/b", &github.Issue{Title: "done", State: "closed",
+		CreatedAt: closedAt.Add(-3 * day), ClosedAt: &closedAt})
+	s.AddIssue("c/d", &github.Issue{Title: "elsewhere"})
+	s.AddIssue("a/b", &github.Issue{Title: "fix", PullRequest: &github.PullRequestLinks{}})
+	c := s.Client()
+
+	report := func(format string) string {
+		t.Helper()
+		var out bytes.Buffer
+		if err := run(context.Background(), c, []string{"repo:a/b"}, format, 4, &out); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		return out.String()
+	}
+
+	if out := report("text"); !strings.HasPrefix(out, "2 issues matching repo:a/b") ||
+		!strings.Contains(out, "Days to close 1 issues: mean 3,") {
+		t.Errorf("text report:\n%s", out)
+	}
+	if out := report("markdown"); !strings.Contains(out, "| a\\|b | 1 |") {
+		t.Errorf("markdown report:\n%s", out)
+	}
+
+	rows, err := csv.NewReader(strings.NewReader(report("csv"))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := make(map[string]string)
+	for _, row := range rows {
+		values[row[0]+"/"+row[1]] = row[2]
+	}
+	if values["total/open"] != "1" || values["label/a|b"] != "1" || values["days to close/mean"] != "3" {
+		t.Errorf("csv report: %v", rows)
+	}
+
+	var r Report
+	if err := json.Unmarshal([]byte(report("json")), &r); err != nil {
+		t.Fatal(err)
+	}
+	var opened, closed int
+	for _, w := range r.Weeks {
+		opened += w.Opened
+		closed += w.Closed
+	}
+	if r.Total != 2 || len(r.Weeks) != 4 || opened != 2 || closed != 1 {
+		t.Errorf("json report: %+v", r)
+	}
+
+	var out bytes.Buffer
+	if err := run(context.Background(), c, []string{"repo:a/b"}, "xml", 4, &out); err == nil {
+		t.Error("unknown format succeeded")
+	}
+}
+
+func TestIncomplete(t *testing.T) {
+	s := githubtest.NewServer()
+	defer s.Close()
+	for i := 0; i < 1001; i++ {
+		s.AddIssue("a/b", &github.Issue{Title: "dup"})
+	}
+	var out bytes.Buffer
+	if err := run(context.Background(), s.Client(), []string{"repo:a/b"}, "text", 4, &out); err != nil {
+		t.Fatal(err)
+	}
+	if want := "WARNING: incomplete: the search returned only 1000 of the 1001 matching issues.\n"; !strings.HasPrefix(out.String(), want) {
+		t.Errorf("text report of a truncated search begins %.100q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"gopl.io/ch04/github"
+)
+
+const day = 24 * time.Hour
+
+// A Report summarizes a set of issues as of a given time.
+type Report struct {
+	Query       string
+	Generated   time.Time
+	Matched     int // issues matching the query, if more than the search returned
+	Total       int // issues counted
+	Open        int
+	Closed      int
+	Ages        []Count    // open issues, by age bucket
+	Labels      []Count    // open issues, by label, most first
+	Assignees   []Count    // open issues, by assignee, most first
+	Weeks       []Week     // oldest first
+	TimeToClose CloseStats // of all closed issues
+}
+
+// A Count is the number of issues in a group.
+type Count struct {
+	Name string
+	N    int
+}
+
+// A Week holds the numbers of issues opened and closed in the week
+// beginning on Start, a Monday, and the number open at its end.
+type Week struct {
+	Start  time.Time
+	Opened int
+	Closed int
+	Open   int
+}
+
+// CloseStats are statistics of the times taken to close issues,
+// in days.
+type CloseStats struct {
+	N      int
+	Mean   float64
+	Median float64
+	P90    float64 // 90th percentile
+	Max    float64
+}
+
+// ageBuckets are the age buckets of open issues, youngest first.
+// An issue falls in the first bucket whose limit exceeds its age.
+var ageBuckets = []struct {
+	name  string
+	limit time.Duration
+}{
+	{"under a month", 30 * day},
+	{"under a year", 365 * day},
+	{"older", math.MaxInt64},
+}
+
+const unassigned = "(unassigned)"
+
+// Incomplete reports whether the numbers of r omit some of the
+// issues matching its query, which the search did not return.
+func (r *Report) Incomplete() bool { return r.Matched > r.Total }
+
+// newReport returns the report of issues as of now, with numbers
+// for the last weeks weeks.  The numbers of issues closed and open
+// in each week are accurate only if issues includes closed issues.
+// Pull requests among issues are not counted.
+func newReport(query string, issues []*github.Issue, now time.Time, weeks int) *Report {
+	var only []*github.Issue
+	for _, issue := range issues {
+		if issue.PullRequest == nil {
+			only = append(only, issue)
+		}
+	}
+	issues = only
+	r := &Report{Query: query, Generated: now, Matched: len(issues), Total: len(issues)}
+
+	ages := make([]int, len(ageBuckets))
+	labels := make(map[string]int)
+	assignees := make(map[string]int)
+	var closeTimes []time.Duration
+	for _, issue := range issues {
+		if issue.State != "open" {
+			r.Closed++
+			if issue.ClosedAt != nil {
+				closeTimes = append(closeTimes, issue.ClosedAt.Sub(issue.CreatedAt))
+			}
+			continue
+		}
+		r.Open++
+		age := now.Sub(issue.CreatedAt)
+		for i, b := range ageBuckets {
+			if age < b.limit {
+				ages[i]++
+				break
+			}
+		}
+		for _, l := range issue.Labels {
+			labels[l.Name]++
+		}
+		for _, u := range issue.Assignees {
+			assignees[u.Login]++
+		}
+		if len(issue.Assignees) == 0 {
+			assignees[unassigned]++
+		}
+	}
+	for i, b := range ageBuckets {
+		r.Ages = append(r.Ages, Count{b.name, ages[i]})
+	}
+	r.Labels = counts(labels)
+	r.Assignees = counts(assignees)
+	r.Weeks = weekly(issues, now, weeks)
+	r.TimeToClose = closeStats(closeTimes)
+	return r
+}
+
+// counts returns the counts of m, most first, then by name.
+func counts(m map[string]int) []Count {
+	var list []Count
+	for name, n := range m {
+		list = append(list, Count{name, n})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].N != list[j].N {
+			return list[i].N > list[j].N
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// weekStart returns the start of the week containing t: midnight UTC
+// on the preceding Monday.
+func weekStart(t time.Time) time.Time {
+	t = t.UTC()
+	offset := (int(t.Weekday()) + 6) % 7 // days since Monday
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, time.UTC)
+}
+
+// weekly returns the numbers of issues for the n weeks up to and
+// including the week containing now.
+func weekly(issues []*github.Issue, now time.Time, n int) []Week {
+	weeks := make([]Week, n)
+	first := weekStart(now).AddDate(0, 0, -7*(n-1))
+	for i := range weeks {
+		weeks[i].Start = first.AddDate(0, 0, 7*i)
+	}
+	// index returns the index of the week containing t, or -1 if
+	// it is before the first week.
+	index := func(t time.Time) int {
+		if t.Before(first) {
+			return -1
+		}
+		i := int(t.Sub(first) / (7 * day))
+		if i >= n {
+			i = n - 1
+		}
+		return i
+	}
+	for _, issue := range issues {
+		opened := index(issue.CreatedAt)
+		if opened >= 0 {
+			weeks[opened].Opened++
+		}
+		closed := n // never, as far as these weeks show
+		if issue.State != "open" && issue.ClosedAt != nil {
+			closed = index(*issue.ClosedAt)
+			if closed >= 0 {
+				weeks[closed].Closed++
+			}
+		}
+		// The issue was open at the end of each week from the one
+		// in which it was opened until the one before it closed.
+		if opened < 0 {
+			opened = 0
+		}
+		for i := opened; i < closed && i < n; i++ {
+			weeks[i].Open++
+		}
+	}
+	return weeks
+}
+
+// closeStats returns the statistics of the times in ds.
+func closeStats(ds []time.Duration) CloseStats {
+	if len(ds) == 0 {
+		return CloseStats{}
+	}
+	sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+	days := func(d time.Duration) float64 { return math.Round(float64(d)/float64(day)*10) / 10 }
+	var sum time.Duration
+	for _, d := range ds {
+		sum += d
+	}
+	n := len(ds)
+	median := ds[n/2]
+	if n%2 == 0 {
+		median = (ds[n/2-1] + ds[n/2]) / 2
+	}
+	p90 := ds[int(math.Ceil(0.9*float64(n)))-1]
+	return CloseStats{
+		N:      n,
+		Mean:   days(sum / time.Duration(n)),
+		Median: days(median),
+		P90:    days(p90),
+		Max:    days(ds[n-1]),
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"gopl.io/ch04/github"
+)
+
+func TestReport(t *testing.T) {
+	date := func(s string) time.Time {
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+	closed := func(s string) *time.Time {
+		t := date(s)
+		return &t
+	}
+	now := date("2026-10-14").Add(12 * time.Hour) // a Wednesday
+	bug := &github.Label{Name: "bug"}
+	issues := []*github.Issue{
+		{Number: 1, State: "open", CreatedAt: now.Add(-2 * day),
+			Labels: []*github.Label{bug}, Assignees: []*github.User{{Login: "alice"}}},
+		{Number: 2, State: "open", CreatedAt: now.Add(-100 * day),
+			Labels: []*github.Label{bug, {Name: "p1"}}},
+		{Number: 3, State: "open", CreatedAt: now.Add(-400 * day)},
+		{Number: 4, State: "closed", CreatedAt: date("2026-10-01"), ClosedAt: closed("2026-10-06")},
+		{Number: 5, State: "closed", CreatedAt: date("2026-09-23"), ClosedAt: closed("2026-10-13")},
+		{Number: 6, State: "closed", CreatedAt: date("2025-12-30"), ClosedAt: closed("2026-01-01")},
+		{Number: 7, State: "open", CreatedAt: now.Add(-day), Labels: []*github.Label{bug},
+			PullRequest: &github.PullRequestLinks{}}, // not counted
+	}
+	r := newReport("q", issues, now, 3)
+
+	if r.Total != 6 || r.Open != 3 || r.Closed != 3 {
+		t.Errorf("totals: %d, %d open, %d closed; want 6, 3, 3", r.Total, r.Open, r.Closed)
+	}
+	wantWeeks := []Week{
+		{date("2026-09-28"), 1, 0, 4},
+		{date("2026-10-05"), 0, 1, 3},
+		{date("2026-10-12"), 1, 1, 3},
+	}
+	if !reflect.DeepEqual(r.Weeks, wantWeeks) {
+		t.Errorf("weeks:\n%v\nwant\n%v", r.Weeks, wantWeeks)
+	}
+	for _, test := range []struct {
+		name      string
+		got, want []Count
+	}{
+		{"ages", r.Ages, []Count{{"under a month", 1}, {"under a year", 1}, {"older", 1}}},
+		{"labels", r.Labels, []Count{{"bug", 2}, {"p1", 1}}},
+		{"assignees", r.Assignees, []Count{{unassigned, 2}, {"alice", 1}}},
+	} {
+		if !reflect.DeepEqual(test.got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, test.got, test.want)
+		}
+	}
+	want := CloseStats{N: 3, Mean: 9, Median: 5, P90: 20, Max: 20}
+	if r.TimeToClose != want {
+		t.Errorf("time to close: got %+v, want %+v", r.TimeToClose, want)
+	}
+}
+
+func TestWeekStart(t *testing.T) {
+	for _, test := range []struct{ t, want string }{
+		{"2026-10-12T00:00:00Z", "2026-10-12"}, // Monday
+		{"2026-10-18T23:59:59Z", "2026-10-12"}, // Sunday
+		{"2026-10-19T01:00:00+02:00", "2026-10-12"},
+		{"2026-01-01T12:00:00Z", "2025-12-29"},
+	} {
+		tm, _ := time.Parse(time.RFC3339, test.t)
+		if got := weekStart(tm).Format("2006-01-02"); got != test.want {
+			t.Errorf("weekStart(%s) = %s, want %s", test.t, got, test.want)
+		}
+	}
+}
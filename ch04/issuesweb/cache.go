@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"gopl.io/ch04/github"
+)
+
+// A snapshot is the state of a repository at one time.  It is not
+// modified once made, so it may be read without locking.
+type snapshot struct {
+	Repo       string
+	Issues     []*github.Issue     // in order of number
+	Milestones []*github.Milestone // in order of number
+	Users      []*github.User      // authors and assignees, by login
+	Labels     []string            // in sorted order
+	Fetched    time.Time
+
+	issues     map[int]*github.Issue
+	milestones map[int]*github.Milestone
+	users      map[string]*github.User
+}
+
+// newSnapshot returns a snapshot of repo, whose issues are those
+// given less the pull requests among them.
+func newSnapshot(repo string, all []*github.Issue, milestones []*github.Milestone) *snapshot {
+	var issues []*github.Issue
+	for _, issue := range all {
+		if issue.PullRequest == nil {
+			issues = append(issues, issue)
+		}
+	}
+	s := &snapshot{
+		Repo:       repo,
+		Issues:     issues,
+		Milestones: milestones,
+		Fetched:    time.Now(),
+		issues:     make(map[int]*github.Issue),
+		milestones: make(map[int]*github.Milestone),
+		users:      make(map[string]*github.User),
+	}
+	sort.Slice(issues, func(i, j int) bool { return issues[i].Number < issues[j].Number })
+	sort.Slice(milestones, func(i, j int) bool { return milestones[i].Number < milestones[j].Number })
+
+	addUser := func(u *github.User) {
+		if u != nil && s.users[u.Login] == nil {
+			s.users[u.Login] = u
+			s.Users = append(s.Users, u)
+		}
+	}
+	labels := make(map[string]bool)
+	for _, issue := range issues {
+		s.issues[issue.Number] = issue
+		addUser(issue.User)
+		for _, u := range issue.Assignees {
+			addUser(u)
+		}
+		for _, l := range issue.Labels {
+			if !labels[l.Name] {
+				labels[l.Name] = true
+				s.Labels = append(s.Labels, l.Name)
+			}
+		}
+	}
+	for _, m := range milestones {
+		s.milestones[m.Number] = m
+	}
+	sort.Slice(s.Users, func(i, j int) bool { return s.Users[i].Login < s.Users[j].Login })
+	sort.Strings(s.Labels)
+	return s
+}
+
+// A cache holds the latest snapshot of a repository.
+type cache struct {
+	client *github.Client
+	repo   string
+
+	mu   sync.Mutex
+	snap *snapshot
+	err  error // of the latest refresh, if it failed
+}
+
+// refresh replaces the snapshot by a new one fetched from GitHub.
+// If that fails, the old snapshot is kept.  The client's conditional
+// requests make a refresh cheap when nothing has changed.
+func (c *cache) refresh(ctx context.Context) error {
+	issues, err := c.client.Issues(ctx, c.repo, "all")
+	var milestones []*github.Milestone
+	if err == nil {
+		milestones, err = c.client.Milestones(ctx, c.repo)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+	if err == nil {
+		c.snap = newSnapshot(c.repo, issues, milestones)
+	}
+	return err
+}
+
+// get returns the latest snapshot, or nil if there is none yet, and
+// the error of the latest refresh.
+func (c *cache) get() (*snapshot, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.snap, c.err
+}
+
+// poll refreshes the cache every period until ctx is cancelled.
+func (c *cache) poll(ctx context.Context, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.refresh(ctx); err != nil {
+				log.Printf("refreshing %s: %v", c.repo, err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"gopl.io/ch04/github"
+)
+
+// A filter selects and orders a list of issues.  It is parsed from,
+// and encoded in, the query of a page's URL, so that every list can
+// be bookmarked.
+type filter struct {
+	State string // "open", "closed" or "all"
+	Label string // "" means any
+	Age   string // "" (any), "week", "month", "year" or "older"
+	Sort  string // a key of sorts, preceded by "-" for descending order
+}
+
+const day = 24 * time.Hour
+
+// maxAge gives the greatest age of an issue in each age bucket
+// but "older", which holds issues more than a year old.
+var maxAge = map[string]time.Duration{
+	"week":  7 * day,
+	"month": 30 * day,
+	"year":  365 * day,
+}
+
+// sorts gives the comparison for each sort key.
+var sorts = map[string]func(x, y *github.Issue) bool{
+	"number":   func(x, y *github.Issue) bool { return x.Number < y.Number },
+	"created":  func(x, y *github.Issue) bool { return x.CreatedAt.Before(y.CreatedAt) },
+	"updated":  func(x, y *github.Issue) bool { return x.UpdatedAt.Before(y.UpdatedAt) },
+	"comments": func(x, y *github.Issue) bool { return x.Comments < y.Comments },
+	"title": func(x, y *github.Issue) bool {
+		return strings.ToLower(x.Title) < strings.ToLower(y.Title)
+	},
+}
+
+// parseFilter returns the filter in query q.  Missing or unknown
+// values select open issues of any label and age, newest first.
+func parseFilter(q url.Values) filter {
+	f := filter{
+		State: q.Get("state"),
+		Label: q.Get("label"),
+		Age:   q.Get("age"),
+		Sort:  q.Get("sort"),
+	}
+	if f.State != "closed" && f.State != "all" {
+		f.State = "open"
+	}
+	if _, ok := maxAge[f.Age]; !ok && f.Age != "older" {
+		f.Age = ""
+	}
+	if sorts[strings.TrimPrefix(f.Sort, "-")] == nil {
+		f.Sort = "-number"
+	}
+	return f
+}
+
+// apply returns the issues selected by f, in the order it specifies.
+func (f filter) apply(issues []*github.Issue, now time.Time) []*github.Issue {
+	var selected []*github.Issue
+	for _, issue := range issues {
+		if f.match(issue, now) {
+			selected = append(selected, issue)
+		}
+	}
+	less := sorts[strings.TrimPrefix(f.Sort, "-")]
+	desc := strings.HasPrefix(f.Sort, "-")
+	sort.SliceStable(selected, func(i, j int) bool {
+		x, y := selected[i], selected[j]
+		if desc {
+			x, y = y, x
+		}
+		if less(x, y) {
+			return true
+		}
+		if less(y, x) {
+			return false
+		}
+		return x.Number < y.Number
+	})
+	return selected
+}
+
+func (f filter) match(issue *github.Issue, now time.Time) bool {
+	if f.State != "all" && issue.State != f.State {
+		return false
+	}
+	if f.Label != "" && !hasLabel(issue, f.Label) {
+		return false
+	}
+	age := now.Sub(issue.CreatedAt)
+	switch f.Age {
+	case "":
+	case "older":
+		if age <= maxAge["year"] {
+			return false
+		}
+	default:
+		if age > maxAge[f.Age] {
+			return false
+		}
+	}
+	return true
+}
+
+func hasLabel(issue *github.Issue, name string) bool {
+	for _, l := range issue.Labels {
+		if l.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// With returns the query of f with key set to value.
+func (f filter) With(key, value string) string {
+	q := url.Values{}
+	q.Set("state", f.State)
+	q.Set("label", f.Label)
+	q.Set("age", f.Age)
+	q.Set("sort", f.Sort)
+	q.Set(key, value)
+	for k := range q {
+		if q.Get(k) == "" {
+			q.Del(k)
+		}
+	}
+	return "?" + q.Encode()
+}
+
+// SortBy returns the query of f sorted by key, reversing the order
+// if f is sorted by key already.
+func (f filter) SortBy(key string) string {
+	if f.Sort == key {
+		return f.With("sort", "-"+key)
+	}
+	return f.With("sort", key)
+}
+
+// Arrow returns an arrow showing the order of f by key, if any.
+func (f filter) Arrow(key string) string {
+	switch f.Sort {
+	case key:
+		return "▲"
+	case "-" + key:
+		return "▼"
+	}
+	return ""
+}
@@ -0,0 +1,52 @@
+// Issuesweb serves a browsable web dashboard of the issues of a GitHub
+// repository (see exercise 4.14).
+//
+// It extends issueshtml into a long-running server.  It fetches every
+// issue and milestone of the repository at start-up, holds them in
+// memory, and refreshes them periodically.  It serves a page for each
+// issue, milestone and user; lists of issues may be filtered by state,
+// label and age, and sorted by any column.
+//
+//	$ issuesweb -repo golang/go -http localhost:8000
+//
+// The access token, if any, is taken from $GITHUB_TOKEN.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"gopl.io/ch04/github"
+)
+
+var (
+	repo    = flag.String("repo", "", "the repository, `owner/name`")
+	api     = flag.String("api", github.DefaultBaseURL, "base `URL` of the GitHub API")
+	addr    = flag.String("http", "localhost:8000", "serve HTTP on `address`")
+	refresh = flag.Duration("refresh", 5*time.Minute, "refresh the issues every `period`")
+)
+
+func main() {
+	flag.Parse()
+	if *repo == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	c := &cache{
+		client: &github.Client{BaseURL: *api, Token: os.Getenv("GITHUB_TOKEN")},
+		repo:   *repo,
+	}
+	ctx := context.Background()
+	if err := c.refresh(ctx); err != nil {
+		log.Fatalf("fetching %s: %v", *repo, err)
+	}
+	go c.poll(ctx, *refresh)
+
+	s := &server{cache: c}
+	log.Printf("serving %s on http://%s", *repo, *addr)
+	log.Fatal(http.ListenAndServe(*addr, s.routes()))
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"gopl.io/ch04/github"
+	"gopl.io/ch04/github/githubtest"
+)
+
+// newTestServer returns a dashboard of a fake repository a/b.
+func newTestServer(t *testing.T) (*httptest.Server, *githubtest.Server, *cache) {
+	gh := githubtest.NewServer()
+	t.Cleanup(gh.Close)
+	m := gh.AddMilestone("a/b", &github.Milestone{Title: "v1"})
+	alice := &github.User{Login: "alice"}
+	bob := &github.User{Login: "bob"}
+	now := time.Now()
+	for _, issue := range []*github.Issue{
+		{Title: "crash", User: alice, CreatedAt: now.Add(-2 * day),
+			Labels: []*github.Label{{Name: "bug"}}, Milestone: m, Comments: 2},
+		{Title: "<script>alert(1)</script>", User: bob, CreatedAt: now.Add(-60 * day)},
+		{Title: "old", User: alice, CreatedAt: now.Add(-400 * day), State: "closed",
+			Assignees: []*github.User{bob}, Milestone: m},
+	} {
+		gh.AddIssue("a/b", issue)
+	}
+	c := &cache{client: gh.Client(), repo: "a/b"}
+	if err := c.refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer((&server{cache: c}).routes())
+	t.Cleanup(ts.Close)
+	return ts, gh, c
+}
+
+func get(t *testing.T, ts *httptest.Server, path string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(ts.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+// listed returns the numbers of the issues listed in page, in order.
+func listed(page string) string {
+	var numbers []string
+	for _, m := range regexp.MustCompile(`<tr[^>]*>\s*<td><a href="/issues/(\d+)">`).FindAllStringSubmatch(page, -1) {
+		numbers = append(numbers, m[1])
+	}
+	return strings.Join(numbers, " ")
+}
+
+func TestLists(t *testing.T) {
+	ts, _, _ := newTestServer(t)
+	for _, test := range []struct {
+		path, want string
+	}{
+		{"/", "2 1"},
+		{"/?state=all", "3 2 1"},
+		{"/?state=closed", "3"},
+		{"/?state=all&label=bug", "1"},
+		{"/?state=all&age=week", "1"},
+		{"/?state=all&age=year", "2 1"},
+		{"/?state=all&age=older", "3"},
+		{"/?state=all&sort=number", "1 2 3"},
+		{"/?state=all&sort=-created", "1 2 3"},
+		{"/?state=all&sort=-comments", "1 3 2"},
+		{"/?state=all&sort=bogus", "3 2 1"},
+		{"/milestones/1?state=all", "3 1"},
+		{"/users/alice?state=all", "3 1"},
+		{"/users/bob?state=all", "3 2"},
+	} {
+		status, body := get(t, ts, test.path)
+		if status != http.StatusOK {
+			t.Errorf("GET %s: status %d", test.path, status)
+			continue
+		}
+		if got := listed(body); got != test.want {
+			t.Errorf("GET %s listed %q, want %q", test.path, got, test.want)
+		}
+	}
+
+	// Sorting again by the same column reverses the order.
+	_, body := get(t, ts, "/?sort=title")
+	if !strings.Contains(body, `href="?sort=-title&amp;state=open"`) {
+		t.Errorf("sorted page lacks a link to reverse the order")
+	}
+}
+
+func TestPages(t *testing.T) {
+	ts, gh, _ := newTestServer(t)
+	if _, err := gh.Client().AddComment(context.Background(), "a/b", 1, "Seen <b>twice</b>."); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		path   string
+		status int
+		want   string
+	}{
+		{"/issues/1", 200, "Seen &lt;b&gt;twice&lt;/b&gt;."},
+		{"/issues/2", 200, "&lt;script&gt;alert(1)&lt;/script&gt;"},
+		{"/issues/3", 200, `<a href="/milestones/1">v1</a>`},
+		{"/milestones/", 200, "<td>1</td>\n  <td>1</td>\n  <td>50%</td>"},
+		{"/users/", 200, `<a href="/users/bob?state=all">bob</a></td>
+  <td>1</td>
+  <td>1</td>
+  <td>0</td>`},
+		{"/issues/4", 404, ""},
+		{"/issues/x", 404, ""},
+		{"/milestones/2", 404, ""},
+		{"/users/carol", 404, ""},
+		{"/elsewhere", 404, ""},
+	} {
+		status, body := get(t, ts, test.path)
+		if status != test.status {
+			t.Errorf("GET %s: status %d, want %d", test.path, status, test.status)
+		}
+		if !strings.Contains(body, test.want) {
+			t.Errorf("GET %s: body lacks %q:\n%s", test.path, test.want, body)
+		}
+		if strings.Contains(body, "<script>") {
+			t.Errorf("GET %s: body contains an unescaped script", test.path)
+		}
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	ts, gh, c := newTestServer(t)
+	gh.AddIssue("a/b", &github.Issue{Title: "new", User: &github.User{Login: "carol"}})
+	gh.AddIssue("a/b", &github.Issue{Title: "fix", User: &github.User{Login: "dave"}, PullRequest: &github.PullRequestLinks{}})
+	if _, body := get(t, ts, "/"); listed(body) != "2 1" {
+		t.Fatalf("new issue listed before refresh")
+	}
+	if err := c.refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, body := get(t, ts, "/"); listed(body) != "4 2 1" {
+		t.Errorf("after refresh, listed %q", listed(body))
+	}
+	if status, _ := get(t, ts, "/users/carol"); status != http.StatusOK {
+		t.Errorf("after refresh, new user's page has status %d", status)
+	}
+	// Pull requests are not issues.
+	if status, _ := get(t, ts, "/issues/5"); status != http.StatusNotFound {
+		t.Errorf("pull request's page has status %d", status)
+	}
+	if status, _ := get(t, ts, "/users/dave"); status != http.StatusNotFound {
+		t.Errorf("pull request author's page has status %d", status)
+	}
+
+	// A failed refresh keeps the old issues, and says so.
+	gh.Token = "secret"
+	if err := c.refresh(context.Background()); err == nil {
+		t.Fatal("refresh without a token succeeded")
+	}
+	_, body := get(t, ts, "/")
+	if listed(body) != "4 2 1" || !strings.Contains(body, "refresh failed") {
+		t.Errorf("after failed refresh, listed %q:\n%s", listed(body), body)
+	}
+}
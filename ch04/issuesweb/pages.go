@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopl.io/ch04/github"
+)
+
+// A server serves pages about the repository held in its cache.
+type server struct {
+	cache *cache
+}
+
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.index)
+	mux.HandleFunc("/issues/", s.issue)
+	mux.HandleFunc("/milestones/", s.milestone)
+	mux.HandleFunc("/users/", s.user)
+	return mux
+}
+
+// page holds the data common to every page.
+type page struct {
+	*snapshot
+	Title string
+	Err   error // of the latest refresh
+}
+
+// A listPage is a page showing a filtered list of issues.
+type listPage struct {
+	page
+	Filter filter
+	List   []*github.Issue
+}
+
+// newPage returns the page data for the latest snapshot.  If there
+// is none, it replies with an error and returns false.
+func (s *server) newPage(w http.ResponseWriter, title string) (page, bool) {
+	snap, err := s.cache.get()
+	if snap == nil {
+		msg := "not yet loaded"
+		if err != nil {
+			msg = err.Error()
+		}
+		http.Error(w, "issues unavailable: "+msg, http.StatusServiceUnavailable)
+		return page{}, false
+	}
+	return page{snap, title, err}, true
+}
+
+// newListPage returns a listPage of those of issues selected by the
+// filter in the query of req.
+func newListPage(p page, req *http.Request, issues []*github.Issue) listPage {
+	f := parseFilter(req.URL.Query())
+	return listPage{p, f, f.apply(issues, time.Now())}
+}
+
+// index serves the list of all issues at "/".
+func (s *server) index(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		http.NotFound(w, req)
+		return
+	}
+	p, ok := s.newPage(w, "Issues")
+	if !ok {
+		return
+	}
+	render(w, "index", newListPage(p, req, p.Issues))
+}
+
+// issue serves the page of one issue at "/issues/N".  Its comments
+// are fetched when requested, not cached.
+func (s *server) issue(w http.ResponseWriter, req *http.Request) {
+	p, ok := s.newPage(w, "")
+	if !ok {
+		return
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(req.URL.Path, "/issues/"))
+	issue := p.issues[n]
+	if err != nil || issue == nil {
+		http.NotFound(w, req)
+		return
+	}
+	data := struct {
+		page
+		Issue       *github.Issue
+		Comments    []*github.Comment
+		CommentsErr error
+	}{page: p, Issue: issue}
+	data.Title = "#" + strconv.Itoa(n) + " " + issue.Title
+	if issue.Comments > 0 {
+		data.Comments, data.CommentsErr = s.cache.client.Comments(req.Context(), p.Repo, n)
+	}
+	render(w, "issue", data)
+}
+
+// A milestoneRow summarizes a milestone.
+type milestoneRow struct {
+	*github.Milestone
+	Open, Closed int // numbers of issues
+}
+
+func (r milestoneRow) Percent() int {
+	if r.Open+r.Closed == 0 {
+		return 0
+	}
+	return 100 * r.Closed / (r.Open + r.Closed)
+}
+
+func (snap *snapshot) milestoneRow(m *github.Milestone) milestoneRow {
+	r := milestoneRow{Milestone: m}
+	for _, issue := range snap.Issues {
+		if issue.Milestone != nil && issue.Milestone.Number == m.Number {
+			if issue.State == "open" {
+				r.Open++
+			} else {
+				r.Closed++
+			}
+		}
+	}
+	return r
+}
+
+// milestone serves the list of milestones at "/milestones/", and
+// the page of one milestone, with its issues, at "/milestones/N".
+func (s *server) milestone(w http.ResponseWriter, req *http.Request) {
+	p, ok := s.newPage(w, "Milestones")
+	if !ok {
+		return
+	}
+	rest := strings.TrimPrefix(req.URL.Path, "/milestones/")
+	if rest == "" {
+		var rows []milestoneRow
+		for _, m := range p.Milestones {
+			rows = append(rows, p.milestoneRow(m))
+		}
+		render(w, "milestones", struct {
+			page
+			Rows []milestoneRow
+		}{p, rows})
+		return
+	}
+	n, err := strconv.Atoi(rest)
+	m := p.milestones[n]
+	if err != nil || m == nil {
+		http.NotFound(w, req)
+		return
+	}
+	var issues []*github.Issue
+	for _, issue := range p.Issues {
+		if issue.Milestone != nil && issue.Milestone.Number == n {
+			issues = append(issues, issue)
+		}
+	}
+	p.Title = "Milestone " + m.Title
+	render(w, "milestone", struct {
+		listPage
+		Row milestoneRow
+	}{newListPage(p, req, issues), p.milestoneRow(m)})
+}
+
+// A userRow summarizes the issues of a user.
+type userRow struct {
+	*github.User
+	Authored, Assigned, Open int // numbers of issues
+}
+
+// user serves the list of users at "/users/", and the page of one
+// user, with the issues they opened or are assigned, at
+// "/users/LOGIN".
+func (s *server) user(w http.ResponseWriter, req *http.Request) {
+	p, ok := s.newPage(w, "Users")
+	if !ok {
+		return
+	}
+	login := strings.TrimPrefix(req.URL.Path, "/users/")
+	if login == "" {
+		rows := make([]userRow, len(p.Users))
+		index := make(map[string]*userRow)
+		for i, u := range p.Users {
+			rows[i].User = u
+			index[u.Login] = &rows[i]
+		}
+		for _, issue := range p.Issues {
+			if issue.User != nil {
+				index[issue.User.Login].Authored++
+			}
+			for _, u := range issue.Assignees {
+				r := index[u.Login]
+				r.Assigned++
+				if issue.State == "open" {
+					r.Open++
+				}
+			}
+		}
+		render(w, "users", struct {
+			page
+			Rows []userRow
+		}{p, rows})
+		return
+	}
+	u := p.users[login]
+	if u == nil {
+		http.NotFound(w, req)
+		return
+	}
+	var issues []*github.Issue
+	for _, issue := range p.Issues {
+		if involves(issue, login) {
+			issues = append(issues, issue)
+		}
+	}
+	p.Title = login
+	render(w, "user", struct {
+		listPage
+		User *github.User
+	}{newListPage(p, req, issues), u})
+}
+
+// involves reports whether the user login opened or is assigned issue.
+func involves(issue *github.Issue, login string) bool {
+	if issue.User != nil && issue.User.Login == login {
+		return true
+	}
+	for _, u := range issue.Assignees {
+		if u.Login == login {
+			return true
+		}
+	}
+	return false
+}
+
+// render executes the named template with data and writes the page.
+// It executes the template in full before writing anything, so that
+// an error results in a clean 500 response.
+func render(w http.ResponseWriter, name string, data interface{}) {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, name, data); err != nil {
+		log.Printf("executing %s: %v", name, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	buf.WriteTo(w)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"html/template"
+	"time"
+)
+
+// The templates rely on html/template to escape the text of issues,
+// which is written by anyone; see gopl.io/ch04/autoescape.
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"date": func(t time.Time) string { return t.Format("2006-01-02") },
+	"days": func(t time.Time) int { return int(time.Since(t) / day) },
+}).Parse(`
+{{define "header"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} · {{.Repo}}</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { padding: 2px 8px; text-align: left; }
+tr:nth-child(even) { background: #f4f4f4; }
+.label { background: #ddd; border-radius: 4px; padding: 0 4px; font-size: small; }
+.closed { color: #888; }
+</style>
+</head>
+<body>
+<p><b>{{.Repo}}</b> · <a href="/">Issues</a> · <a href="/milestones/">Milestones</a> · <a href="/users/">Users</a>
+<small>· fetched {{.Fetched.Format "15:04:05"}}{{if .Err}} · refresh failed: {{.Err}}{{end}}</small></p>
+<h1>{{.Title}}</h1>
+{{end}}
+
+{{define "footer"}}</body>
+</html>
+{{end}}
+
+{{define "labels"}}{{range .}} <a class="label" href="/?state=all&amp;label={{.Name}}">{{.Name}}</a>{{end}}{{end}}
+
+{{define "list"}}
+<form>
+<select name="state">
+  <option value="open"{{if eq .Filter.State "open"}} selected{{end}}>open</option>
+  <option value="closed"{{if eq .Filter.State "closed"}} selected{{end}}>closed</option>
+  <option value="all"{{if eq .Filter.State "all"}} selected{{end}}>all</option>
+</select>
+<select name="label">
+  <option value="">any label</option>
+  {{- range .Labels}}
+  <option{{if eq . $.Filter.Label}} selected{{end}}>{{.}}</option>
+  {{- end}}
+</select>
+<select name="age">
+  <option value="">any age</option>
+  <option value="week"{{if eq .Filter.Age "week"}} selected{{end}}>less than a week old</option>
+  <option value="month"{{if eq .Filter.Age "month"}} selected{{end}}>less than a month old</option>
+  <option value="year"{{if eq .Filter.Age "year"}} selected{{end}}>less than a year old</option>
+  <option value="older"{{if eq .Filter.Age "older"}} selected{{end}}>more than a year old</option>
+</select>
+<input type="hidden" name="sort" value="{{.Filter.Sort}}">
+<input type="submit" value="Filter">
+</form>
+<p>{{len .List}} issues</p>
+<table>
+<tr>
+  <th><a href="{{.Filter.SortBy "number"}}">#</a>{{.Filter.Arrow "number"}}</th>
+  <th>State</th>
+  <th>User</th>
+  <th><a href="{{.Filter.SortBy "title"}}">Title</a>{{.Filter.Arrow "title"}}</th>
+  <th><a href="{{.Filter.SortBy "created"}}">Age (days)</a>{{.Filter.Arrow "created"}}</th>
+  <th><a href="{{.Filter.SortBy "updated"}}">Updated</a>{{.Filter.Arrow "updated"}}</th>
+  <th><a href="{{.Filter.SortBy "comments"}}">Comments</a>{{.Filter.Arrow "comments"}}</th>
+</tr>
+{{range .List}}
+<tr{{if eq .State "closed"}} class="closed"{{end}}>
+  <td><a href="/issues/{{.Number}}">{{.Number}}</a></td>
+  <td>{{.State}}</td>
+  <td>{{with .User}}<a href="/users/{{.Login}}">{{.Login}}</a>{{end}}</td>
+  <td><a href="/issues/{{.Number}}">{{.Title}}</a>{{template "labels" .Labels}}</td>
+  <td>{{days .CreatedAt}}</td>
+  <td>{{date .UpdatedAt}}</td>
+  <td>{{.Comments}}</td>
+</tr>
+{{end}}
+</table>
+{{end}}
+
+{{define "index"}}{{template "header" .}}{{template "list" .}}{{template "footer" .}}{{end}}
+
+{{define "issue"}}{{template "header" .}}
+{{with .Issue}}
+<p><b>{{.State}}</b>
+{{with .User}}opened by <a href="/users/{{.Login}}">{{.Login}}</a>{{end}}
+on {{date .CreatedAt}}{{with .ClosedAt}}, closed on {{date .}}{{end}}
+· <a href="{{.HTMLURL}}">view on GitHub</a></p>
+<p>Labels:{{template "labels" .Labels}}</p>
+<p>Assignees:{{range .Assignees}} <a href="/users/{{.Login}}">{{.Login}}</a>{{end}}</p>
+{{with .Milestone}}<p>Milestone: <a href="/milestones/{{.Number}}">{{.Title}}</a></p>{{end}}
+<pre style="white-space: pre-wrap">{{.Body}}</pre>
+{{end}}
+{{if .CommentsErr}}<p>Comments unavailable: {{.CommentsErr}}</p>{{end}}
+{{range .Comments}}
+<hr>
+<p>{{with .User}}<a href="/users/{{.Login}}">{{.Login}}</a>{{end}} on {{date .CreatedAt}}:</p>
+<pre style="white-space: pre-wrap">{{.Body}}</pre>
+{{end}}
+{{template "footer" .}}{{end}}
+
+{{define "milestones"}}{{template "header" .}}
+<table>
+<tr><th>#</th><th>Title</th><th>State</th><th>Due</th><th>Open</th><th>Closed</th><th>Done</th></tr>
+{{range .Rows}}
+<tr{{if eq .State "closed"}} class="closed"{{end}}>
+  <td>{{.Number}}</td>
+  <td><a href="/milestones/{{.Number}}?state=all">{{.Title}}</a></td>
+  <td>{{.State}}</td>
+  <td>{{with .DueOn}}{{date .}}{{end}}</td>
+  <td>{{.Open}}</td>
+  <td>{{.Closed}}</td>
+  <td>{{.Percent}}%</td>
+</tr>
+{{end}}
+</table>
+{{template "footer" .}}{{end}}
+
+{{define "milestone"}}{{template "header" .}}
+{{with .Row}}
+<p><b>{{.State}}</b>{{with .DueOn}}, due {{date .}}{{end}} ·
+{{.Open}} open, {{.Closed}} closed ({{.Percent}}% done)</p>
+{{with .Description}}<p>{{.}}</p>{{end}}
+{{end}}
+{{template "list" .}}
+{{template "footer" .}}{{end}}
+
+{{define "users"}}{{template "header" .}}
+<table>
+<tr><th>User</th><th>Opened</th><th>Assigned</th><th>Assigned and open</th></tr>
+{{range .Rows}}
+<tr>
+  <td><a href="/users/{{.Login}}?state=all">{{.Login}}</a></td>
+  <td>{{.Authored}}</td>
+  <td>{{.Assigned}}</td>
+  <td>{{.Open}}</td>
+</tr>
+{{end}}
+</table>
+{{template "footer" .}}{{end}}
+
+{{define "user"}}{{template "header" .}}
+<p>Issues opened by or assigned to {{.User.Login}} · <a href="{{.User.HTMLURL}}">profile on GitHub</a></p>
+{{template "list" .}}
+{{template "footer" .}}{{end}}
+`))
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"gopl.io/ch04/github"
+)
+
+// A form is the editable text of an issue.
+type form struct {
+	title     string
+	labels    []string
+	assignees []string
+	milestone int // number, or 0 for none
+	body      string
+}
+
+const formHelp = `# Edit the fields below, and the body after the blank line.
+# Lists are separated by commas; the milestone is its number.
+# Lines starting with '#' are ignored.  An empty title abandons the edit.
+`
+
+const commentHelp = `
+# Write your comment above.  These lines are removed;
+# an empty comment abandons it.
+`
+
+func formOf(issue *github.Issue) *form {
+	f := &form{title: issue.Title, body: issue.Body}
+	for _, l := range issue.Labels {
+		f.labels = append(f.labels, l.Name)
+	}
+	for _, u := range issue.Assignees {
+		f.assignees = append(f.assignees, u.Login)
+	}
+	if issue.Milestone != nil {
+		f.milestone = issue.Milestone.Number
+	}
+	return f
+}
+
+// String returns the text of the form.
+func (f *form) String() string {
+	milestone := ""
+	if f.milestone != 0 {
+		milestone = strconv.Itoa(f.milestone)
+	}
+	return fmt.Sprintf("%sTitle: %s\nLabels: %s\nAssignees: %s\nMilestone: %s\n\n%s",
+		formHelp, f.title, strings.Join(f.labels, ", "),
+		strings.Join(f.assignees, ", "), milestone, f.body)
+}
+
+// parseForm parses the text of a form.
+func parseForm(text string) (*form, error) {
+	f := new(form)
+	lines := strings.Split(text, "\n")
+	for len(lines) > 0 {
+		line := lines[0]
+		lines = lines[1:]
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			break // end of header
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("header line %q has no colon", line)
+		}
+		key, value := strings.ToLower(strings.TrimSpace(line[:i])), strings.TrimSpace(line[i+1:])
+		switch key {
+		case "title":
+			f.title = value
+		case "labels":
+			f.labels = list(value)
+		case "assignees":
+			f.assignees = list(value)
+		case "milestone":
+			if value != "" {
+				n, err := strconv.Atoi(strings.TrimPrefix(value, "#"))
+				if err != nil || n <= 0 {
+					return nil, fmt.Errorf("invalid milestone number %q", value)
+				}
+				f.milestone = n
+			}
+		default:
+			return nil, fmt.Errorf("unknown field %q", line[:i])
+		}
+	}
+	f.body = strings.TrimSpace(strings.Join(lines, "\n"))
+	return f, nil
+}
+
+// list splits a comma-separated list.
+func list(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// changes returns the edit that changes old into f.
+// (A milestone cannot be removed.)
+func (f *form) changes(old *form) *github.IssueEdit {
+	edit := new(github.IssueEdit)
+	if f.title != old.title {
+		edit.Title = f.title
+	}
+	if f.body != strings.TrimSpace(old.body) {
+		edit.Body = &f.body
+	}
+	// An empty list is sent as [], not null, which would leave
+	// the labels or assignees unchanged.
+	if strings.Join(f.labels, ",") != strings.Join(old.labels, ",") {
+		labels := append([]string{}, f.labels...)
+		edit.Labels = &labels
+	}
+	if strings.Join(f.assignees, ",") != strings.Join(old.assignees, ",") {
+		assignees := append([]string{}, f.assignees...)
+		edit.Assignees = &assignees
+	}
+	if f.milestone != old.milestone {
+		edit.Milestone = f.milestone
+	}
+	return edit
+}
+
+// editForm lets the user edit f, and returns the result.
+func editForm(f *form) (*form, error) {
+	text, err := editText(f.String())
+	if err != nil {
+		return nil, err
+	}
+	g, err := parseForm(text)
+	if err != nil {
+		return nil, err
+	}
+	if g.title == "" {
+		return nil, fmt.Errorf("empty title; abandoned")
+	}
+	return g, nil
+}
+
+// stripComments removes from text the lines of help starting with
+// '#', leaving any other lines, such as Markdown headings, alone.
+func stripComments(text, help string) string {
+	comment := make(map[string]bool)
+	for _, line := range strings.Split(help, "\n") {
+		if strings.HasPrefix(line, "#") {
+			comment[line] = true
+		}
+	}
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if !comment[strings.TrimRight(line, " \t\r")] {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// editText lets the user edit text in a temporary file, using
+// editFile, and returns the result.
+func editText(text string) (string, error) {
+	f, err := ioutil.TempFile("", "issue*.md")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(text)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := editFile(f.Name()); err != nil {
+		return "", err
+	}
+	data, err := ioutil.ReadFile(f.Name())
+	return string(data), err
+}
+
+// editFile lets the user edit the named file.  Tests replace it.
+var editFile = func(name string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	args := strings.Fields(editor) // for editors such as "code --wait"
+	cmd := exec.Command(args[0], append(args[1:], name)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running editor: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+// Issuetool creates, reads, edits and closes GitHub issues
+// (see exercise 4.11).
+//
+//	$ issuetool -repo owner/name create
+//	$ issuetool -repo owner/name read 123
+//	$ issuetool -repo owner/name edit 123
+//	$ issuetool -repo owner/name comment 123
+//	$ issuetool -repo owner/name close 123
+//	$ issuetool -repo owner/name reopen 123
+//
+// The create, edit and comment commands open the user's preferred
+// text editor ($VISUAL or $EDITOR, else vi) on a temporary file.
+// An issue appears as a header of fields (title, labels, assignees
+// and milestone number), a blank line, and the body.  Saving a file
+// without a title, or an empty comment, abandons the command.
+//
+// The access token is taken from $GITHUB_TOKEN.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopl.io/ch04/github"
+)
+
+var (
+	repo = flag.String("repo", "", "the repository, `owner/name`")
+	api  = flag.String("api", github.DefaultBaseURL, "base `URL` of the GitHub API")
+)
+
+const usage = `usage: issuetool -repo owner/name command [number]
+commands: create, read N, edit N, comment N, close N, reopen N
+`
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *repo == "" || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	c := &github.Client{BaseURL: *api, Token: os.Getenv("GITHUB_TOKEN")}
+	if err := run(context.Background(), c, *repo, flag.Args(), os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "issuetool: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run performs the command in args on the issues of repo.
+func run(ctx context.Context, c *github.Client, repo string, args []string, out io.Writer) error {
+	cmd := args[0]
+	var number int
+	if cmd != "create" {
+		if len(args) != 2 {
+			return fmt.Errorf("%s needs an issue number", cmd)
+		}
+		var err error
+		if number, err = strconv.Atoi(strings.TrimPrefix(args[1], "#")); err != nil {
+			return fmt.Errorf("invalid issue number %q", args[1])
+		}
+	} else if len(args) != 1 {
+		return fmt.Errorf("create takes no arguments")
+	}
+
+	switch cmd {
+	case "create":
+		f, err := editForm(new(form))
+		if err != nil {
+			return err
+		}
+		issue, err := c.CreateIssue(ctx, repo, f.changes(new(form)))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "created #%d %s\n", issue.Number, issue.HTMLURL)
+
+	case "read":
+		issue, err := c.GetIssue(ctx, repo, number)
+		if err != nil {
+			return err
+		}
+		comments, err := c.Comments(ctx, repo, number)
+		if err != nil {
+			return err
+		}
+		printIssue(out, issue, comments)
+
+	case "edit":
+		issue, err := c.GetIssue(ctx, repo, number)
+		if err != nil {
+			return err
+		}
+		old := formOf(issue)
+		f, err := editForm(old)
+		if err != nil {
+			return err
+		}
+		edit := f.changes(old)
+		if *edit == (github.IssueEdit{}) {
+			fmt.Fprintf(out, "#%d unchanged\n", number)
+			return nil
+		}
+		if _, err := c.EditIssue(ctx, repo, number, edit); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "updated #%d\n", number)
+
+	case "comment":
+		text, err := editText(commentHelp)
+		if err != nil {
+			return err
+		}
+		text = strings.TrimSpace(stripComments(text, commentHelp))
+		if text == "" {
+			return fmt.Errorf("empty comment; abandoned")
+		}
+		if _, err := c.AddComment(ctx, repo, number, text); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "commented on #%d\n", number)
+
+	case "close", "reopen":
+		state := map[string]string{"close": "closed", "reopen": "open"}[cmd]
+		if _, err := c.EditIssue(ctx, repo, number, &github.IssueEdit{State: state}); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "#%d %s\n", number, state)
+
+	default:
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+	return nil
+}
+
+// printIssue prints an issue and its comments.
+func printIssue(out io.Writer, issue *github.Issue, comments []*github.Comment) {
+	fmt.Fprintf(out, "#%d %s [%s]\n", issue.Number, issue.Title, issue.State)
+	fmt.Fprintf(out, "%s\n", issue.HTMLURL)
+	if issue.User != nil {
+		fmt.Fprintf(out, "Author:    %s\n", issue.User.Login)
+	}
+	fmt.Fprintf(out, "Created:   %s\n", issue.CreatedAt.Format("2006-01-02 15:04"))
+	if issue.ClosedAt != nil {
+		fmt.Fprintf(out, "Closed:    %s\n", issue.ClosedAt.Format("2006-01-02 15:04"))
+	}
+	f := formOf(issue)
+	if len(f.labels) > 0 {
+		fmt.Fprintf(out, "Labels:    %s\n", strings.Join(f.labels, ", "))
+	}
+	if len(f.assignees) > 0 {
+		fmt.Fprintf(out, "Assignees: %s\n", strings.Join(f.assignees, ", "))
+	}
+	if issue.Milestone != nil {
+		fmt.Fprintf(out, "Milestone: %s\n", issue.Milestone.Title)
+	}
+	if issue.Body != "" {
+		fmt.Fprintf(out, "\n%s\n", strings.TrimSpace(issue.Body))
+	}
+	for _, c := range comments {
+		login := "?"
+		if c.User != nil {
+			login = c.User.Login
+		}
+		fmt.Fprintf(out, "\n--- %s, %s\n%s\n", login, c.CreatedAt.Format("2006-01-02 15:04"),
+			strings.TrimSpace(c.Body))
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"gopl.io/ch04/github"
+	"gopl.io/ch04/github/githubtest"
+)
+
+// editWith makes the editor replace the text of the file by calling
+// f, and records the text it was given.
+func editWith(f func(text string) string) *string {
+	var given string
+	editFile = func(name string) error {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		given = string(data)
+		return ioutil.WriteFile(name, []byte(f(given)), 0600)
+	}
+	return &given
+}
+
+func TestCommands(t *testing.T) {
+	s := githubtest.NewServer()
+	defer s.Close()
+	s.AddRepo("a/b")
+	s.AddMilestone("a/b", &github.Milestone{Title: "v1"})
+	c := s.Client()
+	ctx := context.Background()
+
+	do := func(args ...string) string {
+		t.Helper()
+		var out bytes.Buffer
+		if err := run(ctx, c, "a/b", args, &out); err != nil {
+			t.Fatalf("%s: %v", strings.Join(args, " "), err)
+		}
+		return out.String()
+	}
+
+	editWith(func(string) string {
+		return "Title: crash on startup\nLabels: bug, p1\nMilestone: 1\n\nIt crashes.\n"
+	})
+	if out := do("create"); !strings.HasPrefix(out, "created #1 ") {
+		t.Errorf("create printed %q", out)
+	}
+
+	// The edit form shows the issue as it is.
+	given := editWith(func(text string) string {
+		text = strings.Replace(text, "Assignees: ", "Assignees: alice", 1)
+		return strings.Replace(text, "It crashes.", "It crashes at once.", 1)
+	})
+	if out := do("edit", "1"); out != "updated #1\n" {
+		t.Errorf("edit printed %q", out)
+	}
+	for _, want := range []string{"Title: crash on startup\n", "Labels: bug, p1\n", "Milestone: 1\n", "\n\nIt crashes."} {
+		if !strings.Contains(*given, want) {
+			t.Errorf("edit form %q lacks %q", *given, want)
+		}
+	}
+
+	editWith(func(text string) string { return text })
+	if out := do("edit", "1"); out != "#1 unchanged\n" {
+		t.Errorf("edit without changes printed %q", out)
+	}
+
+	editWith(func(text string) string { return "Looks bad.\n# Steps\n" + text })
+	do("comment", "1")
+	do("close", "#1")
+
+	out := do("read", "1")
+	for _, want := range []string{
+		"#1 crash on startup [closed]\n",
+		"Labels:    bug, p1\n",
+		"Assignees: alice\n",
+		"Milestone: v1\n",
+		"\nIt crashes at once.\n",
+		"\n--- gopher, ",
+		"\nLooks bad.\n# Steps\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("read printed %q, which lacks %q", out, want)
+		}
+	}
+	do("reopen", "1")
+	if issue, _ := c.GetIssue(ctx, "a/b", 1); issue.State != "open" {
+		t.Errorf("after reopen, state is %s", issue.State)
+	}
+
+	// Emptying a list removes all its items.
+	editWith(func(text string) string {
+		text = strings.Replace(text, "Labels: bug, p1", "Labels:", 1)
+		return strings.Replace(text, "Assignees: alice", "Assignees:", 1)
+	})
+	do("edit", "1")
+	if issue, _ := c.GetIssue(ctx, "a/b", 1); len(issue.Labels) != 0 || len(issue.Assignees) != 0 {
+		t.Errorf("after emptying lists, %d labels and %d assignees remain", len(issue.Labels), len(issue.Assignees))
+	}
+
+	// Errors.
+	editWith(func(string) string { return "Title:\n\nno title" })
+	for _, args := range [][]string{
+		{"create"},
+		{"read"},
+		{"read", "x"},
+		{"read", "2"},
+		{"frob", "1"},
+	} {
+		if err := run(ctx, c, "a/b", args, ioutil.Discard); err == nil {
+			t.Errorf("%s succeeded", strings.Join(args, " "))
+		}
+	}
+}
+
+func TestParseForm(t *testing.T) {
+	f, err := parseForm(formHelp + "Title: t\nLabels: a,, b \nAssignees:\nMilestone: #2\n\n\nbody\n\nmore\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.title != "t" || strings.Join(f.labels, "|") != "a|b" || f.assignees != nil ||
+		f.milestone != 2 || f.body != "body\n\nmore" {
+		t.Errorf("parseForm returned %+v", f)
+	}
+	for _, bad := range []string{"Title\n", "Colour: red\n", "Milestone: soon\n"} {
+		if _, err := parseForm(bad); err == nil {
+			t.Errorf("parseForm(%q) succeeded", bad)
+		}
+	}
+}
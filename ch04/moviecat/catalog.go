@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Movie is a movie in the catalogue, in the JSON form of
+// gopl.io/ch04/movie, with the URL of its poster.
+type Movie struct {
+	Title  string
+	Year   int  `json:"released"`
+	Color  bool `json:"color,omitempty"`
+	Actors []string
+	Poster string `json:"poster,omitempty"` // URL of the poster image
+}
+
+func (m *Movie) String() string { return fmt.Sprintf("%s (%d)", m.Title, m.Year) }
+
+// key identifies a movie: two records with the same title and year
+// are taken to be the same movie.
+func (m *Movie) key() string { return strings.ToLower(m.Title) + "\x00" + strconv.Itoa(m.Year) }
+
+// A Catalog is a set of movies and an index of them, which are saved
+// together in a JSON file so that searching needs no network.
+type Catalog struct {
+	Movies []*Movie `json:"movies"`
+	Index  *Index   `json:"index"`
+}
+
+// Load reads the catalogue saved in the named file.  If the file
+// does not exist, the catalogue is empty.
+func Load(name string) (*Catalog, error) {
+	c := &Catalog{Index: newIndex(nil)}
+	data, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return c, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	if c.Index == nil {
+		c.Index = newIndex(c.Movies)
+	}
+	return c, nil
+}
+
+// Save writes the catalogue to the named file, replacing it
+// atomically.
+func (c *Catalog) Save(name string) error {
+	data, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(name), ".moviecat")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// Add adds movies to the catalogue, replacing those it holds already,
+// and reindexes it.  It returns the number of movies that were new.
+func (c *Catalog) Add(movies ...*Movie) int {
+	pos := make(map[string]int)
+	for i, m := range c.Movies {
+		pos[m.key()] = i
+	}
+	added := 0
+	for _, m := range movies {
+		if i, ok := pos[m.key()]; ok {
+			c.Movies[i] = m
+			continue
+		}
+		pos[m.key()] = len(c.Movies)
+		c.Movies = append(c.Movies, m)
+		added++
+	}
+	c.Index = newIndex(c.Movies)
+	return added
+}
+
+// Search returns the movies that match query, in order of year and
+// then title.  An empty query matches every movie.
+func (c *Catalog) Search(query string) ([]*Movie, error) {
+	terms, err := parseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	if len(terms) == 0 {
+		for id := range c.Movies {
+			ids = append(ids, id)
+		}
+	}
+	for i, t := range terms {
+		matches := c.lookup(t)
+		if i == 0 {
+			ids = matches
+		} else {
+			ids = intersect(ids, matches)
+		}
+	}
+
+	movies := make([]*Movie, len(ids))
+	for i, id := range ids {
+		movies[i] = c.Movies[id]
+	}
+	sort.SliceStable(movies, func(i, j int) bool {
+		if movies[i].Year != movies[j].Year {
+			return movies[i].Year < movies[j].Year
+		}
+		return movies[i].Title < movies[j].Title
+	})
+	return movies, nil
+}
+
+// lookup returns the ids of the movies that match t.  A phrase is
+// looked up word by word in the index, and the movies that contain
+// every word are then checked for the words in sequence.
+func (c *Catalog) lookup(t term) []int {
+	if t.field == "year" {
+		return c.Index.years(t.from, t.to)
+	}
+	postings := c.Index.Title
+	if t.field == "actor" {
+		postings = c.Index.Actor
+	}
+	var ids []int
+	for i, w := range t.words {
+		if i == 0 {
+			ids = postings[w]
+		} else {
+			ids = intersect(ids, postings[w])
+		}
+	}
+	if len(t.words) == 1 {
+		return ids
+	}
+	var matches []int
+	for _, id := range ids {
+		m := c.Movies[id]
+		if t.field == "title" && hasPhrase(words(m.Title), t.words) {
+			matches = append(matches, id)
+		}
+		if t.field == "actor" {
+			for _, actor := range m.Actors {
+				if hasPhrase(words(actor), t.words) {
+					matches = append(matches, id)
+					break
+				}
+			}
+		}
+	}
+	return matches
+}
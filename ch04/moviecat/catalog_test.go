@@ -0,0 +1,108 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testMovies = []*Movie{
+	{Title: "Casablanca", Year: 1942, Actors: []string{"Humphrey Bogart", "Ingrid Bergman"}},
+	{Title: "Cool Hand Luke", Year: 1967, Color: true, Actors: []string{"Paul Newman"}},
+	{Title: "Bullitt", Year: 1968, Color: true, Actors: []string{"Steve McQueen", "Jacqueline Bisset"}},
+	{Title: "Butch Cassidy and the Sundance Kid", Year: 1969, Color: true,
+		Actors: []string{"Paul Newman", "Robert Redford"}},
+	{Title: "The Sting", Year: 1973, Color: true, Actors: []string{"Paul Newman", "Robert Redford"}},
+	{Title: "Hombre", Year: 1967, Color: true, Actors: []string{"Paul Newman", "Fredric March"}},
+	{Title: "Newman's Own", Year: 1990, Actors: []string{"Paul Smith"}},
+}
+
+func titles(movies []*Movie) string {
+	var list []string
+	for _, m := range movies {
+		list = append(list, m.Title)
+	}
+	return strings.Join(list, "; ")
+}
+
+func TestSearch(t *testing.T) {
+	c := &Catalog{}
+	c.Add(testMovies...)
+	for _, test := range []struct{ query, want string }{
+		{`actor:"Paul Newman" year:1967..1970`, "Cool Hand Luke; Hombre; Butch Cassidy and the Sundance Kid"},
+		{`actor:newman`, "Cool Hand Luke; Hombre; Butch Cassidy and the Sundance Kid; The Sting"},
+		{`actor:"Newman Paul"`, ""},
+		{`actor:"paul smith"`, "Newman's Own"},
+		{`newman`, "Newman's Own"},
+		{`"hand luke"`, "Cool Hand Luke"},
+		{`"luke hand"`, ""},
+		{`title:THE`, "Butch Cassidy and the Sundance Kid; The Sting"},
+		{`year:1967`, "Cool Hand Luke; Hombre"},
+		{`year:..1950`, "Casablanca"},
+		{`year:1973..`, "The Sting; Newman's Own"},
+		{`actor:redford actor:newman year:1970..`, "The Sting"},
+		{`casablanca year:1943..`, ""},
+		{`nosuchword`, ""},
+		{``, "Casablanca; Cool Hand Luke; Hombre; Bullitt; Butch Cassidy and the Sundance Kid; The Sting; Newman's Own"},
+	} {
+		movies, err := c.Search(test.query)
+		if err != nil {
+			t.Errorf("Search(%s): %v", test.query, err)
+			continue
+		}
+		if got := titles(movies); got != test.want {
+			t.Errorf("Search(%s) = %q, want %q", test.query, got, test.want)
+		}
+	}
+}
+
+func TestBadQueries(t *testing.T) {
+	for _, query := range []string{
+		`actor:"Paul Newman`,
+		`director:hitchcock`,
+		`year:1967..1960`,
+		`year:sixties`,
+		`year:..`,
+		`title:"!!"`,
+	} {
+		if _, err := parseQuery(query); err == nil {
+			t.Errorf("parseQuery(%s) succeeded", query)
+		}
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "movies.json")
+	c, err := Load(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := c.Add(testMovies[:3]...); n != 3 {
+		t.Errorf("Add added %d movies, want 3", n)
+	}
+	if err := c.Save(name); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = Load(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c.Movies, testMovies[:3]) {
+		t.Errorf("loaded %v", c.Movies)
+	}
+	if !reflect.DeepEqual(c.Index, newIndex(c.Movies)) {
+		t.Errorf("loaded index differs from a new one")
+	}
+
+	// Adding a movie again replaces it.
+	luke := *testMovies[1]
+	luke.Actors = append(luke.Actors, "George Kennedy")
+	if n := c.Add(&luke, testMovies[3]); n != 1 {
+		t.Errorf("Add added %d movies, want 1", n)
+	}
+	if movies, _ := c.Search("actor:kennedy"); titles(movies) != "Cool Hand Luke" || len(c.Movies) != 4 {
+		t.Errorf("after replacing, catalogue is %v", c.Movies)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// An Index is an inverted index of movies.  Each posting list holds
+// the positions in the catalogue of the movies containing a word (or
+// released in a year), in increasing order.
+type Index struct {
+	Title map[string][]int `json:"title"` // by word of the title
+	Actor map[string][]int `json:"actor"` // by word of an actor's name
+	Year  map[int][]int    `json:"year"`
+}
+
+func newIndex(movies []*Movie) *Index {
+	x := &Index{
+		Title: make(map[string][]int),
+		Actor: make(map[string][]int),
+		Year:  make(map[int][]int),
+	}
+	for id, m := range movies {
+		for _, w := range words(m.Title) {
+			x.Title[w] = post(x.Title[w], id)
+		}
+		for _, actor := range m.Actors {
+			for _, w := range words(actor) {
+				x.Actor[w] = post(x.Actor[w], id)
+			}
+		}
+		x.Year[m.Year] = post(x.Year[m.Year], id)
+	}
+	return x
+}
+
+// post adds id to the posting list ids, unless it is there already.
+// Ids are added in increasing order.
+func post(ids []int, id int) []int {
+	if len(ids) > 0 && ids[len(ids)-1] == id {
+		return ids
+	}
+	return append(ids, id)
+}
+
+// years returns the movies released in the years from through to.
+func (x *Index) years(from, to int) []int {
+	var ids []int
+	for year, list := range x.Year {
+		if from <= year && year <= to {
+			ids = append(ids, list...)
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// words returns the words of s, in lower case.
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// intersect returns the ids in both of the sorted lists a and b.
+func intersect(a, b []int) []int {
+	var ids []int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			ids = append(ids, a[i])
+			i++
+			j++
+		}
+	}
+	return ids
+}
+
+// hasPhrase reports whether the words of phrase appear in sequence
+// in text.
+func hasPhrase(text, phrase []string) bool {
+	for i := 0; i+len(phrase) <= len(text); i++ {
+		j := 0
+		for j < len(phrase) && text[i+j] == phrase[j] {
+			j++
+		}
+		if j == len(phrase) {
+			return true
+		}
+	}
+	return false
+}
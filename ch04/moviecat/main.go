@@ -0,0 +1,159 @@
+// Moviecat keeps an offline catalogue of movies, which it can search
+// without a network connection.
+//
+// The catalogue is a JSON file holding movies, in the form printed by
+// gopl.io/ch04/movie, and an inverted index of their titles, actors
+// and years.  Movies may be imported from such JSON files, or fetched
+// by title from the Open Movie Database, whose posters moviecat
+// downloads and caches on request.
+//
+//	$ moviecat import movies.json
+//	$ moviecat fetch "Cool Hand Luke" 1967
+//	$ moviecat search 'actor:"Paul Newman" year:1967..1970'
+//	$ moviecat poster 'cool hand luke'
+//
+// See parseQuery for the syntax of queries.  The access key of the
+// movie database is taken from $OMDB_API_KEY.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	db      = flag.String("db", "movies.json", "the catalogue `file`")
+	posters = flag.String("posters", "posters", "cache posters in `dir`")
+	apiURL  = flag.String("api", DefaultAPI, "base `URL` of the movie database")
+)
+
+const usage = `usage: moviecat [flags] command [args]
+commands:
+  import file...       add the movies in JSON files ("-" for stdin)
+  fetch title [year]   add a movie from the movie database
+  search [query]       list the movies that match the query
+  poster [query]       download the posters of the matching movies
+`
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cat := &catalogue{
+		file:    *db,
+		posters: *posters,
+		api:     &API{BaseURL: *apiURL, Key: os.Getenv("OMDB_API_KEY")},
+	}
+	if err := cat.run(context.Background(), flag.Args(), os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "moviecat: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// A catalogue holds the configuration of the commands.
+type catalogue struct {
+	file    string // of the Catalog
+	posters string // directory of cached posters
+	api     *API
+}
+
+// run performs the command in args.
+func (cat *catalogue) run(ctx context.Context, args []string, out io.Writer) error {
+	c, err := Load(cat.file)
+	if err != nil {
+		return err
+	}
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "import":
+		if len(args) == 0 {
+			return fmt.Errorf("import: no files")
+		}
+		var movies []*Movie
+		for _, name := range args {
+			ms, err := readMovies(name)
+			if err != nil {
+				return err
+			}
+			movies = append(movies, ms...)
+		}
+		n := c.Add(movies...)
+		fmt.Fprintf(out, "imported %d movies, %d new\n", len(movies), n)
+		return c.Save(cat.file)
+
+	case "fetch":
+		if len(args) == 0 || len(args) > 2 {
+			return fmt.Errorf("usage: fetch title [year]")
+		}
+		year := 0
+		if len(args) == 2 {
+			if year, err = strconv.Atoi(args[1]); err != nil {
+				return fmt.Errorf("fetch: bad year %q", args[1])
+			}
+		}
+		m, err := cat.api.Fetch(ctx, args[0], year)
+		if err != nil {
+			return err
+		}
+		c.Add(m)
+		fmt.Fprintf(out, "added %s\n", m)
+		return c.Save(cat.file)
+
+	case "search", "poster":
+		movies, err := c.Search(strings.Join(args, " "))
+		if err != nil {
+			return err
+		}
+		for _, m := range movies {
+			if cmd == "search" {
+				fmt.Fprintf(out, "%-40s %s\n", m, strings.Join(m.Actors, ", "))
+				continue
+			}
+			name, err := cat.api.Poster(ctx, cat.posters, m)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "%s\n", name)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown command %q", cmd)
+}
+
+// readMovies returns the movies in the named JSON file, which holds
+// an array of them, or, if name is "-", in the standard input.
+func readMovies(name string) ([]*Movie, error) {
+	var data []byte
+	var err error
+	if name == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var movies []*Movie
+	if err := json.Unmarshal(data, &movies); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	for _, m := range movies {
+		if m.Title == "" {
+			return nil, fmt.Errorf("%s: movie without a title", name)
+		}
+	}
+	return movies, nil
+}
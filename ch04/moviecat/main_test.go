@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newStub returns a stub of the movie database that knows one movie,
+// and a count of the posters it has served.
+func newStub(t *testing.T) (*httptest.Server, *int) {
+	posters := 0
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		q := req.URL.Query()
+		switch {
+		case req.URL.Path == "/posters/luke.jpg":
+			posters++
+			w.Write([]byte("JPEG"))
+		case req.URL.Path != "/" || q.Get("apikey") != "key":
+			http.NotFound(w, req)
+		case strings.EqualFold(q.Get("t"), "cool hand luke") && (q.Get("y") == "" || q.Get("y") == "1967"):
+			fmt.Fprintf(w, `{"Title":"Cool Hand Luke","Year":"1967","Actors":"Paul Newman, George Kennedy, J.D. Cannon",`+
+				`"Poster":"%s/posters/luke.jpg","Response":"True"}`, ts.URL)
+		default:
+			w.Write([]byte(`{"Response":"False","Error":"Movie not found!"}`))
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &posters
+}
+
+func TestCommands(t *testing.T) {
+	ts, posters := newStub(t)
+	dir := t.TempDir()
+	cat := &catalogue{
+		file:    filepath.Join(dir, "movies.json"),
+		posters: filepath.Join(dir, "posters"),
+		api:     &API{BaseURL: ts.URL + "/", Key: "key"},
+	}
+	ctx := context.Background()
+	do := func(args ...string) string {
+		t.Helper()
+		var out bytes.Buffer
+		if err := cat.run(ctx, args, &out); err != nil {
+			t.Fatalf("%s: %v", strings.Join(args, " "), err)
+		}
+		return out.String()
+	}
+
+	data, err := json.Marshal(testMovies[:3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	imports := filepath.Join(dir, "import.json")
+	if err := ioutil.WriteFile(imports, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out := do("import", imports); out != "imported 3 movies, 3 new\n" {
+		t.Errorf("import printed %q", out)
+	}
+
+	// Fetching replaces the imported record, adding its poster.
+	if out := do("fetch", "cool hand luke", "1967"); out != "added Cool Hand Luke (1967)\n" {
+		t.Errorf("fetch printed %q", out)
+	}
+	if out := do("search", "actor:kennedy"); !strings.HasPrefix(out, "Cool Hand Luke (1967)") {
+		t.Errorf("search printed %q", out)
+	}
+	var out bytes.Buffer
+	if err := cat.run(ctx, []string{"fetch", "Vertigo"}, &out); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("fetching a missing movie: %v", err)
+	}
+
+	// Posters are downloaded once.
+	want := filepath.Join(cat.posters, "cool-hand-luke-1967.jpg") + "\n"
+	for i := 0; i < 2; i++ {
+		if out := do("poster", "luke"); out != want {
+			t.Errorf("poster printed %q, want %q", out, want)
+		}
+	}
+	if *posters != 1 {
+		t.Errorf("poster downloaded %d times", *posters)
+	}
+	if err := cat.run(ctx, []string{"poster", "casablanca"}, &out); err == nil {
+		t.Errorf("poster of a movie without one succeeded")
+	}
+
+	// The catalogue persists.
+	c, err := Load(cat.file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Movies) != 3 || c.Movies[1].Poster == "" {
+		t.Errorf("saved catalogue is %v", c.Movies)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DefaultAPI is the base URL of the Open Movie Database.
+const DefaultAPI = "https://www.omdbapi.com/"
+
+// An API is a client of the JSON web service of the Open Movie
+// Database, or of another service with the same interface
+// (see exercise 4.13).
+type API struct {
+	BaseURL    string       // if empty, DefaultAPI
+	Key        string       // the access key
+	HTTPClient *http.Client // if nil, http.DefaultClient
+}
+
+func (api *API) client() *http.Client {
+	if api.HTTPClient != nil {
+		return api.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// omdbMovie is the API's record of a movie.
+type omdbMovie struct {
+	Title    string
+	Year     string // "1967", or "1967–1970" for a series
+	Actors   string // separated by commas
+	Poster   string // a URL, or "N/A"
+	Response string // "True" or "False"
+	Error    string // if Response is "False"
+}
+
+// Fetch returns the movie with the given title, released in the given
+// year if it is not zero.
+func (api *API) Fetch(ctx context.Context, title string, year int) (*Movie, error) {
+	base := api.BaseURL
+	if base == "" {
+		base = DefaultAPI
+	}
+	q := url.Values{"t": {title}, "type": {"movie"}, "apikey": {api.Key}}
+	if year != 0 {
+		q.Set("y", strconv.Itoa(year))
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", base+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := api.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var m omdbMovie
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return nil, fmt.Errorf("fetching %q: %s: %v", title, resp.Status, err)
+	}
+	if m.Response != "True" {
+		return nil, fmt.Errorf("fetching %q: %s", title, m.Error)
+	}
+
+	movie := &Movie{Title: m.Title}
+	if len(m.Year) >= 4 {
+		movie.Year, _ = strconv.Atoi(m.Year[:4])
+	}
+	for _, actor := range strings.Split(m.Actors, ",") {
+		if actor = strings.TrimSpace(actor); actor != "" && actor != "N/A" {
+			movie.Actors = append(movie.Actors, actor)
+		}
+	}
+	if m.Poster != "N/A" {
+		movie.Poster = m.Poster
+	}
+	return movie, nil
+}
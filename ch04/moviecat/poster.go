@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// posterFile returns the name of the file in dir that caches the
+// poster of m.
+func posterFile(dir string, m *Movie) string {
+	ext := ".jpg"
+	if u, err := url.Parse(m.Poster); err == nil && path.Ext(u.Path) != "" {
+		ext = path.Ext(u.Path)
+	}
+	name := strings.Join(words(m.Title), "-") + "-" + strconv.Itoa(m.Year) + ext
+	return filepath.Join(dir, name)
+}
+
+// Poster returns the name of a file in dir holding the poster of m,
+// downloading it if it is not there already.
+func (api *API) Poster(ctx context.Context, dir string, m *Movie) (string, error) {
+	if m.Poster == "" {
+		return "", fmt.Errorf("%s has no poster", m)
+	}
+	name := posterFile(dir, m)
+	if _, err := os.Stat(name); err == nil {
+		return name, nil // cached
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", m.Poster, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := api.client().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("poster of %s: %s", m, resp.Status)
+	}
+
+	// Download to a temporary file, so that an interrupted download
+	// is not mistaken for a cached poster.
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	f, err := ioutil.TempFile(dir, ".poster")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(f, resp.Body)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return name, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A term is one condition of a query, all of which a movie must meet.
+type term struct {
+	field    string   // "title", "actor" or "year"
+	words    []string // of the title or actor's name, in sequence
+	from, to int      // range of years, inclusive
+}
+
+// parseQuery parses a query, a sequence of terms separated by spaces.
+// A term is a field name, a colon and a value, or just a value, which
+// is matched against the title:
+//
+//	title:casablanca
+//	actor:"Paul Newman"
+//	year:1967 year:1967..1970 year:..1950 year:1990..
+//	"cool hand"
+//
+// A quoted value matches its words in sequence.  Case is ignored.
+func parseQuery(query string) ([]term, error) {
+	var terms []term
+	s := strings.TrimSpace(query)
+	for s != "" {
+		field := "title"
+		if i := strings.IndexAny(s, ` :"`); i > 0 && s[i] == ':' {
+			field, s = strings.ToLower(s[:i]), s[i+1:]
+		}
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quotation in %q", query)
+			}
+			value, s = s[1:1+end], s[2+end:]
+		} else {
+			end := strings.IndexByte(s, ' ')
+			if end < 0 {
+				end = len(s)
+			}
+			value, s = s[:end], s[end:]
+		}
+		s = strings.TrimSpace(s)
+
+		t := term{field: field}
+		switch field {
+		case "title", "actor":
+			t.words = words(value)
+			if len(t.words) == 0 {
+				return nil, fmt.Errorf("no words in %s:%q", field, value)
+			}
+		case "year":
+			var err error
+			if t.from, t.to, err = parseYears(value); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unknown field %q (want title, actor or year)", field)
+		}
+		terms = append(terms, t)
+	}
+	return terms, nil
+}
+
+// parseYears parses a year, or a range of years such as 1967..1970,
+// either end of which may be omitted.
+func parseYears(s string) (from, to int, err error) {
+	lo, hi := s, s
+	if i := strings.Index(s, ".."); i >= 0 {
+		lo, hi = s[:i], s[i+2:]
+	}
+	from, to = 0, int(^uint(0)>>1)
+	if lo != "" {
+		if from, err = strconv.Atoi(lo); err != nil {
+			return 0, 0, fmt.Errorf("bad year %q", lo)
+		}
+	}
+	if hi != "" {
+		if to, err = strconv.Atoi(hi); err != nil {
+			return 0, 0, fmt.Errorf("bad year %q", hi)
+		}
+	}
+	if lo == "" && hi == "" || from > to {
+		return 0, 0, fmt.Errorf("bad range of years %q", s)
+	}
+	return from, to, nil
+}
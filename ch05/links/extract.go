@@ -0,0 +1,306 @@
+package links
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// A Kind identifies the element and attribute a link came from.
+type Kind string
+
+const (
+	Anchor     Kind = "a"       // <a href> or <area href>
+	Image      Kind = "img"     // <img src> or <img srcset>
+	Script     Kind = "script"  // <script src>
+	Resource   Kind = "link"    // <link href>: stylesheets, icons, and so on
+	Refresh    Kind = "refresh" // <meta http-equiv=refresh content="N; url=...">
+	StyleURL   Kind = "css"     // url(...) in a style attribute
+	SourceSet  Kind = "srcset"  // a candidate in <img srcset> or <source srcset>
+	SourceFile Kind = "source"  // <source src>
+)
+
+// A Link is a reference to another resource found in an HTML document.
+type Link struct {
+	URL  string // absolute URL of the target
+	Kind Kind
+	Rel  string // the rel attribute, lower case, if any
+	Text string // anchor text or image alt text, with white space collapsed
+}
+
+// NoFollow reports whether the link's rel attribute includes "nofollow".
+func (l Link) NoFollow() bool {
+	for _, r := range strings.Fields(l.Rel) {
+		if r == "nofollow" {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtractLinks is like Extract, but returns every kind of link
+// in the document, not just anchors.
+func ExtractLinks(url string) ([]Link, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("getting %s: %s", url, resp.Status)
+	}
+	return ExtractHTML(resp.Request.URL, resp.Body)
+}
+
+// ExtractHTML parses r as HTML and returns the links in the document,
+// resolved relative to base, or to the document's <base href> if it
+// has one.  Unlike Extract, it performs no I/O of its own, so callers
+// that manage their own HTTP requests (with contexts, custom clients,
+// and so on) can reuse the same extraction.
+func ExtractHTML(base *url.URL, r io.Reader) ([]Link, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s as HTML: %v", base, err)
+	}
+
+	var links []Link
+	eachRef(doc, base, func(l Link) (string, bool) {
+		links = append(links, l)
+		return "", false
+	})
+	return links, nil
+}
+
+// Rewrite parses r as HTML and writes it to w with each reference
+// replaced by the result of f.  f is called with each link, exactly
+// as ExtractHTML would return it, and returns the text to use in its
+// place in the document, or false to leave the reference unchanged.
+//
+// Any <base href> is removed, so that replacements are relative to
+// the document itself; references that f leaves unchanged in such a
+// document are made absolute.  The document is re-rendered, so its
+// formatting may change.
+func Rewrite(w io.Writer, base *url.URL, r io.Reader, f func(Link) (string, bool)) error {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return fmt.Errorf("parsing %s as HTML: %v", base, err)
+	}
+	if b := eachRef(doc, base, f); b != nil {
+		b.Parent.RemoveChild(b)
+	}
+	return html.Render(w, doc)
+}
+
+// eachRef calls f for each reference in the document, resolved
+// relative to base or the document's <base href>, and replaces
+// the reference with f's result if f returns true.  It returns
+// the <base> element, if any.
+func eachRef(doc *html.Node, base *url.URL, f func(Link) (string, bool)) (baseElem *html.Node) {
+	// The first <base href> applies to the whole document,
+	// including any links that precede it.
+	forEachNode(doc, func(n *html.Node) {
+		if baseElem == nil && n.Type == html.ElementNode && n.Data == "base" {
+			if href, ok := attr(n, "href"); ok {
+				if u, err := base.Parse(href); err == nil {
+					base = u
+					baseElem = n
+				}
+			}
+		}
+	}, nil)
+
+	forEachNode(doc, func(n *html.Node) {
+		if n.Type != html.ElementNode {
+			return
+		}
+		rel, _ := attr(n, "rel")
+		rel = strings.ToLower(rel)
+		// ref calls f for the reference ref of the given kind,
+		// and returns its replacement.
+		ref := func(ref string, kind Kind, text string) string {
+			trimmed := strings.TrimSpace(ref)
+			if trimmed == "" {
+				return ref
+			}
+			u, err := base.Parse(trimmed)
+			if err != nil {
+				return ref // ignore bad URLs
+			}
+			if s, ok := f(Link{u.String(), kind, rel, text}); ok {
+				return s
+			} else if baseElem != nil {
+				return u.String()
+			}
+			return ref
+		}
+		for i, a := range n.Attr {
+			switch {
+			case a.Key == "style":
+				n.Attr[i].Val = cssURL.ReplaceAllStringFunc(a.Val, func(m string) string {
+					old := cssURLs(m)[0]
+					if new := ref(old, StyleURL, ""); new != old {
+						return "url(" + cssString(new) + ")"
+					}
+					return m
+				})
+			case n.Data == "a" && a.Key == "href", n.Data == "area" && a.Key == "href":
+				n.Attr[i].Val = ref(a.Val, Anchor, text(n))
+			case n.Data == "img" && a.Key == "src":
+				n.Attr[i].Val = ref(a.Val, Image, alt(n))
+			case n.Data == "source" && a.Key == "src":
+				n.Attr[i].Val = ref(a.Val, SourceFile, "")
+			case n.Data == "script" && a.Key == "src":
+				n.Attr[i].Val = ref(a.Val, Script, "")
+			case n.Data == "link" && a.Key == "href":
+				n.Attr[i].Val = ref(a.Val, Resource, "")
+			case (n.Data == "img" || n.Data == "source") && a.Key == "srcset":
+				text := ""
+				if n.Data == "img" {
+					text = alt(n)
+				}
+				// Replace each candidate URL in turn, searching
+				// from the end of the previous one.
+				val, start := a.Val, 0
+				for _, src := range srcset(a.Val) {
+					j := strings.Index(val[start:], src)
+					if j < 0 {
+						break
+					}
+					new := ref(src, SourceSet, text)
+					val = val[:start+j] + new + val[start+j+len(src):]
+					start += j + len(new)
+				}
+				n.Attr[i].Val = val
+			case n.Data == "meta" && a.Key == "content":
+				if equiv, _ := attr(n, "http-equiv"); strings.EqualFold(equiv, "refresh") {
+					if old, ok := refreshURL(a.Val); ok {
+						if new := ref(old, Refresh, ""); new != old {
+							n.Attr[i].Val = strings.Replace(a.Val, old, new, 1)
+						}
+					}
+				}
+			}
+		}
+	}, nil)
+	return baseElem
+}
+
+// alt returns the alt text of n, with white space collapsed.
+func alt(n *html.Node) string {
+	alt, _ := attr(n, "alt")
+	return strings.Join(strings.Fields(alt), " ")
+}
+
+// attr returns the value of n's attribute named key.
+func attr(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// text returns the text content of n and its descendants,
+// with runs of white space collapsed to a single space.
+func text(n *html.Node) string {
+	var buf strings.Builder
+	forEachNode(n, func(n *html.Node) {
+		if n.Type == html.TextNode {
+			buf.WriteString(n.Data)
+			buf.WriteByte(' ')
+		}
+	}, nil)
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+// srcset returns the URLs of the image candidates in a srcset
+// attribute such as "a.png 1x, b.png 2x".  A URL may itself contain
+// commas, so candidates are separated only by a comma that follows
+// a descriptor or white space.
+func srcset(s string) []string {
+	var urls []string
+	for {
+		s = strings.TrimLeft(s, " \t\n\r\f,")
+		if s == "" {
+			return urls
+		}
+		i := strings.IndexAny(s, " \t\n\r\f")
+		if i < 0 {
+			i = len(s)
+		}
+		u := s[:i]
+		s = s[i:]
+		if trimmed := strings.TrimRight(u, ","); trimmed != u {
+			u = trimmed // "a.png," has no descriptors
+		} else if j := strings.IndexByte(s, ','); j >= 0 {
+			s = s[j+1:] // skip descriptors
+		} else {
+			s = ""
+		}
+		urls = append(urls, u)
+	}
+}
+
+// refreshURL returns the URL in the content of a refresh meta
+// element, such as "5; url=/next.html".
+func refreshURL(content string) (string, bool) {
+	i := strings.IndexAny(content, ";,")
+	if i < 0 {
+		return "", false
+	}
+	rest := strings.TrimSpace(content[i+1:])
+	if len(rest) < 4 || !strings.EqualFold(rest[:3], "url") {
+		return "", false
+	}
+	rest = strings.TrimSpace(rest[3:])
+	if !strings.HasPrefix(rest, "=") {
+		return "", false
+	}
+	rest = strings.TrimSpace(rest[1:])
+	if len(rest) > 0 && (rest[0] == '"' || rest[0] == '\'') {
+		if j := strings.IndexByte(rest[1:], rest[0]); j >= 0 {
+			rest = rest[1 : j+1]
+		} else {
+			rest = rest[1:]
+		}
+	}
+	return rest, rest != ""
+}
+
+var cssURL = regexp.MustCompile(`url\(\s*(?:"([^"]*)"|'([^']*)'|([^)'"\s]*))\s*\)`)
+
+// cssString returns s as a quoted CSS string.  Quotes and
+// backslashes are escaped with a backslash, and control and
+// non-ASCII characters as hexadecimal escapes, each ended by a space.
+func cssString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r >= 0x7f:
+			fmt.Fprintf(&b, "\\%x ", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// cssURLs returns the arguments of each url(...) in a CSS fragment.
+func cssURLs(css string) []string {
+	var urls []string
+	for _, m := range cssURL.FindAllStringSubmatch(css, -1) {
+		urls = append(urls, m[1]+m[2]+m[3])
+	}
+	return urls
+}
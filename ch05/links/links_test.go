@@ -0,0 +1,112 @@
+package links
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestExtractHTML(t *testing.T) {
+	const doc = `<html><head>
+<link rel="stylesheet" href="style.css">
+<base href="/docs/">
+<meta http-equiv="Refresh" content="5; URL='next.html'">
+<script src="app.js"></script>
+</head><body>
+<a href="intro.html">An   <b>introduction</b></a>
+<a rel="NoFollow sponsored" href="http://ads.example/">Ad</a>
+<img src="logo.png" srcset="logo-1x.png 1x, logo,2x.png 2x" alt="The logo">
+<picture><source srcset="a.webp, b.webp 2x"></picture>
+<div style="background: url('bg.png'); border-image: url(border.svg)"></div>
+<a href="http://[::1">bad</a>
+</body></html>`
+	base, _ := url.Parse("http://example.com/index.html")
+	links, err := ExtractHTML(base, strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, l := range links {
+		s := fmt.Sprintf("%s %s", l.Kind, l.URL)
+		if l.Text != "" {
+			s += fmt.Sprintf(" %q", l.Text)
+		}
+		if l.NoFollow() {
+			s += " nofollow"
+		}
+		got = append(got, s)
+	}
+	want := []string{
+		"link http://example.com/docs/style.css",
+		"refresh http://example.com/docs/next.html",
+		"script http://example.com/docs/app.js",
+		`a http://example.com/docs/intro.html "An introduction"`,
+		`a http://ads.example/ "Ad" nofollow`,
+		`img http://example.com/docs/logo.png "The logo"`,
+		`srcset http://example.com/docs/logo-1x.png "The logo"`,
+		`srcset http://example.com/docs/logo,2x.png "The logo"`,
+		"srcset http://example.com/docs/a.webp",
+		"srcset http://example.com/docs/b.webp",
+		"css http://example.com/docs/bg.png",
+		"css http://example.com/docs/border.svg",
+	}
+	if g, w := strings.Join(got, "\n"), strings.Join(want, "\n"); g != w {
+		t.Errorf("got:\n%s\nwant:\n%s", g, w)
+	}
+}
+
+func TestRefreshURL(t *testing.T) {
+	for _, test := range []struct {
+		content, want string
+		ok            bool
+	}{
+		{"0;url=/a", "/a", true},
+		{"3; URL = \"/b c\"", "/b c", true},
+		{"10", "", false},
+		{"5; urn=/x", "", false},
+	} {
+		got, ok := refreshURL(test.content)
+		if got != test.want || ok != test.ok {
+			t.Errorf("refreshURL(%q) = %q, %t, want %q, %t", test.content, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	const doc = `<html><head><base href="/docs/"></head><body>` +
+		`<a href="a.html">A</a><a href="http://other.example/">B</a>` +
+		`<img srcset="x.png 1x, y.png 2x" style="background:url(bg.png)">` +
+		`</body></html>`
+	base, _ := url.Parse("http://example.com/")
+	var buf strings.Builder
+	err := Rewrite(&buf, base, strings.NewReader(doc), func(l Link) (string, bool) {
+		if !strings.HasPrefix(l.URL, "http://example.com/docs/") {
+			return "", false
+		}
+		return "local/" + strings.TrimPrefix(l.URL, "http://example.com/docs/"), true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<html><head></head><body>` +
+		`<a href="local/a.html">A</a><a href="http://other.example/">B</a>` +
+		`<img srcset="local/x.png 1x, local/y.png 2x" style="background:url(&#34;local/bg.png&#34;)"/>` +
+		`</body></html>`
+	if got := buf.String(); got != want {
+		t.Errorf("Rewrite:\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestCSSString(t *testing.T) {
+	for s, want := range map[string]string{
+		"bg.png":   `"bg.png"`,
+		`a"b\c`:    `"a\"b\\c"`,
+		"café.png": `"caf\e9 .png"`,
+		"a\nb":     `"a\a b"`,
+	} {
+		if got := cssString(s); got != want {
+			t.Errorf("cssString(%q) = %s, want %s", s, got, want)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"gopl.io/ch05/tasks"
+)
+
+// parse parses a task file.  Each task begins with a line holding its
+// name, a colon, and the names of the tasks it depends on; the
+// indented lines that follow describe it.  An "inputs" line lists
+// patterns of the files on which the task depends.  Every other line
+// is a step of its action, which are taken in order:
+//
+//	run program args...   run a program, without a shell
+//	echo text...          print text
+//	mkdir dir...          make directories, with their parents
+//	rm path...            remove files or directories, if they exist
+//	cp from to            copy a file
+//
+// Arguments are separated by spaces, and may be quoted with double
+// quotes.  Blank lines and lines beginning with # are ignored.
+func parse(name string, r io.Reader) ([]*tasks.Task, error) {
+	var list []*tasks.Task
+	var t *tasks.Task
+	var steps []tasks.Action
+	end := func() {
+		if t != nil {
+			t.Action = sequence(steps)
+			list = append(list, t)
+		}
+	}
+
+	in := bufio.NewScanner(r)
+	for n := 1; in.Scan(); n++ {
+		line := in.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		errorf := func(format string, args ...interface{}) error {
+			return fmt.Errorf("%s:%d: %s", name, n, fmt.Sprintf(format, args...))
+		}
+
+		if line[0] != ' ' && line[0] != '\t' {
+			// The heading of a new task.
+			i := strings.Index(trimmed, ":")
+			if i <= 0 || strings.ContainsAny(trimmed[:i], " \t") {
+				return nil, errorf("want task name and colon")
+			}
+			end()
+			t = &tasks.Task{Name: trimmed[:i], Deps: strings.Fields(trimmed[i+1:])}
+			steps = nil
+			continue
+		}
+
+		if t == nil {
+			return nil, errorf("indented line outside a task")
+		}
+		args, err := fields(trimmed)
+		if err != nil {
+			return nil, errorf("%v", err)
+		}
+		if args[0] == "inputs" {
+			t.Inputs = append(t.Inputs, args[1:]...)
+			continue
+		}
+		step, err := newStep(args)
+		if err != nil {
+			return nil, errorf("%v", err)
+		}
+		steps = append(steps, step)
+		t.Spec += trimmed + "\n"
+	}
+	if err := in.Err(); err != nil {
+		return nil, err
+	}
+	end()
+	return list, nil
+}
+
+// fields splits s into space-separated arguments, which may be
+// quoted with double quotes.
+func fields(s string) ([]string, error) {
+	var args []string
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		if s[0] == '"' {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quotation")
+			}
+			args = append(args, s[1:1+end])
+			s = s[2+end:]
+			continue
+		}
+		end := strings.IndexAny(s, " \t")
+		if end < 0 {
+			end = len(s)
+		}
+		args = append(args, s[:end])
+		s = s[end:]
+	}
+	return args, nil
+}
+
+// newStep returns the action of a step.
+func newStep(args []string) (tasks.Action, error) {
+	cmd, args := args[0], args[1:]
+	want := func(n int) error {
+		if len(args) < n {
+			return fmt.Errorf("%s needs %d arguments", cmd, n)
+		}
+		return nil
+	}
+	switch cmd {
+	case "run":
+		if err := want(1); err != nil {
+			return nil, err
+		}
+		return tasks.Command(args[0], args[1:]...), nil
+	case "echo":
+		return func(_ context.Context, out io.Writer) error {
+			_, err := fmt.Fprintln(out, strings.Join(args, " "))
+			return err
+		}, nil
+	case "mkdir", "rm":
+		return func(context.Context, io.Writer) error {
+			for _, name := range args {
+				f := os.RemoveAll
+				if cmd == "mkdir" {
+					f = func(name string) error { return os.MkdirAll(name, 0755) }
+				}
+				if err := f(name); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, nil
+	case "cp":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("cp needs 2 arguments")
+		}
+		return func(context.Context, io.Writer) error {
+			data, err := ioutil.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			return ioutil.WriteFile(args[1], data, 0644)
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown step %q", cmd)
+}
+
+// sequence returns an action that takes each of steps in turn,
+// stopping at the first to fail.
+func sequence(steps []tasks.Action) tasks.Action {
+	return func(ctx context.Context, out io.Writer) error {
+		for _, step := range steps {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := step(ctx, out); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
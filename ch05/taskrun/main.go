@@ -0,0 +1,69 @@
+// Taskrun runs the tasks declared in a task file, like make.
+//
+// It runs the named tasks, or all of them, and the tasks they depend
+// on, using gopl.io/ch05/tasks: concurrently, in topological order,
+// skipping those whose inputs are unchanged since they last
+// succeeded, and stopping at the first failure.  See parse for the
+// format of the file.
+//
+//	$ cat Taskfile
+//	generate:
+//		inputs gen/*.txt
+//		run go generate ./...
+//	build: generate
+//		inputs *.go
+//		run go build ./...
+//	test: build
+//		run go test ./...
+//	$ taskrun -j 4 test
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"gopl.io/ch05/tasks"
+)
+
+var (
+	file    = flag.String("f", "Taskfile", "read tasks from `file`")
+	workers = flag.Int("j", 0, "run at most `N` tasks at once (default GOMAXPROCS)")
+	state   = flag.String("state", ".taskrun.json", "record the inputs of tasks in `file`")
+	force   = flag.Bool("force", false, "forget the recorded inputs, and run every task")
+)
+
+func main() {
+	flag.Parse()
+	f, err := os.Open(*file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "taskrun: %v\n", err)
+		os.Exit(1)
+	}
+	list, err := parse(*file, f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "taskrun: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Cancel the tasks on interrupt.
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sigint := make(chan os.Signal, 1)
+		signal.Notify(sigint, os.Interrupt)
+		<-sigint
+		cancel()
+	}()
+
+	r := &tasks.Runner{Workers: *workers, State: *state, Output: os.Stdout}
+	if *force {
+		os.Remove(*state)
+	}
+	if _, err := r.Run(ctx, list, flag.Args()...); err != nil {
+		fmt.Fprintf(os.Stderr, "taskrun: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopl.io/ch05/tasks"
+)
+
+func TestParse(t *testing.T) {
+	list, err := parse("Taskfile", strings.NewReader(`# A comment.
+build: generate fetch
+	inputs *.go go.mod
+	mkdir bin
+	run go build -o bin ./...
+
+generate: 
+	echo "hello,  world" again
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("parsed %d tasks, want 2", len(list))
+	}
+	build := list[0]
+	if build.Name != "build" || !reflect.DeepEqual(build.Deps, []string{"generate", "fetch"}) ||
+		!reflect.DeepEqual(build.Inputs, []string{"*.go", "go.mod"}) ||
+		build.Spec != "mkdir bin\nrun go build -o bin ./...\n" {
+		t.Errorf("build = %+v", build)
+	}
+	var out bytes.Buffer
+	if err := list[1].Action(context.Background(), &out); err != nil || out.String() != "hello,  world again\n" {
+		t.Errorf("generate printed %q, %v", &out, err)
+	}
+
+	for _, test := range []struct{ text, want string }{
+		{"\tmkdir x\n", "Taskfile:1: indented line outside a task"},
+		{"a b: c\n", "Taskfile:1: want task name and colon"},
+		{"a:\n\n\tfrobnicate x\n", `Taskfile:3: unknown step "frobnicate"`},
+		{"a:\n\techo \"x\n", "Taskfile:2: unterminated quotation"},
+		{"a:\n\tcp x\n", "Taskfile:2: cp needs 2 arguments"},
+		{"a:\n\trun\n", "Taskfile:2: run needs 1 arguments"},
+	} {
+		if _, err := parse("Taskfile", strings.NewReader(test.text)); err == nil || err.Error() != test.want {
+			t.Errorf("parse(%q) returned %v, want %s", test.text, err, test.want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dir, name) }
+	if err := ioutil.WriteFile(path("src.txt"), []byte("source"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	list, err := parse("Taskfile", strings.NewReader(strings.NewReplacer("$D", dir).Replace(`
+build: prepare
+	inputs $D/src.txt
+	cp $D/src.txt $D/out/dst.txt
+prepare:
+	mkdir $D/out
+clean:
+	rm $D/out
+`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &tasks.Runner{State: path("state.json")}
+	status, err := r.Run(context.Background(), list, "build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status["build"] != tasks.Done || status["prepare"] != tasks.Done || len(status) != 2 {
+		t.Errorf("status = %v", status)
+	}
+	if data, err := ioutil.ReadFile(path("out/dst.txt")); err != nil || string(data) != "source" {
+		t.Errorf("after build, dst.txt holds %q, %v", data, err)
+	}
+
+	if _, err := r.Run(context.Background(), list, "clean"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path("out")); !os.IsNotExist(err) {
+		t.Errorf("after clean, out exists: %v", err)
+	}
+	status, err = r.Run(context.Background(), list, "build")
+	if err != nil || status["build"] != tasks.Skipped {
+		t.Errorf("rebuilding: %v, %v", status, err)
+	}
+}
@@ -0,0 +1,384 @@
+// Package tasks runs a graph of dependent tasks, like make.
+//
+// Tasks run concurrently, each as soon as the tasks it depends on
+// are done, in the topological order of gopl.io/ch05/topo where there
+// is a choice.  A task whose inputs are unchanged since it last
+// succeeded is skipped, and the first task to fail cancels those
+// running and prevents any more from starting.
+package tasks
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"time"
+
+	"gopl.io/ch05/topo"
+)
+
+// An Action performs a task, writing any output to out.  It should
+// return promptly once ctx is cancelled.
+type Action func(ctx context.Context, out io.Writer) error
+
+// Command returns an Action that runs the named program with args,
+// without a shell.  The program is killed if the action is cancelled.
+func Command(name string, args ...string) Action {
+	return func(ctx context.Context, out io.Writer) error {
+		cmd := exec.CommandContext(ctx, name, args...)
+		cmd.Stdout = out
+		cmd.Stderr = out
+		return cmd.Run()
+	}
+}
+
+// A Task is a unit of work.
+type Task struct {
+	Name   string
+	Deps   []string // names of the tasks that must be done first
+	Action Action
+
+	// Inputs are the files that determine the task's outcome, as
+	// patterns for filepath.Glob.  A task with inputs is skipped if
+	// they, its Spec and the inputs of its dependencies are the same
+	// as when it last succeeded.  A task without inputs always runs.
+	Inputs []string
+
+	// Spec describes the action, such as the command it runs, so
+	// that changing the action makes the task run again.
+	Spec string
+}
+
+// A Status is the outcome of a task.
+type Status int
+
+const (
+	Done      Status = iota // ran and succeeded
+	Skipped                 // not run, because its inputs were unchanged
+	Failed                  // ran and failed
+	Cancelled               // stopped or never started, because the run failed
+)
+
+var statuses = [...]string{"done", "skipped", "failed", "cancelled"}
+
+func (s Status) String() string { return statuses[s] }
+
+// A Runner runs tasks.  Its zero value is ready to use.
+type Runner struct {
+	// Workers is the maximum number of tasks that run at once.
+	// Zero means runtime.GOMAXPROCS(0).
+	Workers int
+
+	// State, if not empty, names the file that records the hashes
+	// of the inputs of the tasks that succeeded.  If empty, every
+	// task runs.
+	State string
+
+	// Output, if not nil, receives a line reporting the outcome of
+	// each task, followed by its output.  The output of concurrent
+	// tasks is not interleaved.
+	Output io.Writer
+}
+
+// A result is the outcome of running one task.
+type result struct {
+	name   string
+	status Status
+	hash   string
+	err    error
+	output []byte
+	time   time.Duration
+}
+
+// Run runs the tasks named by targets, and the tasks they depend on,
+// or all tasks if there are no targets.  It returns the status of
+// each task it was to run, and the error of the first task to fail,
+// if any.  It fails without running any task if the dependencies of
+// the tasks are unknown or cyclic.
+func (r *Runner) Run(ctx context.Context, tasks []*Task, targets ...string) (map[string]Status, error) {
+	byName := make(map[string]*Task)
+	graph := make(map[string][]string)
+	for _, t := range tasks {
+		if byName[t.Name] != nil {
+			return nil, fmt.Errorf("task %s is defined twice", t.Name)
+		}
+		byName[t.Name] = t
+		graph[t.Name] = t.Deps
+	}
+	for _, t := range tasks {
+		for _, dep := range t.Deps {
+			if byName[dep] == nil {
+				return nil, fmt.Errorf("task %s depends on unknown task %s", t.Name, dep)
+			}
+		}
+	}
+	order, err := topo.Sort(graph)
+	if err != nil {
+		return nil, err
+	}
+	order, err = needed(order, graph, targets, byName)
+	if err != nil {
+		return nil, err
+	}
+	state, err := loadState(r.State)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	workers := r.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	// The scheduler below starts each task once its dependencies
+	// are done, preferring the earliest in topological order.
+	status := make(map[string]Status)
+	hashes := make(map[string]string)
+	waiting := make(map[string]int)         // number of dependencies not done
+	dependants := make(map[string][]string) // in topological order
+	for _, name := range order {
+		status[name] = Cancelled // until it finishes
+		waiting[name] = len(byName[name].Deps)
+		for _, dep := range byName[name].Deps {
+			dependants[dep] = append(dependants[dep], name)
+		}
+	}
+	rank := make(map[string]int)
+	var ready []string // in topological order
+	for i, name := range order {
+		rank[name] = i
+		if waiting[name] == 0 {
+			ready = append(ready, name)
+		}
+	}
+
+	results := make(chan result)
+	started := make(map[string]bool)
+	running := 0
+	var firstErr error
+	for running > 0 || (len(ready) > 0 && firstErr == nil) {
+		for running < workers && len(ready) > 0 && firstErr == nil {
+			t := byName[ready[0]]
+			ready = ready[1:]
+			deps := make([]string, len(t.Deps))
+			for i, dep := range t.Deps {
+				deps[i] = hashes[dep]
+			}
+			last := state[t.Name] // read here, since this loop writes state
+			started[t.Name] = true
+			running++
+			go func(t *Task, deps []string, last string) {
+				results <- run(ctx, t, deps, last)
+			}(t, deps, last)
+		}
+
+		res := <-results
+		running--
+		status[res.name] = res.status
+		hashes[res.name] = res.hash
+		r.report(res)
+		switch res.status {
+		case Done, Skipped:
+			if res.hash != "" {
+				state[res.name] = res.hash
+			}
+			for _, d := range dependants[res.name] {
+				if waiting[d]--; waiting[d] == 0 {
+					ready = insert(ready, d, rank)
+				}
+			}
+		case Failed, Cancelled:
+			// The task may have left its outputs half made, so
+			// it must run next time, whatever its inputs.
+			delete(state, res.name)
+			if res.status == Failed && firstErr == nil {
+				firstErr = fmt.Errorf("task %s: %v", res.name, res.err)
+				cancel() // fail fast
+			}
+		}
+	}
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = ctx.Err() // the caller cancelled the run
+	}
+	for _, name := range order {
+		if !started[name] {
+			r.report(result{name: name, status: Cancelled})
+		}
+	}
+
+	if err := saveState(r.State, state); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return status, firstErr
+}
+
+// needed returns the tasks in order that are named by targets or
+// that they depend on, or all of order if there are no targets.
+func needed(order []string, graph map[string][]string, targets []string, byName map[string]*Task) ([]string, error) {
+	if len(targets) == 0 {
+		return order, nil
+	}
+	need := make(map[string]bool)
+	var visit func(name string)
+	visit = func(name string) {
+		if !need[name] {
+			need[name] = true
+			for _, dep := range graph[name] {
+				visit(dep)
+			}
+		}
+	}
+	for _, name := range targets {
+		if byName[name] == nil {
+			return nil, fmt.Errorf("unknown task %s", name)
+		}
+		visit(name)
+	}
+	var tasks []string
+	for _, name := range order {
+		if need[name] {
+			tasks = append(tasks, name)
+		}
+	}
+	return tasks, nil
+}
+
+// insert inserts name into ready, keeping it in order of rank.
+func insert(ready []string, name string, rank map[string]int) []string {
+	i := sort.Search(len(ready), func(i int) bool { return rank[ready[i]] > rank[name] })
+	ready = append(ready, "")
+	copy(ready[i+1:], ready[i:])
+	ready[i] = name
+	return ready
+}
+
+// run runs task t, unless the hash of its inputs, which depends on
+// deps, the hashes of its dependencies, equals last, and returns the
+// outcome.
+func run(ctx context.Context, t *Task, deps []string, last string) result {
+	res := result{name: t.Name}
+	start := time.Now()
+	var out bytes.Buffer
+	if len(t.Inputs) > 0 {
+		res.hash, res.err = hash(t, deps)
+	}
+	switch {
+	case res.err != nil:
+		res.status = Failed
+	case res.hash != "" && res.hash == last:
+		res.status = Skipped
+	case ctx.Err() != nil:
+		res.status = Cancelled
+	default:
+		res.status = Done
+		if t.Action != nil {
+			res.err = t.Action(ctx, &out)
+		}
+		if res.err != nil {
+			res.status = Failed
+			if ctx.Err() != nil {
+				res.status = Cancelled // by another task's failure
+			}
+		}
+	}
+
+	res.output = out.Bytes()
+	res.time = time.Since(start)
+	return res
+}
+
+// report writes the outcome and output of a task to r.Output.
+func (r *Runner) report(res result) {
+	if r.Output == nil {
+		return
+	}
+	line := fmt.Sprintf("%s: %s", res.name, res.status)
+	switch res.status {
+	case Done:
+		line += fmt.Sprintf(" (%.1fs)", res.time.Seconds())
+	case Failed:
+		line += fmt.Sprintf(": %v", res.err)
+	}
+	fmt.Fprintf(r.Output, "%s\n%s", line, res.output)
+}
+
+// hash returns a SHA-256 hash of the name, spec and inputs of t and
+// of deps, the hashes of its dependencies, in hexadecimal.
+func hash(t *Task, deps []string) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "%q %q %q\n", t.Name, t.Spec, deps)
+	for _, pattern := range t.Inputs {
+		names, err := filepath.Glob(pattern)
+		if err != nil {
+			return "", err
+		}
+		if names == nil {
+			return "", fmt.Errorf("no input matches %s", pattern)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			f, err := os.Open(name)
+			if err != nil {
+				return "", err
+			}
+			info, err := f.Stat()
+			if err == nil && info.IsDir() {
+				err = fmt.Errorf("input %s is a directory", name)
+			}
+			if err == nil {
+				fmt.Fprintf(h, "%q %d\n", name, info.Size())
+				_, err = io.Copy(h, f)
+			}
+			f.Close()
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// loadState returns the hashes recorded in the named file, if any.
+func loadState(name string) (map[string]string, error) {
+	state := make(map[string]string)
+	if name == "" {
+		return state, nil
+	}
+	data, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return state, nil
+}
+
+// saveState records state in the named file, replacing it atomically.
+func saveState(name string, state map[string]string) error {
+	if name == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(state, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp := name + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
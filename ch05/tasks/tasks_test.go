@@ -0,0 +1,265 @@
+package tasks_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopl.io/ch05/tasks"
+	"gopl.io/ch05/topo"
+)
+
+// A history records the starts and ends of tasks.
+type history struct {
+	mu               sync.Mutex
+	events           []string
+	running, maxRuns int
+}
+
+// action returns an action that records its start and end in l,
+// and returns err.
+func (l *history) action(name string, err error) tasks.Action {
+	return func(ctx context.Context, out io.Writer) error {
+		l.mu.Lock()
+		l.events = append(l.events, "start "+name)
+		l.running++
+		if l.running > l.maxRuns {
+			l.maxRuns = l.running
+		}
+		l.mu.Unlock()
+		time.Sleep(time.Millisecond)
+		fmt.Fprintf(out, "output of %s\n", name)
+		l.mu.Lock()
+		l.events = append(l.events, "end "+name)
+		l.running--
+		l.mu.Unlock()
+		return err
+	}
+}
+
+// index returns the position of event in the log, or -1.
+func (l *history) index(event string) int {
+	for i, e := range l.events {
+		if e == event {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestOrder(t *testing.T) {
+	l := new(history)
+	graph := map[string][]string{
+		"fetch": nil, "generate": nil, "tools": nil,
+		"build":   {"fetch", "generate"},
+		"test":    {"build", "tools"},
+		"package": {"build"},
+		"deploy":  {"test", "package"},
+	}
+	var list []*tasks.Task
+	for name, deps := range graph {
+		list = append(list, &tasks.Task{Name: name, Deps: deps, Action: l.action(name, nil)})
+	}
+	var out bytes.Buffer
+	r := &tasks.Runner{Workers: 2, Output: &out}
+	status, err := r.Run(context.Background(), list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, deps := range graph {
+		if status[name] != tasks.Done {
+			t.Errorf("%s: %s", name, status[name])
+		}
+		for _, dep := range deps {
+			if l.index("end "+dep) > l.index("start "+name) {
+				t.Errorf("%s started before %s ended", name, dep)
+			}
+		}
+	}
+	if l.maxRuns != 2 {
+		t.Errorf("ran %d tasks at once, want 2", l.maxRuns)
+	}
+	if !strings.Contains(out.String(), "build: done (") || !strings.Contains(out.String(), "output of build\n") {
+		t.Errorf("output:\n%s", &out)
+	}
+
+	// With targets, only they and their dependencies run.
+	l = new(history)
+	for _, task := range list {
+		task.Action = l.action(task.Name, nil)
+	}
+	status, err = (&tasks.Runner{}).Run(context.Background(), list, "package")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status) != 4 || len(l.events) != 8 {
+		t.Errorf("running package: %v, %v", status, l.events)
+	}
+}
+
+func TestSkip(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.txt")
+	write := func(text string) {
+		if err := ioutil.WriteFile(input, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("one")
+
+	l := new(history)
+	list := []*tasks.Task{
+		{Name: "gen", Inputs: []string{filepath.Join(dir, "*.txt")}, Action: l.action("gen", nil)},
+		{Name: "build", Deps: []string{"gen"}, Inputs: []string{input}, Action: l.action("build", nil)},
+		{Name: "lint", Inputs: []string{filepath.Join(dir, "*.go")}, Action: l.action("lint", nil)},
+		{Name: "report", Deps: []string{"build"}, Action: l.action("report", nil)},
+	}
+	r := &tasks.Runner{State: filepath.Join(dir, "state.json")}
+	run := func(want string, targets ...string) {
+		t.Helper()
+		status, err := r.Run(context.Background(), list, targets...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := fmt.Sprintf("gen %s, build %s, report %s", status["gen"], status["build"], status["report"])
+		if got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+	run("gen done, build done, report done", "report")
+	run("gen skipped, build skipped, report done", "report")
+
+	// Changing the input reruns the tasks that depend on it.
+	write("two")
+	run("gen done, build done, report done", "report")
+	run("gen skipped, build skipped, report done", "report")
+
+	// So does changing the spec of a dependency.
+	list[0].Spec = "v2"
+	run("gen done, build done, report done", "report")
+
+	// A task whose inputs are missing fails.
+	status, err := r.Run(context.Background(), list, "lint")
+	if err == nil || status["lint"] != tasks.Failed {
+		t.Errorf("lint without inputs: %v, %v", status["lint"], err)
+	}
+
+	// A task that fails, perhaps leaving its outputs half made,
+	// runs next time even if its inputs are as they were when it
+	// last succeeded.
+	write("three")
+	run("gen done, build done, report done", "report")
+	write("four")
+	list[1].Action = l.action("build", errors.New("oops"))
+	if status, err := r.Run(context.Background(), list, "report"); err == nil || status["build"] != tasks.Failed {
+		t.Errorf("failing build: %v, %v", status["build"], err)
+	}
+	write("three")
+	list[1].Action = l.action("build", nil)
+	run("gen done, build done, report done", "report")
+}
+
+// TestSkipConcurrent runs many independent tasks at once with a state
+// file, so that the race detector can catch unguarded use of it.
+func TestSkipConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.txt")
+	if err := ioutil.WriteFile(input, []byte("one"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	l := new(history)
+	var list []*tasks.Task
+	var names []string
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("t%d", i)
+		list = append(list, &tasks.Task{Name: name, Inputs: []string{input}, Action: l.action(name, nil)})
+		names = append(names, name)
+	}
+	r := &tasks.Runner{Workers: 4, State: filepath.Join(dir, "state.json")}
+	for _, want := range []tasks.Status{tasks.Done, tasks.Skipped} {
+		status, err := r.Run(context.Background(), list, names...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range names {
+			if status[name] != want {
+				t.Errorf("%s: got %s, want %s", name, status[name], want)
+			}
+		}
+	}
+}
+
+func TestFailFast(t *testing.T) {
+	l := new(history)
+	slowCancelled := make(chan bool, 1)
+	list := []*tasks.Task{
+		{Name: "a", Action: l.action("a", errors.New("oops"))},
+		{Name: "b", Deps: []string{"a"}, Action: l.action("b", nil)},
+		{Name: "c", Deps: []string{"b"}, Action: l.action("c", nil)},
+		{Name: "slow", Action: func(ctx context.Context, out io.Writer) error {
+			select {
+			case <-ctx.Done():
+				slowCancelled <- true
+				return ctx.Err()
+			case <-time.After(10 * time.Second):
+				slowCancelled <- false
+				return nil
+			}
+		}},
+	}
+	var out bytes.Buffer
+	status, err := (&tasks.Runner{Workers: 4, Output: &out}).Run(context.Background(), list)
+	if err == nil || err.Error() != "task a: oops" {
+		t.Errorf("Run returned %v, want task a: oops", err)
+	}
+	if !<-slowCancelled {
+		t.Errorf("slow task was not cancelled")
+	}
+	want := map[string]tasks.Status{"a": tasks.Failed, "b": tasks.Cancelled, "c": tasks.Cancelled, "slow": tasks.Cancelled}
+	if fmt.Sprint(status) != fmt.Sprint(want) {
+		t.Errorf("status = %v, want %v", status, want)
+	}
+	if l.index("start b") >= 0 {
+		t.Errorf("dependant of a failed task started")
+	}
+	for _, line := range []string{"a: failed: oops\n", "b: cancelled\n", "c: cancelled\n", "slow: cancelled\n"} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("output lacks %q:\n%s", line, &out)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	r := new(tasks.Runner)
+	ctx := context.Background()
+	_, err := r.Run(ctx, []*tasks.Task{
+		{Name: "a", Deps: []string{"b"}},
+		{Name: "b", Deps: []string{"a"}},
+	})
+	var cycle *topo.CycleError
+	if !errors.As(err, &cycle) || strings.Join(cycle.Path, " ") != "a b a" {
+		t.Errorf("cyclic tasks: %v", err)
+	}
+	for _, test := range []struct {
+		tasks   []*tasks.Task
+		targets []string
+		want    string
+	}{
+		{[]*tasks.Task{{Name: "a", Deps: []string{"x"}}}, nil, "task a depends on unknown task x"},
+		{[]*tasks.Task{{Name: "a"}, {Name: "a"}}, nil, "task a is defined twice"},
+		{[]*tasks.Task{{Name: "a"}}, []string{"b"}, "unknown task b"},
+		{[]*tasks.Task{{Name: "a", Action: tasks.Command("no such program")}}, nil, "task a: exec: "},
+	} {
+		if _, err := r.Run(ctx, test.tasks, test.targets...); err == nil || !strings.HasPrefix(err.Error(), test.want) {
+			t.Errorf("Run returned %v, want %s", err, test.want)
+		}
+	}
+}
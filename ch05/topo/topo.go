@@ -0,0 +1,165 @@
+// Package topo provides topological sorting of dependency graphs.
+//
+// It is the topoSort function of gopl.io/ch05/toposort made reusable:
+// rather than silently producing an order for a graph that contains a
+// cycle, Sort reports the cycle.  Levels groups the nodes of a graph
+// into sets that can be processed in parallel, and CriticalPath finds
+// the longest path through a graph whose edges are weighted.
+//
+// A graph is a map from each node to its prerequisites, like the
+// prereqs table of toposort.  A node that appears only as a
+// prerequisite has none itself.
+package topo
+
+import (
+	"sort"
+	"strings"
+)
+
+// A CycleError reports that a graph contains a cycle.
+type CycleError struct {
+	// Path is the cycle: each node is a prerequisite of the one
+	// before it, and the first node is the same as the last.
+	Path []string
+}
+
+func (e *CycleError) Error() string {
+	return "dependency cycle: " + strings.Join(e.Path, " -> ")
+}
+
+// Sort returns the nodes of graph in topological order: every node
+// comes after its prerequisites.  The order is the same as that of
+// toposort, and is determined by the graph alone.  If the graph
+// contains a cycle, Sort returns a *CycleError describing it.
+func Sort(graph map[string][]string) ([]string, error) {
+	const (
+		unseen = iota
+		visiting
+		done
+	)
+	var order []string
+	state := make(map[string]int)
+	var path []string // the nodes being visited, each a prerequisite of the one before
+	var visit func(node string) error
+	visit = func(node string) error {
+		switch state[node] {
+		case done:
+			return nil
+		case visiting:
+			i := len(path) - 1
+			for path[i] != node {
+				i--
+			}
+			cycle := append(append([]string(nil), path[i:]...), node)
+			return &CycleError{cycle}
+		}
+		state[node] = visiting
+		path = append(path, node)
+		for _, prereq := range graph[node] {
+			if err := visit(prereq); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[node] = done
+		order = append(order, node)
+		return nil
+	}
+
+	for _, key := range keys(graph) {
+		if err := visit(key); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// keys returns the keys of graph in sorted order.
+func keys(graph map[string][]string) []string {
+	var keys []string
+	for key := range graph {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Levels returns the nodes of graph grouped into levels, each in
+// sorted order.  The first level holds the nodes without
+// prerequisites, and each later level holds the nodes whose
+// prerequisites are all in earlier levels, at least one in the level
+// before.  So the nodes of a level may be processed in parallel once
+// those of the earlier levels are done.
+func Levels(graph map[string][]string) ([][]string, error) {
+	order, err := Sort(graph)
+	if err != nil {
+		return nil, err
+	}
+	var levels [][]string
+	level := make(map[string]int)
+	for _, node := range order {
+		n := 0
+		for _, prereq := range graph[node] {
+			if level[prereq]+1 > n {
+				n = level[prereq] + 1
+			}
+		}
+		level[node] = n
+		if n == len(levels) {
+			levels = append(levels, nil)
+		}
+		levels[n] = append(levels[n], node)
+	}
+	for _, nodes := range levels {
+		sort.Strings(nodes)
+	}
+	return levels, nil
+}
+
+// CriticalPath returns the longest path through graph, whose edge
+// from each prerequisite to the node that needs it has the length
+// given by weight, and the length of that path.  The path is listed
+// from its first prerequisite to its last dependant.  If several
+// paths are longest, the one found first in topological order is
+// returned.
+//
+// To weight nodes instead, such as build steps by their durations,
+// let weight(prereq, node) be the weight of node, and add the
+// weight of the path's first node to the length.
+func CriticalPath(graph map[string][]string, weight func(prereq, node string) float64) ([]string, float64, error) {
+	order, err := Sort(graph)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(order) == 0 {
+		return nil, 0, nil
+	}
+	dist := make(map[string]float64) // length of the longest path ending at a node
+	prev := make(map[string]string)  // the node before it on that path
+	end := order[0]
+	for _, node := range order {
+		for i, prereq := range graph[node] {
+			if d := dist[prereq] + weight(prereq, node); i == 0 || d > dist[node] {
+				dist[node] = d
+				prev[node] = prereq
+			}
+		}
+		if dist[node] > dist[end] {
+			end = node
+		}
+	}
+
+	var path []string
+	for node := end; ; {
+		path = append(path, node)
+		p, ok := prev[node]
+		if !ok {
+			break
+		}
+		node = p
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, dist[end], nil
+}
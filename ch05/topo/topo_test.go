@@ -0,0 +1,161 @@
+package topo_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopl.io/ch05/topo"
+)
+
+// prereqs is the table of gopl.io/ch05/toposort.
+var prereqs = map[string][]string{
+	"algorithms": {"data structures"},
+	"calculus":   {"linear algebra"},
+
+	"compilers": {
+		"data structures",
+		"formal languages",
+		"computer organization",
+	},
+
+	"data structures":       {"discrete math"},
+	"databases":             {"data structures"},
+	"discrete math":         {"intro to programming"},
+	"formal languages":      {"discrete math"},
+	"networks":              {"operating systems"},
+	"operating systems":     {"data structures", "computer organization"},
+	"programming languages": {"data structures", "computer organization"},
+}
+
+func TestSort(t *testing.T) {
+	order, err := topo.Sort(prereqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The order printed by toposort.
+	want := []string{
+		"intro to programming", "discrete math", "data structures",
+		"algorithms", "linear algebra", "calculus", "formal languages",
+		"computer organization", "compilers", "databases",
+		"operating systems", "networks", "programming languages",
+	}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("Sort = %q, want %q", order, want)
+	}
+}
+
+func TestCycle(t *testing.T) {
+	for _, test := range []struct {
+		graph map[string][]string
+		want  string
+	}{
+		{map[string][]string{"a": {"a"}}, "a -> a"},
+		{map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"a"}}, "a -> b -> c -> a"},
+		// The cycle excludes the nodes that lead to it.
+		{map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"d"}, "d": {"b"}}, "b -> c -> d -> b"},
+		{map[string][]string{
+			"linear algebra": {"calculus"},
+			"calculus":       {"linear algebra"},
+			"algorithms":     {"data structures"},
+		}, "calculus -> linear algebra -> calculus"},
+	} {
+		_, err := topo.Sort(test.graph)
+		var cycle *topo.CycleError
+		if !errors.As(err, &cycle) {
+			t.Errorf("Sort(%v) returned error %v, want a cycle", test.graph, err)
+			continue
+		}
+		if got := strings.Join(cycle.Path, " -> "); got != test.want {
+			t.Errorf("Sort(%v) found cycle %s, want %s", test.graph, got, test.want)
+		}
+		if _, err := topo.Levels(test.graph); err == nil {
+			t.Errorf("Levels(%v) succeeded", test.graph)
+		}
+		if _, _, err := topo.CriticalPath(test.graph, func(string, string) float64 { return 1 }); err == nil {
+			t.Errorf("CriticalPath(%v) succeeded", test.graph)
+		}
+	}
+}
+
+func TestLevels(t *testing.T) {
+	levels, err := topo.Levels(prereqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"computer organization", "intro to programming", "linear algebra"},
+		{"calculus", "discrete math"},
+		{"data structures", "formal languages"},
+		{"algorithms", "compilers", "databases", "operating systems", "programming languages"},
+		{"networks"},
+	}
+	if !reflect.DeepEqual(levels, want) {
+		t.Errorf("Levels = %q, want %q", levels, want)
+	}
+	if levels, err := topo.Levels(nil); err != nil || levels != nil {
+		t.Errorf("Levels(nil) = %v, %v", levels, err)
+	}
+}
+
+func TestCriticalPath(t *testing.T) {
+	// The weight of an edge is the length of the course it leads to,
+	// and every course without prerequisites takes 1 term.
+	terms := map[string]float64{"compilers": 3, "networks": 2, "operating systems": 2}
+	weight := func(prereq, course string) float64 {
+		if w, ok := terms[course]; ok {
+			return w
+		}
+		return 1
+	}
+	path, length, err := topo.CriticalPath(prereqs, weight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"intro to programming", "discrete math", "data structures", "operating systems", "networks"}
+	if !reflect.DeepEqual(path, want) || length != 6 {
+		t.Errorf("CriticalPath = %q, %g; want %q, 6", path, length, want)
+	}
+
+	if path, length, err := topo.CriticalPath(nil, weight); path != nil || length != 0 || err != nil {
+		t.Errorf("CriticalPath(nil) = %v, %g, %v", path, length, err)
+	}
+	path, length, _ = topo.CriticalPath(map[string][]string{"a": nil}, weight)
+	if !reflect.DeepEqual(path, []string{"a"}) || length != 0 {
+		t.Errorf("CriticalPath of one node = %v, %g", path, length)
+	}
+}
+
+func ExampleLevels() {
+	levels, err := topo.Levels(map[string][]string{
+		"test":    {"build"},
+		"build":   {"fetch", "generate"},
+		"deploy":  {"test", "package"},
+		"package": {"build"},
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for i, level := range levels {
+		fmt.Println(i, level)
+	}
+	// Output:
+	// 0 [fetch generate]
+	// 1 [build]
+	// 2 [package test]
+	// 3 [deploy]
+}
+
+func ExampleSort_cycle() {
+	_, err := topo.Sort(map[string][]string{
+		"build":    {"generate"},
+		"generate": {"tools"},
+		"tools":    {"build"},
+	})
+	fmt.Println(err)
+	// Output:
+	// dependency cycle: build -> generate -> tools -> build
+}
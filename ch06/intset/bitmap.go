@@ -0,0 +1,458 @@
+package intset
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// A Bitmap is a compressed set of integers in the range [0, 2³²),
+// or [0, 2³¹) where int is 32 bits, with the same methods as IntSet.
+// Its zero value represents the empty set.
+//
+// An IntSet needs a bit for every integer up to its greatest element,
+// so a set holding only 1<<30 occupies 128MB.  A Bitmap, in the
+// manner of a roaring bitmap, divides its elements into chunks of 2¹⁶
+// integers, and represents the elements of each chunk, if any, in
+// whichever of three ways is most compact: as a sorted array, a
+// bitmap, or a list of runs.
+type Bitmap struct {
+	keys  []uint16    // high 16 bits of the elements of each chunk, sorted
+	conts []container // low 16 bits of the elements of each chunk
+}
+
+// split returns the key and low bits of the element x, and reports
+// whether x is in the range of a Bitmap.
+func split(x int) (key, low uint16, ok bool) {
+	if x < 0 || uint64(x) >= 1<<32 {
+		return 0, 0, false
+	}
+	return uint16(x >> 16), uint16(x), true
+}
+
+func join(key, low uint16) int { return int(key)<<16 | int(low) }
+
+// find returns the index of the first chunk whose key is not less
+// than key.
+func (s *Bitmap) find(key uint16) int {
+	return sort.Search(len(s.keys), func(i int) bool { return s.keys[i] >= key })
+}
+
+// Has reports whether the set contains x.
+func (s *Bitmap) Has(x int) bool {
+	key, low, ok := split(x)
+	if !ok {
+		return false
+	}
+	i := s.find(key)
+	return i < len(s.keys) && s.keys[i] == key && s.conts[i].has(low)
+}
+
+// Add adds x to the set.  It panics if x is not in [0, 2³²).
+func (s *Bitmap) Add(x int) {
+	key, low, ok := split(x)
+	if !ok {
+		panic(fmt.Sprintf("intset: %d out of range of Bitmap", x))
+	}
+	i := s.find(key)
+	if i < len(s.keys) && s.keys[i] == key {
+		s.conts[i] = s.conts[i].add(low)
+		return
+	}
+	s.insert(i, key, arrayContainer{low})
+}
+
+// insert inserts a chunk at index i.
+func (s *Bitmap) insert(i int, key uint16, c container) {
+	s.keys = append(s.keys, 0)
+	copy(s.keys[i+1:], s.keys[i:])
+	s.keys[i] = key
+	s.conts = append(s.conts, nil)
+	copy(s.conts[i+1:], s.conts[i:])
+	s.conts[i] = c
+}
+
+// AddAll adds vals to the set.
+func (s *Bitmap) AddAll(vals ...int) {
+	for _, x := range vals {
+		s.Add(x)
+	}
+}
+
+// Remove removes x from the set, if present.
+func (s *Bitmap) Remove(x int) {
+	key, low, ok := split(x)
+	if !ok {
+		return
+	}
+	i := s.find(key)
+	if i == len(s.keys) || s.keys[i] != key {
+		return
+	}
+	if c := s.conts[i].remove(low); c != nil {
+		s.conts[i] = c
+	} else {
+		s.keys = append(s.keys[:i], s.keys[i+1:]...)
+		s.conts = append(s.conts[:i], s.conts[i+1:]...)
+	}
+}
+
+// Clear removes all elements from the set.
+func (s *Bitmap) Clear() { s.keys, s.conts = nil, nil }
+
+// Copy returns a copy of the set.
+func (s *Bitmap) Copy() *Bitmap {
+	t := &Bitmap{keys: append([]uint16(nil), s.keys...)}
+	for _, c := range s.conts {
+		t.conts = append(t.conts, c.clone())
+	}
+	return t
+}
+
+// Optimize changes the representation of each chunk of the set to
+// the most compact.  Adding and removing elements one at a time never
+// forms runs; the set operations, and Optimize, do.
+func (s *Bitmap) Optimize() {
+	for i, c := range s.conts {
+		s.conts[i] = fromBitmap(c.bitmap())
+	}
+}
+
+// Len returns the number of elements of the set.
+func (s *Bitmap) Len() int {
+	n := 0
+	for _, c := range s.conts {
+		n += c.card()
+	}
+	return n
+}
+
+// merge sets s to the result of applying op to the chunks of s and
+// t, as in combine.  op(0, 0) must be 0.
+func (s *Bitmap) merge(t *Bitmap, op func(x, y uint64) uint64) {
+	var keys []uint16
+	var conts []container
+	emit := func(key uint16, c container) {
+		if c != nil {
+			keys = append(keys, key)
+			conts = append(conts, c)
+		}
+	}
+	i, j := 0, 0
+	for i < len(s.keys) || j < len(t.keys) {
+		switch {
+		case j == len(t.keys) || i < len(s.keys) && s.keys[i] < t.keys[j]:
+			if op(1, 0) != 0 {
+				emit(s.keys[i], s.conts[i])
+			}
+			i++
+		case i == len(s.keys) || t.keys[j] < s.keys[i]:
+			if op(0, 1) != 0 {
+				emit(t.keys[j], t.conts[j].clone())
+			}
+			j++
+		default:
+			emit(s.keys[i], combine(s.conts[i], t.conts[j], op))
+			i++
+			j++
+		}
+	}
+	s.keys, s.conts = keys, conts
+}
+
+// UnionWith sets s to the union of s and t.
+func (s *Bitmap) UnionWith(t *Bitmap) {
+	s.merge(t, func(x, y uint64) uint64 { return x | y })
+}
+
+// IntersectWith sets s to the intersection of s and t.
+func (s *Bitmap) IntersectWith(t *Bitmap) {
+	s.merge(t, func(x, y uint64) uint64 { return x & y })
+}
+
+// DifferenceWith sets s to the difference of s and t, the elements
+// of s that are not in t.
+func (s *Bitmap) DifferenceWith(t *Bitmap) {
+	s.merge(t, func(x, y uint64) uint64 { return x &^ y })
+}
+
+// SymmetricDifference sets s to the symmetric difference of s and t,
+// the elements in one set but not both.
+func (s *Bitmap) SymmetricDifference(t *Bitmap) {
+	s.merge(t, func(x, y uint64) uint64 { return x ^ y })
+}
+
+// Elems returns the elements of the set, in increasing order.
+func (s *Bitmap) Elems() []int {
+	elems := make([]int, 0, s.Len())
+	s.Each(func(x int) bool {
+		elems = append(elems, x)
+		return true
+	})
+	return elems
+}
+
+// Each calls f for each element of the set, in increasing order,
+// until f returns false.  f must not change the set.
+func (s *Bitmap) Each(f func(x int) bool) {
+	for i, c := range s.conts {
+		key := s.keys[i]
+		if !c.each(func(low uint16) bool { return f(join(key, low)) }) {
+			return
+		}
+	}
+}
+
+// Next returns the least element of the set that is not less than x,
+// and reports whether there is one.
+func (s *Bitmap) Next(x int) (int, bool) {
+	if x < 0 {
+		x = 0
+	}
+	key, low, ok := split(x)
+	if !ok {
+		return 0, false
+	}
+	for i := s.find(key); i < len(s.keys); i++ {
+		if s.keys[i] > key {
+			low = 0
+		}
+		if y, ok := s.conts[i].next(low); ok {
+			return join(s.keys[i], y), true
+		}
+	}
+	return 0, false
+}
+
+// Min returns the least element of the set, and reports whether the
+// set is non-empty.
+func (s *Bitmap) Min() (int, bool) { return s.Next(0) }
+
+// Max returns the greatest element of the set, and reports whether
+// the set is non-empty.
+func (s *Bitmap) Max() (int, bool) {
+	n := len(s.keys)
+	if n == 0 {
+		return 0, false
+	}
+	return join(s.keys[n-1], s.conts[n-1].max()), true
+}
+
+// Rank returns the number of elements of the set less than x.
+func (s *Bitmap) Rank(x int) int {
+	if x <= 0 {
+		return 0
+	}
+	key, low, ok := split(x)
+	if !ok {
+		return s.Len()
+	}
+	n := 0
+	for i, k := range s.keys {
+		if k > key {
+			break
+		}
+		if k == key {
+			return n + s.conts[i].rank(low)
+		}
+		n += s.conts[i].card()
+	}
+	return n
+}
+
+// Select returns the element of the set of rank i, that is, the
+// (i+1)th least, and reports whether there is one.
+func (s *Bitmap) Select(i int) (int, bool) {
+	if i < 0 {
+		return 0, false
+	}
+	for j, c := range s.conts {
+		if n := c.card(); i >= n {
+			i -= n
+			continue
+		}
+		var x int
+		c.each(func(low uint16) bool {
+			x = join(s.keys[j], low)
+			i--
+			return i >= 0
+		})
+		return x, true
+	}
+	return 0, false
+}
+
+// IsSubset reports whether every element of s is an element of t.
+func (s *Bitmap) IsSubset(t *Bitmap) bool {
+	for i, key := range s.keys {
+		j := t.find(key)
+		if j == len(t.keys) || t.keys[j] != key {
+			return false
+		}
+		if combine(s.conts[i], t.conts[j], func(x, y uint64) uint64 { return x &^ y }) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Equal reports whether s and t have the same elements.
+func (s *Bitmap) Equal(t *Bitmap) bool {
+	return len(s.keys) == len(t.keys) && s.Len() == t.Len() && s.IsSubset(t)
+}
+
+// String returns the set as a string of the form "{1 2 3}".
+func (s *Bitmap) String() string {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	s.Each(func(x int) bool {
+		if buf.Len() > len("{") {
+			buf.WriteByte(' ')
+		}
+		fmt.Fprintf(&buf, "%d", x)
+		return true
+	})
+	buf.WriteByte('}')
+	return buf.String()
+}
+
+// The binary encoding of a Bitmap is portable: all integers are
+// little-endian.  It is the magic string "RBM1", the number of chunks
+// as a uint32, then for each chunk in increasing order its key as a
+// uint16, its kind as a byte, and its contents:
+//
+//	array:  the number of elements as a uint16, then each element
+//	bitmap: 1024 uint64 words, least significant bit first
+//	runs:   the number of runs as a uint16, then the first and last
+//	        element of each run
+const bitmapMagic = "RBM1"
+
+const (
+	kindArray = iota
+	kindBitmap
+	kindRuns
+)
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (s *Bitmap) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(bitmapMagic)
+	le := binary.LittleEndian
+	binary.Write(&buf, le, uint32(len(s.keys)))
+	for i, c := range s.conts {
+		binary.Write(&buf, le, s.keys[i])
+		switch c := c.(type) {
+		case arrayContainer:
+			buf.WriteByte(kindArray)
+			binary.Write(&buf, le, uint16(len(c)))
+			binary.Write(&buf, le, []uint16(c))
+		case *bitmapContainer:
+			buf.WriteByte(kindBitmap)
+			binary.Write(&buf, le, c.words[:])
+		case *runContainer:
+			buf.WriteByte(kindRuns)
+			binary.Write(&buf, le, uint16(len(c.runs)))
+			for _, r := range c.runs {
+				binary.Write(&buf, le, [2]uint16{r.start, r.last})
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+var errBitmapFormat = errors.New("intset: invalid Bitmap encoding")
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+// It checks that data is a valid encoding.
+func (s *Bitmap) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	le := binary.LittleEndian
+	magic := make([]byte, len(bitmapMagic))
+	var n uint32
+	if _, err := r.Read(magic); err != nil || string(magic) != bitmapMagic {
+		return errBitmapFormat
+	}
+	if binary.Read(r, le, &n) != nil || int64(n)*3 > int64(r.Len()) {
+		return errBitmapFormat
+	}
+	var t Bitmap
+	for i := 0; i < int(n); i++ {
+		var key uint16
+		if binary.Read(r, le, &key) != nil || i > 0 && key <= t.keys[i-1] || join(key, 0) < 0 {
+			return errBitmapFormat
+		}
+		kind, err := r.ReadByte()
+		if err != nil {
+			return errBitmapFormat
+		}
+		var c container
+		switch kind {
+		case kindArray:
+			c, err = readArray(r)
+		case kindBitmap:
+			c, err = readBitmap(r)
+		case kindRuns:
+			c, err = readRuns(r)
+		default:
+			err = errBitmapFormat
+		}
+		if err != nil {
+			return err
+		}
+		t.keys = append(t.keys, key)
+		t.conts = append(t.conts, c)
+	}
+	if r.Len() != 0 {
+		return errBitmapFormat
+	}
+	*s = t
+	return nil
+}
+
+func readArray(r *bytes.Reader) (container, error) {
+	var n uint16
+	if binary.Read(r, binary.LittleEndian, &n) != nil || n == 0 || n > arrayMax || 2*int(n) > r.Len() {
+		return nil, errBitmapFormat
+	}
+	c := make(arrayContainer, n)
+	binary.Read(r, binary.LittleEndian, []uint16(c))
+	for i := 1; i < len(c); i++ {
+		if c[i] <= c[i-1] {
+			return nil, errBitmapFormat
+		}
+	}
+	return c, nil
+}
+
+func readBitmap(r *bytes.Reader) (container, error) {
+	var words [1024]uint64
+	if binary.Read(r, binary.LittleEndian, words[:]) != nil {
+		return nil, errBitmapFormat
+	}
+	c := newBitmapContainer(&words)
+	if c.n == 0 {
+		return nil, errBitmapFormat
+	}
+	return c, nil
+}
+
+func readRuns(r *bytes.Reader) (container, error) {
+	var n uint16
+	if binary.Read(r, binary.LittleEndian, &n) != nil || n == 0 || n > runMax || 4*int(n) > r.Len() {
+		return nil, errBitmapFormat
+	}
+	ends := make([]uint16, 2*n)
+	binary.Read(r, binary.LittleEndian, ends)
+	c := new(runContainer)
+	for i := 0; i < len(ends); i += 2 {
+		start, last := ends[i], ends[i+1]
+		if start > last || i > 0 && int(start) <= int(ends[i-1])+1 {
+			return nil, errBitmapFormat
+		}
+		c.runs = append(c.runs, run{start, last})
+		c.n += int(last-start) + 1
+	}
+	return c, nil
+}
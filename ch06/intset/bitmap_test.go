@@ -0,0 +1,227 @@
+package intset
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+// randomBitmap returns a Bitmap and the equivalent IntSet of
+// elements below 1<<20, clustered so that chunks of every kind arise.
+func randomBitmap(rng *rand.Rand) (*Bitmap, *IntSet) {
+	b, s := new(Bitmap), new(IntSet)
+	for i := rng.Intn(6); i > 0; i-- {
+		base := rng.Intn(16) << 16
+		switch rng.Intn(3) {
+		case 0: // sparse
+			for j := rng.Intn(100); j > 0; j-- {
+				x := base + rng.Intn(1<<16)
+				b.Add(x)
+				s.Add(x)
+			}
+		case 1: // dense
+			for j := 5000 + rng.Intn(5000); j > 0; j-- {
+				x := base + rng.Intn(1<<16)
+				b.Add(x)
+				s.Add(x)
+			}
+		case 2: // runs
+			for j := rng.Intn(20); j > 0; j-- {
+				start := base + rng.Intn(1<<16-1000)
+				for x := start; x < start+rng.Intn(1000); x++ {
+					b.Add(x)
+					s.Add(x)
+				}
+			}
+		}
+	}
+	if rng.Intn(2) == 0 {
+		b.Optimize()
+	}
+	return b, s
+}
+
+// same reports whether b and s have the same elements, checking all
+// the queries.
+func same(t *testing.T, op string, b *Bitmap, s *IntSet) {
+	t.Helper()
+	if b.Len() != s.Len() {
+		t.Fatalf("%s: Len() = %d, want %d", op, b.Len(), s.Len())
+	}
+	if fmt.Sprint(b.Elems()) != fmt.Sprint(s.Elems()) {
+		t.Fatalf("%s: Elems differ", op)
+	}
+	bmin, bok := b.Min()
+	smin, sok := s.Min()
+	bmax, _ := b.Max()
+	smax, _ := s.Max()
+	if bmin != smin || bok != sok || bmax != smax {
+		t.Fatalf("%s: Min, Max = %d, %d, want %d, %d", op, bmin, bmax, smin, smax)
+	}
+	for _, x := range []int{-1, 0, 1, 65535, 65536, 100000, 1 << 19, smax, smax + 1} {
+		if b.Has(x) != s.Has(x) || b.Rank(x) != s.Rank(x) {
+			t.Fatalf("%s: Has(%d), Rank(%d) = %t, %d, want %t, %d",
+				op, x, x, b.Has(x), b.Rank(x), s.Has(x), s.Rank(x))
+		}
+		bn, bok := b.Next(x)
+		sn, sok := s.Next(x)
+		if bn != sn || bok != sok {
+			t.Fatalf("%s: Next(%d) = %d, %t, want %d, %t", op, x, bn, bok, sn, sok)
+		}
+	}
+	for _, i := range []int{0, s.Len() / 3, s.Len() - 1, s.Len()} {
+		bx, bok := b.Select(i)
+		sx, sok := s.Select(i)
+		if bx != sx || bok != sok {
+			t.Fatalf("%s: Select(%d) = %d, %t, want %d, %t", op, i, bx, bok, sx, sok)
+		}
+	}
+}
+
+// TestBitmap compares Bitmap with IntSet.
+func TestBitmap(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		b1, s1 := randomBitmap(rng)
+		b2, s2 := randomBitmap(rng)
+		same(t, "random", b1, s1)
+
+		for _, op := range []struct {
+			name string
+			b    func(x, y *Bitmap)
+			s    func(x, y *IntSet)
+		}{
+			{"UnionWith", (*Bitmap).UnionWith, (*IntSet).UnionWith},
+			{"IntersectWith", (*Bitmap).IntersectWith, (*IntSet).IntersectWith},
+			{"DifferenceWith", (*Bitmap).DifferenceWith, (*IntSet).DifferenceWith},
+			{"SymmetricDifference", (*Bitmap).SymmetricDifference, (*IntSet).SymmetricDifference},
+		} {
+			b, s := b1.Copy(), s1.Copy()
+			op.b(b, b2)
+			op.s(s, s2)
+			same(t, op.name, b, s)
+			if b.IsSubset(b2) != s.IsSubset(s2) || b.IsSubset(b1) != s.IsSubset(s1) {
+				t.Fatalf("%s: IsSubset differs", op.name)
+			}
+		}
+		if b1.Equal(b2) != s1.Equal(s2) || !b1.Equal(b1.Copy()) {
+			t.Fatal("Equal differs")
+		}
+
+		// Remove half of the elements.
+		b, s := b1.Copy(), s1.Copy()
+		for i, x := range s1.Elems() {
+			if i%2 == 0 || x%7 == 0 {
+				b.Remove(x)
+				s.Remove(x)
+			}
+		}
+		same(t, "Remove", b, s)
+		if !b1.Equal(b1.Copy()) || b.Equal(b1) != s.Equal(s1) {
+			t.Fatal("Equal differs after Remove")
+		}
+	}
+}
+
+func TestBitmapRuns(t *testing.T) {
+	// Add and remove elements one at a time in a run container,
+	// splitting and joining runs.
+	var b Bitmap
+	var s IntSet
+	for x := 100; x < 200; x++ {
+		b.Add(x)
+		s.Add(x)
+	}
+	b.Optimize()
+	if _, ok := b.conts[0].(*runContainer); !ok {
+		t.Fatalf("chunk of one run is %T", b.conts[0])
+	}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		x := 50 + rng.Intn(200)
+		if rng.Intn(2) == 0 {
+			b.Add(x)
+			s.Add(x)
+		} else {
+			b.Remove(x)
+			s.Remove(x)
+		}
+		same(t, fmt.Sprint("step ", i), &b, &s)
+	}
+}
+
+func TestBitmapSparse(t *testing.T) {
+	if strconv.IntSize < 64 {
+		t.Skip("elements beyond 2³¹ need 64-bit ints")
+	}
+	var top uint64 = 1<<32 - 1 // a variable, so that this compiles where int is 32 bits
+	var b Bitmap
+	b.AddAll(0, 1<<30, int(top))
+	if got := b.String(); got != "{0 1073741824 4294967295}" {
+		t.Errorf("String() = %s", got)
+	}
+	if b.Has(-1) || b.Has(int(top+1)) {
+		t.Error("Has out of range")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Add(1<<32) did not panic")
+		}
+	}()
+	b.Add(int(top + 1))
+}
+
+func TestBitmapBinary(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		b, s := randomBitmap(rng)
+		data, err := b.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b2 Bitmap
+		if err := b2.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		same(t, "UnmarshalBinary", &b2, s)
+
+		// Every truncation of the encoding is rejected.
+		for n := 0; n < len(data); n += 1 + n/8 {
+			if err := b2.UnmarshalBinary(data[:n]); err == nil {
+				t.Fatalf("UnmarshalBinary accepted %d of %d bytes", n, len(data))
+			}
+		}
+	}
+
+	// The encoding is fixed.
+	var b Bitmap
+	b.AddAll(1, 2, 1<<16)
+	data, _ := b.MarshalBinary()
+	want := "RBM1" + "\x02\x00\x00\x00" +
+		"\x00\x00" + "\x00" + "\x02\x00" + "\x01\x00\x02\x00" +
+		"\x01\x00" + "\x00" + "\x01\x00" + "\x00\x00"
+	if string(data) != want {
+		t.Errorf("MarshalBinary() = %q, want %q", data, want)
+	}
+}
+
+func BenchmarkSparse(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	vals := make([]int, 1000)
+	for i := range vals {
+		vals[i] = rng.Intn(1 << 24)
+	}
+	b.Run("IntSet", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var s IntSet
+			s.AddAll(vals...)
+		}
+	})
+	b.Run("Bitmap", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var s Bitmap
+			s.AddAll(vals...)
+		}
+	})
+}
@@ -0,0 +1,422 @@
+package intset
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// A container holds the low 16 bits of the elements of a Bitmap
+// that share their high 16 bits.  It is one of three kinds, whichever
+// is smallest for its contents: a sorted array, for few elements; a
+// bitmap of 2¹⁶ bits, for many; or a list of runs of consecutive
+// elements, for elements that are clustered.
+//
+// A container is never empty.  Methods that change a container
+// return the container that replaces it, which may be of another
+// kind, or nil if it has become empty.
+type container interface {
+	has(x uint16) bool
+	add(x uint16) container
+	remove(x uint16) container
+	card() int
+	// next returns the least element not less than x.
+	next(x uint16) (uint16, bool)
+	max() uint16
+	// rank returns the number of elements less than x.
+	rank(x uint16) int
+	// each calls f for each element in increasing order, and
+	// reports false if f did.
+	each(f func(x uint16) bool) bool
+	// bitmap returns the elements as a bitmap, which the caller
+	// must not change.
+	bitmap() *[1024]uint64
+	clone() container
+}
+
+// arrayMax is the greatest number of elements in an array container;
+// any more would take more space than a bitmap.
+const arrayMax = 4096
+
+// runMax is the greatest number of runs in a run container.
+const runMax = 2048
+
+// An arrayContainer is a sorted list of elements.
+type arrayContainer []uint16
+
+// search returns the index of the least element not less than x.
+func (c arrayContainer) search(x uint16) int {
+	return sort.Search(len(c), func(i int) bool { return c[i] >= x })
+}
+
+func (c arrayContainer) has(x uint16) bool {
+	i := c.search(x)
+	return i < len(c) && c[i] == x
+}
+
+func (c arrayContainer) add(x uint16) container {
+	i := c.search(x)
+	if i < len(c) && c[i] == x {
+		return c
+	}
+	if len(c) == arrayMax {
+		b := newBitmapContainer(c.bitmap())
+		return b.add(x)
+	}
+	c = append(c, 0)
+	copy(c[i+1:], c[i:])
+	c[i] = x
+	return c
+}
+
+func (c arrayContainer) remove(x uint16) container {
+	i := c.search(x)
+	if i == len(c) || c[i] != x {
+		return c
+	}
+	if len(c) == 1 {
+		return nil
+	}
+	return append(c[:i], c[i+1:]...)
+}
+
+func (c arrayContainer) card() int { return len(c) }
+
+func (c arrayContainer) next(x uint16) (uint16, bool) {
+	if i := c.search(x); i < len(c) {
+		return c[i], true
+	}
+	return 0, false
+}
+
+func (c arrayContainer) max() uint16       { return c[len(c)-1] }
+func (c arrayContainer) rank(x uint16) int { return c.search(x) }
+
+func (c arrayContainer) each(f func(x uint16) bool) bool {
+	for _, x := range c {
+		if !f(x) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c arrayContainer) bitmap() *[1024]uint64 {
+	var words [1024]uint64
+	for _, x := range c {
+		words[x/64] |= 1 << (x % 64)
+	}
+	return &words
+}
+
+func (c arrayContainer) clone() container {
+	return append(arrayContainer(nil), c...)
+}
+
+// A bitmapContainer is a bit vector of 2¹⁶ bits.
+type bitmapContainer struct {
+	words [1024]uint64
+	n     int // number of bits set
+}
+
+func newBitmapContainer(words *[1024]uint64) *bitmapContainer {
+	c := &bitmapContainer{words: *words}
+	for _, w := range c.words {
+		c.n += bits.OnesCount64(w)
+	}
+	return c
+}
+
+func (c *bitmapContainer) has(x uint16) bool {
+	return c.words[x/64]&(1<<(x%64)) != 0
+}
+
+func (c *bitmapContainer) add(x uint16) container {
+	if !c.has(x) {
+		c.words[x/64] |= 1 << (x % 64)
+		c.n++
+	}
+	return c
+}
+
+func (c *bitmapContainer) remove(x uint16) container {
+	if !c.has(x) {
+		return c
+	}
+	c.words[x/64] &^= 1 << (x % 64)
+	c.n--
+	if c.n <= arrayMax {
+		return c.array()
+	}
+	return c
+}
+
+// array returns the elements as an array container.
+func (c *bitmapContainer) array() arrayContainer {
+	a := make(arrayContainer, 0, c.n)
+	c.each(func(x uint16) bool {
+		a = append(a, x)
+		return true
+	})
+	return a
+}
+
+func (c *bitmapContainer) card() int { return c.n }
+
+func (c *bitmapContainer) next(x uint16) (uint16, bool) {
+	i := int(x / 64)
+	w := c.words[i] &^ (1<<(x%64) - 1) // ignore bits below x
+	for {
+		if w != 0 {
+			return uint16(64*i + bits.TrailingZeros64(w)), true
+		}
+		if i++; i == len(c.words) {
+			return 0, false
+		}
+		w = c.words[i]
+	}
+}
+
+func (c *bitmapContainer) max() uint16 {
+	for i := len(c.words) - 1; ; i-- {
+		if w := c.words[i]; w != 0 {
+			return uint16(64*i + 63 - bits.LeadingZeros64(w))
+		}
+	}
+}
+
+func (c *bitmapContainer) rank(x uint16) int {
+	n := 0
+	for _, w := range c.words[:x/64] {
+		n += bits.OnesCount64(w)
+	}
+	return n + bits.OnesCount64(c.words[x/64]&(1<<(x%64)-1))
+}
+
+func (c *bitmapContainer) each(f func(x uint16) bool) bool {
+	for i, w := range c.words {
+		for w != 0 {
+			if !f(uint16(64*i + bits.TrailingZeros64(w))) {
+				return false
+			}
+			w &= w - 1 // clear lowest set bit
+		}
+	}
+	return true
+}
+
+func (c *bitmapContainer) bitmap() *[1024]uint64 { return &c.words }
+
+func (c *bitmapContainer) clone() container {
+	d := *c
+	return &d
+}
+
+// A run is a sequence of consecutive elements.
+type run struct{ start, last uint16 }
+
+// A runContainer is a sorted list of disjoint, non-adjacent runs.
+type runContainer struct {
+	runs []run
+	n    int // number of elements
+}
+
+// search returns the index of the first run that ends at or after x.
+func (c *runContainer) search(x uint16) int {
+	return sort.Search(len(c.runs), func(i int) bool { return c.runs[i].last >= x })
+}
+
+func (c *runContainer) has(x uint16) bool {
+	i := c.search(x)
+	return i < len(c.runs) && c.runs[i].start <= x
+}
+
+func (c *runContainer) add(x uint16) container {
+	i := c.search(x)
+	if i < len(c.runs) && c.runs[i].start <= x {
+		return c // already present
+	}
+	joinPrev := i > 0 && c.runs[i-1].last+1 == x
+	joinNext := i < len(c.runs) && c.runs[i].start-1 == x
+	switch {
+	case joinPrev && joinNext:
+		c.runs[i-1].last = c.runs[i].last
+		c.runs = append(c.runs[:i], c.runs[i+1:]...)
+	case joinPrev:
+		c.runs[i-1].last = x
+	case joinNext:
+		c.runs[i].start = x
+	default:
+		if len(c.runs) == runMax {
+			return fromBitmap(c.bitmap()).add(x)
+		}
+		c.runs = append(c.runs, run{})
+		copy(c.runs[i+1:], c.runs[i:])
+		c.runs[i] = run{x, x}
+	}
+	c.n++
+	return c
+}
+
+func (c *runContainer) remove(x uint16) container {
+	i := c.search(x)
+	if i == len(c.runs) || c.runs[i].start > x {
+		return c // absent
+	}
+	if c.n == 1 {
+		return nil
+	}
+	r := c.runs[i]
+	switch {
+	case r.start == r.last:
+		c.runs = append(c.runs[:i], c.runs[i+1:]...)
+	case x == r.start:
+		c.runs[i].start++
+	case x == r.last:
+		c.runs[i].last--
+	default: // split the run
+		if len(c.runs) == runMax {
+			return fromBitmap(c.bitmap()).remove(x)
+		}
+		c.runs = append(c.runs, run{})
+		copy(c.runs[i+2:], c.runs[i+1:])
+		c.runs[i].last = x - 1
+		c.runs[i+1] = run{x + 1, r.last}
+	}
+	c.n--
+	return c
+}
+
+func (c *runContainer) card() int { return c.n }
+
+func (c *runContainer) next(x uint16) (uint16, bool) {
+	i := c.search(x)
+	if i == len(c.runs) {
+		return 0, false
+	}
+	if r := c.runs[i]; r.start > x {
+		return r.start, true
+	}
+	return x, true
+}
+
+func (c *runContainer) max() uint16 { return c.runs[len(c.runs)-1].last }
+
+func (c *runContainer) rank(x uint16) int {
+	n := 0
+	for _, r := range c.runs {
+		if r.start >= x {
+			break
+		}
+		if r.last >= x {
+			return n + int(x-r.start)
+		}
+		n += int(r.last-r.start) + 1
+	}
+	return n
+}
+
+func (c *runContainer) each(f func(x uint16) bool) bool {
+	for _, r := range c.runs {
+		for x := int(r.start); x <= int(r.last); x++ {
+			if !f(uint16(x)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (c *runContainer) bitmap() *[1024]uint64 {
+	var words [1024]uint64
+	for _, r := range c.runs {
+		for x := int(r.start); x <= int(r.last); x++ {
+			words[x/64] |= 1 << uint(x%64)
+		}
+	}
+	return &words
+}
+
+func (c *runContainer) clone() container {
+	return &runContainer{append([]run(nil), c.runs...), c.n}
+}
+
+// fromBitmap returns the smallest container holding the elements of
+// words, or nil if there are none.
+func fromBitmap(words *[1024]uint64) container {
+	var n, nruns int
+	var carry uint64 // the highest bit of the previous word
+	for _, w := range words {
+		n += bits.OnesCount64(w)
+		nruns += bits.OnesCount64(w &^ (w<<1 | carry)) // bits that start runs
+		carry = w >> 63
+	}
+	switch {
+	case n == 0:
+		return nil
+	case 4*nruns < 2*n && 4*nruns < 8192:
+		c := &runContainer{n: n}
+		start := -1
+		for x := 0; x <= 1<<16; x++ {
+			set := x < 1<<16 && words[x/64]&(1<<uint(x%64)) != 0
+			if set && start < 0 {
+				start = x
+			} else if !set && start >= 0 {
+				c.runs = append(c.runs, run{uint16(start), uint16(x - 1)})
+				start = -1
+			}
+		}
+		return c
+	case n <= arrayMax:
+		return newBitmapContainer(words).array()
+	default:
+		return newBitmapContainer(words)
+	}
+}
+
+// combine returns the container holding the elements x for which
+// op(bit(x in a), bit(x in b)) has its lowest bit set, or nil if
+// there are none.  op is applied to whole words of bits at once.
+func combine(a, b container, op func(x, y uint64) uint64) container {
+	if a, ok := a.(arrayContainer); ok {
+		if b, ok := b.(arrayContainer); ok {
+			return combineArrays(a, b, op)
+		}
+	}
+	aw, bw := a.bitmap(), b.bitmap()
+	var words [1024]uint64
+	for i := range words {
+		words[i] = op(aw[i], bw[i])
+	}
+	return fromBitmap(&words)
+}
+
+// combineArrays is combine for two arrays, by merging them.
+func combineArrays(a, b arrayContainer, op func(x, y uint64) uint64) container {
+	var c arrayContainer
+	for i, j := 0, 0; i < len(a) || j < len(b); {
+		var x uint16
+		var inA, inB uint64
+		switch {
+		case j == len(b) || i < len(a) && a[i] < b[j]:
+			x, inA = a[i], 1
+			i++
+		case i == len(a) || b[j] < a[i]:
+			x, inB = b[j], 1
+			j++
+		default:
+			x, inA, inB = a[i], 1, 1
+			i++
+			j++
+		}
+		if op(inA, inB)&1 != 0 {
+			c = append(c, x)
+		}
+	}
+	if len(c) == 0 {
+		return nil
+	}
+	if len(c) > arrayMax {
+		return newBitmapContainer(c.bitmap())
+	}
+	return c
+}
@@ -0,0 +1,196 @@
+package intset
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// The binary encoding of an IntSet is the magic string "IS64", the
+// number of words as a little-endian uint32, then the words of the
+// bit vector, each a little-endian uint64, least significant bit
+// first.  The words are 8-byte aligned if the encoding is, so a View
+// may read them in place.
+const (
+	intSetMagic  = "IS64"
+	intSetHeader = 8
+)
+
+// MarshalBinary implements encoding.BinaryMarshaler.  The gob package
+// uses it too.
+func (s *IntSet) MarshalBinary() ([]byte, error) {
+	words := s.words
+	for len(words) > 0 && words[len(words)-1] == 0 {
+		words = words[:len(words)-1]
+	}
+	data := make([]byte, intSetHeader+8*len(words))
+	copy(data, intSetMagic)
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(words)))
+	for i, word := range words {
+		binary.LittleEndian.PutUint64(data[intSetHeader+8*i:], word)
+	}
+	return data, nil
+}
+
+var errIntSetFormat = errors.New("intset: invalid IntSet encoding")
+
+// checkEncoding checks the header of an encoded IntSet and returns
+// the number of words it holds.
+func checkEncoding(data []byte) (int, error) {
+	if len(data) < intSetHeader || string(data[:4]) != intSetMagic {
+		return 0, errIntSetFormat
+	}
+	// Compare in 64 bits, since 8 times the count may overflow an int.
+	n := binary.LittleEndian.Uint32(data[4:])
+	if uint64(len(data)-intSetHeader) != 8*uint64(n) {
+		return 0, errIntSetFormat
+	}
+	return int(n), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.  The gob
+// package uses it too.
+func (s *IntSet) UnmarshalBinary(data []byte) error {
+	n, err := checkEncoding(data)
+	if err != nil {
+		return err
+	}
+	words := make([]uint64, n)
+	for i := range words {
+		words[i] = binary.LittleEndian.Uint64(data[intSetHeader+8*i:])
+	}
+	s.words = words
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.  A set is encoded as an
+// array of its elements, in increasing order.
+func (s *IntSet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Elems())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *IntSet) UnmarshalJSON(data []byte) error {
+	var elems []int
+	if err := json.Unmarshal(data, &elems); err != nil {
+		return err
+	}
+	var t IntSet
+	for _, x := range elems {
+		if x < 0 {
+			return fmt.Errorf("intset: negative element %d", x)
+		}
+		t.Add(x)
+	}
+	*s = t
+	return nil
+}
+
+// A View is a read-only IntSet that reads the binary encoding of a
+// set in place, without copying or decoding it, so that a set in a
+// memory-mapped file may be used at once.
+type View struct {
+	words []byte // the encoded words
+	close func() error
+}
+
+// NewView returns a view of the set encoded in data, which must not
+// change while the view is in use.
+func NewView(data []byte) (*View, error) {
+	if _, err := checkEncoding(data); err != nil {
+		return nil, err
+	}
+	return &View{words: data[intSetHeader:]}, nil
+}
+
+// Close releases the file underlying a view made by OpenView.
+// It does nothing for a view made by NewView.
+func (v *View) Close() error {
+	if v.close == nil {
+		return nil
+	}
+	err := v.close()
+	v.words, v.close = nil, nil
+	return err
+}
+
+func (v *View) nwords() int { return len(v.words) / 8 }
+
+func (v *View) word(i int) uint64 {
+	return binary.LittleEndian.Uint64(v.words[8*i:])
+}
+
+// Has reports whether the set contains the non-negative value x.
+func (v *View) Has(x int) bool {
+	word, bit := x/64, uint(x%64)
+	return word < v.nwords() && v.word(word)&(1<<bit) != 0
+}
+
+// Len returns the number of elements of the set.
+func (v *View) Len() int {
+	n := 0
+	for i := 0; i < v.nwords(); i++ {
+		n += bits.OnesCount64(v.word(i))
+	}
+	return n
+}
+
+// Each calls f for each element of the set, in increasing order,
+// until f returns false.
+func (v *View) Each(f func(x int) bool) {
+	for i := 0; i < v.nwords(); i++ {
+		for word := v.word(i); word != 0; word &= word - 1 {
+			if !f(64*i + bits.TrailingZeros64(word)) {
+				return
+			}
+		}
+	}
+}
+
+// Elems returns the elements of the set, in increasing order.
+func (v *View) Elems() []int {
+	var elems []int
+	v.Each(func(x int) bool {
+		elems = append(elems, x)
+		return true
+	})
+	return elems
+}
+
+// IntersectsWith reports whether the view and s have an element in
+// common.
+func (v *View) IntersectsWith(s *IntSet) bool {
+	for i := 0; i < v.nwords() && i < len(s.words); i++ {
+		if v.word(i)&s.words[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// IntSet returns a copy of the set, which may be changed.
+func (v *View) IntSet() *IntSet {
+	s := &IntSet{words: make([]uint64, v.nwords())}
+	for i := range s.words {
+		s.words[i] = v.word(i)
+	}
+	return s
+}
+
+// String returns the set as a string of the form "{1 2 3}".
+func (v *View) String() string {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	v.Each(func(x int) bool {
+		if buf.Len() > len("{") {
+			buf.WriteByte(' ')
+		}
+		fmt.Fprintf(&buf, "%d", x)
+		return true
+	})
+	buf.WriteByte('}')
+	return buf.String()
+}
@@ -0,0 +1,119 @@
+package intset
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+func TestBinary(t *testing.T) {
+	var s IntSet
+	s.AddAll(1, 64, 200)
+	s.Add(1000)
+	s.Remove(1000) // trailing zero words are not encoded
+	data, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "IS64\x04\x00\x00\x00" +
+		"\x02\x00\x00\x00\x00\x00\x00\x00" +
+		"\x01\x00\x00\x00\x00\x00\x00\x00" +
+		"\x00\x00\x00\x00\x00\x00\x00\x00" +
+		"\x00\x01\x00\x00\x00\x00\x00\x00"
+	if string(data) != want {
+		t.Errorf("MarshalBinary() = %q, want %q", data, want)
+	}
+	var u IntSet
+	if err := u.UnmarshalBinary(data); err != nil || !u.Equal(&s) {
+		t.Errorf("UnmarshalBinary: %s, %v, want %s", &u, err, &s)
+	}
+	for _, bad := range []string{
+		"", "IS64", "IS64\x01\x00\x00\x00", "XS64\x00\x00\x00\x00", want[:len(want)-1],
+		// Counts whose size in bytes overflows a 32-bit int.
+		"IS64\x00\x00\x00\x20", "IS64\xff\xff\xff\xff",
+	} {
+		if err := u.UnmarshalBinary([]byte(bad)); err == nil {
+			t.Errorf("UnmarshalBinary(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestGobJSON(t *testing.T) {
+	type flags struct {
+		Name    string
+		Enabled *IntSet
+	}
+	var s IntSet
+	s.AddAll(3, 5, 700)
+	in := flags{"beta", &s}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(in); err != nil {
+		t.Fatal(err)
+	}
+	var out flags
+	if err := gob.NewDecoder(&buf).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != "beta" || !out.Enabled.Equal(&s) {
+		t.Errorf("gob: got %v %s, want %v %s", out.Name, out.Enabled, in.Name, &s)
+	}
+
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"Name":"beta","Enabled":[3,5,700]}`; string(data) != want {
+		t.Errorf("json.Marshal = %s, want %s", data, want)
+	}
+	out = flags{}
+	if err := json.Unmarshal(data, &out); err != nil || !out.Enabled.Equal(&s) {
+		t.Errorf("json.Unmarshal: %s, %v, want %s", out.Enabled, err, &s)
+	}
+	if err := json.Unmarshal([]byte(`{"Enabled":[1,-2]}`), &out); err == nil {
+		t.Error("json.Unmarshal accepted a negative element")
+	}
+}
+
+func TestView(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	s, _ := randomSet(rng, 500, 10000)
+	data, _ := s.MarshalBinary()
+	name := filepath.Join(t.TempDir(), "set")
+	if err := ioutil.WriteFile(name, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mapped, err := OpenView(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mapped.Close()
+	inMemory, err := NewView(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []*View{mapped, inMemory} {
+		if v.Len() != s.Len() || v.String() != s.String() || !v.IntSet().Equal(s) {
+			t.Errorf("view is %s, want %s", v, s)
+		}
+		for x := 0; x < 11000; x += 7 {
+			if v.Has(x) != s.Has(x) {
+				t.Errorf("view.Has(%d) = %t", x, v.Has(x))
+			}
+		}
+		var u IntSet
+		u.Add(s.Elems()[100])
+		if !v.IntersectsWith(&u) {
+			t.Errorf("view does not intersect %s", &u)
+		}
+	}
+
+	if _, err := NewView(data[:9]); err == nil {
+		t.Error("NewView accepted a truncated encoding")
+	}
+}
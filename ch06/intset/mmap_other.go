@@ -0,0 +1,24 @@
+//go:build !(aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris)
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package intset
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// OpenView returns a view of the set encoded in the named file.  On
+// this platform the file is read into memory rather than mapped.
+// The caller must call Close when done.
+func OpenView(name string) (*View, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	v, err := NewView(data)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	return v, nil
+}
@@ -0,0 +1,37 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package intset
+
+import (
+	"os"
+	"syscall"
+)
+
+// OpenView returns a view of the set encoded in the named file, which
+// it maps into memory read-only.  The caller must call Close when done.
+func OpenView(name string) (*View, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() == 0 || info.Size() != int64(int(info.Size())) {
+		return nil, &os.PathError{Op: "open", Path: name, Err: errIntSetFormat}
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, &os.PathError{Op: "mmap", Path: name, Err: err}
+	}
+	v, err := NewView(data)
+	if err != nil {
+		syscall.Munmap(data)
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	v.close = func() error { return syscall.Munmap(data) }
+	return v, nil
+}
@@ -0,0 +1,203 @@
+package intset
+
+import "math/bits"
+
+// This file completes the set algebra of IntSet (see exercises 6.1
+// to 6.5).  Operations that may empty the highest words of a set
+// trim them, but no operation relies on that.
+
+// Len returns the number of elements of the set.
+func (s *IntSet) Len() int {
+	n := 0
+	for _, word := range s.words {
+		n += bits.OnesCount64(word)
+	}
+	return n
+}
+
+// Remove removes x from the set, if present.
+func (s *IntSet) Remove(x int) {
+	word, bit := x/64, uint(x%64)
+	if x >= 0 && word < len(s.words) {
+		s.words[word] &^= 1 << bit
+		s.trim()
+	}
+}
+
+// Clear removes all elements from the set.
+func (s *IntSet) Clear() { s.words = nil }
+
+// Copy returns a copy of the set.
+func (s *IntSet) Copy() *IntSet {
+	return &IntSet{words: append([]uint64(nil), s.words...)}
+}
+
+// AddAll adds the non-negative values vals to the set.
+func (s *IntSet) AddAll(vals ...int) {
+	for _, x := range vals {
+		s.Add(x)
+	}
+}
+
+// IntersectWith sets s to the intersection of s and t.
+func (s *IntSet) IntersectWith(t *IntSet) {
+	if len(s.words) > len(t.words) {
+		s.words = s.words[:len(t.words)]
+	}
+	for i := range s.words {
+		s.words[i] &= t.words[i]
+	}
+	s.trim()
+}
+
+// DifferenceWith sets s to the difference of s and t, the elements
+// of s that are not in t.
+func (s *IntSet) DifferenceWith(t *IntSet) {
+	for i := range s.words {
+		if i < len(t.words) {
+			s.words[i] &^= t.words[i]
+		}
+	}
+	s.trim()
+}
+
+// SymmetricDifference sets s to the symmetric difference of s and t,
+// the elements in one set but not both.
+func (s *IntSet) SymmetricDifference(t *IntSet) {
+	for i, tword := range t.words {
+		if i < len(s.words) {
+			s.words[i] ^= tword
+		} else {
+			s.words = append(s.words, tword)
+		}
+	}
+	s.trim()
+}
+
+// trim removes zero words from the end of s.words.
+func (s *IntSet) trim() {
+	n := len(s.words)
+	for n > 0 && s.words[n-1] == 0 {
+		n--
+	}
+	s.words = s.words[:n]
+}
+
+// Elems returns the elements of the set, in increasing order.
+func (s *IntSet) Elems() []int {
+	elems := make([]int, 0, s.Len())
+	s.Each(func(x int) bool {
+		elems = append(elems, x)
+		return true
+	})
+	return elems
+}
+
+// Each calls f for each element of the set, in increasing order,
+// until f returns false.  f must not change the set.
+func (s *IntSet) Each(f func(x int) bool) {
+	for i, word := range s.words {
+		for word != 0 {
+			j := bits.TrailingZeros64(word)
+			if !f(64*i + j) {
+				return
+			}
+			word &= word - 1 // clear lowest set bit
+		}
+	}
+}
+
+// Next returns the least element of the set that is not less than x,
+// and reports whether there is one.  It allows a loop over the set
+// that may change it:
+//
+//	for x, ok := s.Next(0); ok; x, ok = s.Next(x + 1) { ... }
+func (s *IntSet) Next(x int) (int, bool) {
+	if x < 0 {
+		x = 0
+	}
+	i := x / 64
+	if i >= len(s.words) {
+		return 0, false
+	}
+	word := s.words[i] &^ (1<<uint(x%64) - 1) // ignore bits below x
+	for {
+		if word != 0 {
+			return 64*i + bits.TrailingZeros64(word), true
+		}
+		if i++; i == len(s.words) {
+			return 0, false
+		}
+		word = s.words[i]
+	}
+}
+
+// Min returns the least element of the set, and reports whether the
+// set is non-empty.
+func (s *IntSet) Min() (int, bool) { return s.Next(0) }
+
+// Max returns the greatest element of the set, and reports whether
+// the set is non-empty.
+func (s *IntSet) Max() (int, bool) {
+	for i := len(s.words) - 1; i >= 0; i-- {
+		if word := s.words[i]; word != 0 {
+			return 64*i + 63 - bits.LeadingZeros64(word), true
+		}
+	}
+	return 0, false
+}
+
+// Rank returns the number of elements of the set less than x.
+func (s *IntSet) Rank(x int) int {
+	if x <= 0 {
+		return 0
+	}
+	word, bit := x/64, uint(x%64)
+	n := 0
+	for i := 0; i < word && i < len(s.words); i++ {
+		n += bits.OnesCount64(s.words[i])
+	}
+	if word < len(s.words) {
+		n += bits.OnesCount64(s.words[word] & (1<<bit - 1))
+	}
+	return n
+}
+
+// Select returns the element of the set of rank i, that is, the
+// (i+1)th least, and reports whether there is one.
+func (s *IntSet) Select(i int) (int, bool) {
+	if i < 0 {
+		return 0, false
+	}
+	for w, word := range s.words {
+		n := bits.OnesCount64(word)
+		if i >= n {
+			i -= n
+			continue
+		}
+		for ; i > 0; i-- {
+			word &= word - 1 // clear lowest set bit
+		}
+		return 64*w + bits.TrailingZeros64(word), true
+	}
+	return 0, false
+}
+
+// IsSubset reports whether every element of s is an element of t.
+func (s *IntSet) IsSubset(t *IntSet) bool {
+	for i, word := range s.words {
+		var tword uint64
+		if i < len(t.words) {
+			tword = t.words[i]
+		}
+		if word&^tword != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Equal reports whether s and t have the same elements.
+func (s *IntSet) Equal(t *IntSet) bool {
+	return s.IsSubset(t) && t.IsSubset(s)
+}
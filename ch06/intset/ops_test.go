@@ -0,0 +1,301 @@
+package intset
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// randomSet returns an IntSet and the equivalent map of n random
+// elements less than max.
+func randomSet(rng *rand.Rand, n, max int) (*IntSet, map[int]bool) {
+	s := new(IntSet)
+	m := make(map[int]bool)
+	for i := 0; i < n; i++ {
+		x := rng.Intn(max)
+		s.Add(x)
+		m[x] = true
+	}
+	return s, m
+}
+
+func sorted(m map[int]bool) []int {
+	var elems []int
+	for x := range m {
+		elems = append(elems, x)
+	}
+	sort.Ints(elems)
+	return elems
+}
+
+// check reports whether s has exactly the elements of m.
+func check(t *testing.T, op string, s *IntSet, m map[int]bool) {
+	t.Helper()
+	if got, want := fmt.Sprint(s.Elems()), fmt.Sprint(sorted(m)); got != want {
+		t.Errorf("%s: got %s, want %s", op, got, want)
+	}
+	if s.Len() != len(m) {
+		t.Errorf("%s: Len() = %d, want %d", op, s.Len(), len(m))
+	}
+}
+
+// TestOps compares the set algebra with the same operations on maps.
+func TestOps(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		max := 1 + rng.Intn(1000)
+		s, sm := randomSet(rng, rng.Intn(50), max)
+		u, um := randomSet(rng, rng.Intn(50), max)
+
+		x := s.Copy()
+		x.IntersectWith(u)
+		xm := make(map[int]bool)
+		for k := range sm {
+			if um[k] {
+				xm[k] = true
+			}
+		}
+		check(t, "IntersectWith", x, xm)
+		if !x.IsSubset(s) || !x.IsSubset(u) {
+			t.Errorf("intersection %s not a subset of %s and %s", x, s, u)
+		}
+
+		x = s.Copy()
+		x.DifferenceWith(u)
+		xm = make(map[int]bool)
+		for k := range sm {
+			if !um[k] {
+				xm[k] = true
+			}
+		}
+		check(t, "DifferenceWith", x, xm)
+
+		x = s.Copy()
+		x.SymmetricDifference(u)
+		for k := range um {
+			xm[k] = !sm[k]
+			if !xm[k] {
+				delete(xm, k)
+			}
+		}
+		check(t, "SymmetricDifference", x, xm)
+
+		x = s.Copy()
+		x.UnionWith(u)
+		x.DifferenceWith(s)
+		x.DifferenceWith(u)
+		if x.Len() != 0 {
+			t.Errorf("union less both sets is %s", x)
+		}
+
+		// Remove every other element.
+		x = s.Copy()
+		for i, k := range sorted(sm) {
+			if i%2 == 0 {
+				x.Remove(k)
+				delete(sm, k)
+			}
+		}
+		check(t, "Remove", x, sm)
+		if x.Equal(s) != (x.Len() == s.Len()) {
+			t.Errorf("%s.Equal(%s) = %t", x, s, x.Equal(s))
+		}
+	}
+}
+
+func TestOrder(t *testing.T) {
+	var s IntSet
+	if _, ok := s.Min(); ok {
+		t.Error("empty set has a Min")
+	}
+	if _, ok := s.Max(); ok {
+		t.Error("empty set has a Max")
+	}
+	elems := []int{3, 64, 65, 127, 128, 1000}
+	s.AddAll(elems...)
+	s.Add(2000)
+	s.Remove(2000) // leaves no trailing zero words
+
+	if x, _ := s.Min(); x != 3 {
+		t.Errorf("Min() = %d, want 3", x)
+	}
+	if x, _ := s.Max(); x != 1000 {
+		t.Errorf("Max() = %d, want 1000", x)
+	}
+	for i, x := range elems {
+		if r := s.Rank(x); r != i {
+			t.Errorf("Rank(%d) = %d, want %d", x, r, i)
+		}
+		if r := s.Rank(x + 1); r != i+1 {
+			t.Errorf("Rank(%d) = %d, want %d", x+1, r, i+1)
+		}
+		if y, ok := s.Select(i); y != x || !ok {
+			t.Errorf("Select(%d) = %d, %t, want %d", i, y, ok, x)
+		}
+	}
+	if _, ok := s.Select(len(elems)); ok {
+		t.Errorf("Select(%d) succeeded", len(elems))
+	}
+
+	var got []int
+	for x, ok := s.Next(0); ok; x, ok = s.Next(x + 1) {
+		got = append(got, x)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(elems) {
+		t.Errorf("Next loop visited %v, want %v", got, elems)
+	}
+	got = nil
+	s.Each(func(x int) bool {
+		got = append(got, x)
+		return x < 100
+	})
+	if fmt.Sprint(got) != "[3 64 65 127]" {
+		t.Errorf("Each visited %v, want [3 64 65 127]", got)
+	}
+
+	var u IntSet
+	u.AddAll(elems...)
+	if !s.Equal(&u) {
+		t.Errorf("%s not Equal to %s", &s, &u)
+	}
+	s.Clear()
+	if s.Len() != 0 || !s.IsSubset(&u) || s.Equal(&u) {
+		t.Errorf("after Clear, set is %s", &s)
+	}
+}
+
+func Example_algebra() {
+	var x, y IntSet
+	x.AddAll(1, 2, 3, 4)
+	y.AddAll(3, 4, 5)
+
+	z := x.Copy()
+	z.IntersectWith(&y)
+	fmt.Println(z) // "{3 4}"
+
+	z = x.Copy()
+	z.DifferenceWith(&y)
+	fmt.Println(z) // "{1 2}"
+
+	z = x.Copy()
+	z.SymmetricDifference(&y)
+	fmt.Println(z, z.Len()) // "{1 2 5} 3"
+
+	// Output:
+	// {3 4}
+	// {1 2}
+	// {1 2 5} 3
+}
+
+// -- Benchmarks --
+
+// The benchmarks compare IntSet with map[int]bool for sets of 1000
+// elements less than 100000.
+const benchN, benchMax = 1000, 100000
+
+func BenchmarkAdd(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	vals := rng.Perm(benchMax)[:benchN]
+	b.Run("IntSet", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var s IntSet
+			s.AddAll(vals...)
+		}
+	})
+	b.Run("Map", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			m := make(map[int]bool)
+			for _, x := range vals {
+				m[x] = true
+			}
+		}
+	})
+}
+
+func BenchmarkHas(b *testing.B) {
+	s, m := randomSet(rand.New(rand.NewSource(1)), benchN, benchMax)
+	b.Run("IntSet", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			s.Has(i % benchMax)
+		}
+	})
+	b.Run("Map", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = m[i%benchMax]
+		}
+	})
+}
+
+func BenchmarkLen(b *testing.B) {
+	s, m := randomSet(rand.New(rand.NewSource(1)), benchN, benchMax)
+	b.Run("IntSet", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			s.Len()
+		}
+	})
+	b.Run("Map", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = len(m)
+		}
+	})
+}
+
+func BenchmarkIntersect(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	s, sm := randomSet(rng, benchN, benchMax)
+	u, um := randomSet(rng, benchN, benchMax)
+	b.Run("IntSet", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			x := s.Copy()
+			x.IntersectWith(u)
+		}
+	})
+	b.Run("Map", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			x := make(map[int]bool)
+			for k := range sm {
+				if um[k] {
+					x[k] = true
+				}
+			}
+		}
+	})
+}
+
+func BenchmarkUnion(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	s, sm := randomSet(rng, benchN, benchMax)
+	u, um := randomSet(rng, benchN, benchMax)
+	b.Run("IntSet", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			x := s.Copy()
+			x.UnionWith(u)
+		}
+	})
+	b.Run("Map", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			x := make(map[int]bool, len(sm))
+			for k := range sm {
+				x[k] = true
+			}
+			for k := range um {
+				x[k] = true
+			}
+		}
+	})
+}
+
+func BenchmarkElems(b *testing.B) {
+	s, m := randomSet(rand.New(rand.NewSource(1)), benchN, benchMax)
+	b.Run("IntSet", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			s.Elems()
+		}
+	})
+	b.Run("Map", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			sorted(m)
+		}
+	})
+}
@@ -0,0 +1,388 @@
+// Package crawl provides a polite, bounded, concurrent web crawler.
+//
+// It generalizes gopl.io/ch08/crawl3: in addition to bounding the
+// total number of concurrent requests, a Crawler limits the depth of
+// the crawl, the number of concurrent requests to any one host and
+// the rate at which each host is fetched, honours robots.txt, keeps
+// to an allowed set of hosts, and stops when its context is cancelled.
+package crawl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"gopl.io/ch05/links"
+)
+
+// A Crawler holds the configuration of a crawl.
+// Its zero value crawls without limits other than the default
+// number of workers; set fields before calling Crawl.
+type Crawler struct {
+	MaxDepth   int           // maximum link depth from a seed; 0 means no limit
+	Workers    int           // concurrent fetches overall; 0 means 20
+	PerHost    int           // concurrent fetches per host; 0 means 2
+	Delay      time.Duration // minimum interval between fetches from a host
+	UserAgent  string        // sent with each request and matched in robots.txt
+	SameDomain bool          // only follow links to the hosts of the seeds
+	Allow      []string      // hosts (and their subdomains) that may be followed
+	NoRobots   bool          // ignore robots.txt
+	Client     *http.Client  // nil means http.DefaultClient
+
+	// CheckExternal causes out-of-scope links to be fetched, so
+	// that their status is known, but not followed.
+	CheckExternal bool
+
+	// Request, if non-nil, is called to modify each request before
+	// it is sent, for example to add conditional headers.
+	Request func(req *http.Request)
+
+	// Response, if non-nil, is called with each response, whatever
+	// its status, in place of the default handling of the body, which
+	// extracts links from successfully fetched HTML.  When it is
+	// called, the page's Status, Type and Modified fields are set; it
+	// may set the page's Links, and its result becomes the page's Err.
+	// It is called concurrently from many goroutines.
+	Response func(page *Page, resp *http.Response) error
+
+	// Follow, if non-nil, reports whether a link found on a page
+	// should be crawled.  If nil, only anchors (<a href> and
+	// <area href>) without rel=nofollow are crawled, and images,
+	// scripts, stylesheets and other resources are not.
+	Follow func(l links.Link) bool
+
+	// State, if non-nil, records the progress of the crawl.  If it
+	// was resumed, URLs it has already seen are not fetched again
+	// and its frontier is fetched before anything else.
+	State *State
+}
+
+// A Page is the outcome of fetching one URL.
+type Page struct {
+	URL      string
+	Depth    int       // number of links followed from a seed
+	External bool      // out of scope; fetched only to learn its status
+	Status   int       // HTTP status code, or 0 if the request failed
+	Err      error     // non-nil if the page could not be fetched or parsed
+	Type     string    // media type, such as "text/html"
+	Modified time.Time // from the Last-Modified header, if any
+	Links    []links.Link
+}
+
+// ErrRobots is the error recorded for pages excluded by robots.txt.
+var ErrRobots = errors.New("disallowed by robots.txt")
+
+// An item is a URL awaiting a fetch.
+type item struct {
+	url      string
+	depth    int
+	external bool
+}
+
+// Crawl crawls the web starting with seeds, calling visit for each
+// page fetched.  Each URL is visited at most once.  The calls to
+// visit are made sequentially from the calling goroutine, so visit
+// need not be concurrency-safe.
+//
+// Crawl returns when there is nothing left to fetch, or when ctx is
+// cancelled, in which case it returns ctx.Err(), or when c.State
+// cannot be written, in which case it returns that error.
+func (c *Crawler) Crawl(ctx context.Context, seeds []string, visit func(*Page)) error {
+	workers := c.Workers
+	if workers <= 0 {
+		workers = 20
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	f := &fetcher{
+		c:      c,
+		client: client,
+		robots: &robotsCache{client: client, agent: c.UserAgent, hosts: make(map[string]*robotsEntry)},
+		hosts:  make(map[string]*host),
+	}
+	scope := c.scope(seeds)
+
+	// The structure is that of crawl3, except that the workers send
+	// back whole pages so that visit runs in this goroutine, and
+	// the number of pending pages is counted, as in crawl2, so that
+	// the crawl terminates.
+	pages := make(chan *Page)
+	unseen := make(chan item)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for it := range unseen {
+				pages <- f.fetch(ctx, it)
+			}
+		}()
+	}
+
+	seen := make(map[string]bool)
+	var queue []item
+	if c.State != nil {
+		for _, it := range c.State.frontier() {
+			if u, err := url.Parse(it.url); err == nil {
+				it.external = !scope(u)
+				queue = append(queue, it)
+			}
+		}
+	}
+	enqueue := func(rawurl string, depth int) {
+		if c.MaxDepth > 0 && depth > c.MaxDepth {
+			return
+		}
+		u, err := url.Parse(rawurl)
+		if err != nil {
+			return
+		}
+		external := !scope(u)
+		if external && !(c.CheckExternal && depth > 0 && web(u)) {
+			return
+		}
+		normalize(u)
+		s := u.String()
+		if seen[s] || (c.State != nil && c.State.Seen(s)) {
+			return
+		}
+		seen[s] = true
+		if c.State != nil {
+			c.State.queued(s, depth)
+		}
+		queue = append(queue, item{s, depth, external})
+	}
+	for _, seed := range seeds {
+		enqueue(seed, 0)
+	}
+	follow := c.Follow
+	if follow == nil {
+		follow = func(l links.Link) bool { return l.Kind == links.Anchor && !l.NoFollow() }
+	}
+	stopped := func() bool {
+		return ctx.Err() != nil || (c.State != nil && c.State.Err() != nil)
+	}
+
+	var n int // number of items handed to workers but not yet returned
+	for len(queue) > 0 || n > 0 {
+		// Offer the next queued item to the workers while also
+		// accepting finished pages, so that neither side blocks.
+		var send chan<- item
+		var next item
+		if len(queue) > 0 && !stopped() {
+			send, next = unseen, queue[0]
+		} else if n == 0 {
+			break // cancelled
+		}
+		select {
+		case send <- next:
+			queue = queue[1:]
+			n++
+		case page := <-pages:
+			n--
+			// A page that failed because of cancellation
+			// remains in the frontier to be fetched on resumption.
+			if c.State != nil && (page.Err == nil || ctx.Err() == nil) {
+				c.State.done(page.URL)
+			}
+			visit(page)
+			if !page.External {
+				for _, link := range page.Links {
+					if follow(link) {
+						enqueue(link.URL, page.Depth+1)
+					}
+				}
+			}
+		}
+	}
+	close(unseen)
+	if c.State != nil && c.State.Err() != nil {
+		return c.State.Err()
+	}
+	return ctx.Err()
+}
+
+// scope returns a function that reports whether a URL may be crawled.
+func (c *Crawler) scope(seeds []string) func(*url.URL) bool {
+	var allow []string
+	for _, h := range c.Allow {
+		allow = append(allow, strings.ToLower(h))
+	}
+	if c.SameDomain {
+		for _, seed := range seeds {
+			if u, err := url.Parse(seed); err == nil {
+				allow = append(allow, strings.ToLower(u.Hostname()))
+			}
+		}
+	}
+	return func(u *url.URL) bool {
+		if !web(u) {
+			return false
+		}
+		if len(allow) == 0 {
+			return true
+		}
+		h := strings.ToLower(u.Hostname())
+		for _, a := range allow {
+			if h == a || strings.HasSuffix(h, "."+a) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// web reports whether u is an http or https URL.
+func web(u *url.URL) bool {
+	return u.Scheme == "http" || u.Scheme == "https"
+}
+
+// A fetcher fetches pages on behalf of a Crawler, enforcing its
+// per-host limits.
+type fetcher struct {
+	c      *Crawler
+	client *http.Client
+	robots *robotsCache
+
+	mu    sync.Mutex // guards hosts
+	hosts map[string]*host
+}
+
+// A host tracks the requests in flight to one host.
+type host struct {
+	sema chan struct{} // counting semaphore of concurrent requests
+
+	mu   sync.Mutex // guards next
+	next time.Time  // earliest time of the next request
+}
+
+func (f *fetcher) host(name string) *host {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	h := f.hosts[name]
+	if h == nil {
+		n := f.c.PerHost
+		if n <= 0 {
+			n = 2
+		}
+		h = &host{sema: make(chan struct{}, n)}
+		f.hosts[name] = h
+	}
+	return h
+}
+
+// wait blocks until a request to h is permitted, or ctx is cancelled.
+// The caller must release h.sema when the request is done.
+func (h *host) wait(ctx context.Context, delay time.Duration) error {
+	select {
+	case h.sema <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	h.mu.Lock()
+	now := time.Now()
+	at := h.next
+	if at.Before(now) {
+		at = now
+	}
+	h.next = at.Add(delay)
+	h.mu.Unlock()
+
+	if d := at.Sub(now); d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			<-h.sema
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (f *fetcher) fetch(ctx context.Context, it item) *Page {
+	page := &Page{URL: it.url, Depth: it.depth, External: it.external}
+	u, err := url.Parse(it.url)
+	if err != nil {
+		page.Err = err
+		return page
+	}
+
+	delay := f.c.Delay
+	if !f.c.NoRobots {
+		rb, err := f.robots.get(ctx, u)
+		for attempt := 1; err != nil && attempt < robotsAttempts && ctx.Err() == nil; attempt++ {
+			// Wait for the entry to expire, then try again.
+			t := time.NewTimer(robotsRetry)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+			}
+			rb, err = f.robots.get(ctx, u)
+		}
+		if err != nil {
+			page.Err = err
+			return page
+		}
+		if !rb.allowed(u.RequestURI()) {
+			page.Err = ErrRobots
+			return page
+		}
+		if rb != nil && rb.delay > delay {
+			delay = rb.delay
+		}
+	}
+
+	h := f.host(u.Host)
+	if err := h.wait(ctx, delay); err != nil {
+		page.Err = err
+		return page
+	}
+	defer func() { <-h.sema }()
+
+	req, err := http.NewRequest("GET", it.url, nil)
+	if err != nil {
+		page.Err = err
+		return page
+	}
+	req = req.WithContext(ctx)
+	if f.c.UserAgent != "" {
+		req.Header.Set("User-Agent", f.c.UserAgent)
+	}
+	if f.c.Request != nil {
+		f.c.Request(req)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		page.Err = err
+		return page
+	}
+	defer resp.Body.Close()
+	page.Status = resp.StatusCode
+	if t, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		page.Modified = t
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" {
+		page.Type, _, _ = mime.ParseMediaType(ct)
+	}
+	if f.c.Response != nil {
+		page.Err = f.c.Response(page, resp)
+		return page
+	}
+	if resp.StatusCode != http.StatusOK {
+		page.Err = fmt.Errorf("getting %s: %s", it.url, resp.Status)
+		return page
+	}
+	if it.external || page.Type != "text/html" {
+		return page // no links to follow
+	}
+	page.Links, page.Err = links.ExtractHTML(resp.Request.URL, resp.Body)
+	return page
+}
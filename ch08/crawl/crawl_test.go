@@ -0,0 +1,308 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopl.io/ch05/links"
+)
+
+// site is a small graph of pages, each listing the paths it links to.
+var site = map[string][]string{
+	"/":          {"/a", "/b", "/private/x"},
+	"/a":         {"/a/1", "/b", "/#top"},
+	"/b":         {"/b/1", "http://elsewhere.example/"},
+	"/a/1":       {"/a/1/deep"},
+	"/b/1":       {"/", "/missing"},
+	"/a/1/deep":  nil,
+	"/private/x": nil,
+}
+
+const robotsTxt = `# test rules
+User-agent: otherbot
+Disallow: /
+
+User-agent: *
+Disallow: /private/
+`
+
+// siteServer serves site, recording each request path and the peak
+// number of concurrent requests.
+type siteServer struct {
+	mu       sync.Mutex
+	paths    []string
+	inflight int
+	peak     int
+	delay    time.Duration
+}
+
+func (s *siteServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/robots.txt" {
+		fmt.Fprint(w, robotsTxt)
+		return
+	}
+	s.mu.Lock()
+	s.paths = append(s.paths, r.URL.Path)
+	s.inflight++
+	if s.inflight > s.peak {
+		s.peak = s.inflight
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.inflight--
+		s.mu.Unlock()
+	}()
+	time.Sleep(s.delay)
+
+	links, ok := site[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, "<html><body>")
+	for _, l := range links {
+		fmt.Fprintf(w, "<a href=%q>%s</a>\n", l, l)
+	}
+	fmt.Fprint(w, "</body></html>")
+}
+
+func crawlPaths(t *testing.T, c *Crawler, srv *httptest.Server) map[string]*Page {
+	t.Helper()
+	pages := make(map[string]*Page)
+	err := c.Crawl(context.Background(), []string{srv.URL + "/"}, func(p *Page) {
+		path := strings.TrimPrefix(p.URL, srv.URL)
+		if pages[path] != nil {
+			t.Errorf("visited %s twice", p.URL)
+		}
+		pages[path] = p
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pages
+}
+
+func keys(m map[string]*Page) string {
+	var ks []string
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return strings.Join(ks, " ")
+}
+
+func TestCrawl(t *testing.T) {
+	ss := new(siteServer)
+	srv := httptest.NewServer(ss)
+	defer srv.Close()
+
+	c := &Crawler{SameDomain: true}
+	pages := crawlPaths(t, c, srv)
+	if got, want := keys(pages), "/ /a /a/1 /a/1/deep /b /b/1 /missing /private/x"; got != want {
+		t.Errorf("visited %s, want %s", got, want)
+	}
+	if p := pages["/private/x"]; p.Err != ErrRobots {
+		t.Errorf("/private/x: err = %v, want %v", p.Err, ErrRobots)
+	}
+	if p := pages["/a/1/deep"]; p.Depth != 3 || p.Status != 200 {
+		t.Errorf("/a/1/deep: depth %d status %d, want 3 200", p.Depth, p.Status)
+	}
+	for _, p := range ss.paths {
+		if strings.HasPrefix(p, "/private/") {
+			t.Errorf("fetched %s despite robots.txt", p)
+		}
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	srv := httptest.NewServer(new(siteServer))
+	defer srv.Close()
+
+	c := &Crawler{SameDomain: true, MaxDepth: 1, NoRobots: true}
+	pages := crawlPaths(t, c, srv)
+	if got, want := keys(pages), "/ /a /b /private/x"; got != want {
+		t.Errorf("visited %s, want %s", got, want)
+	}
+}
+
+func TestPerHost(t *testing.T) {
+	ss := &siteServer{delay: 20 * time.Millisecond}
+	srv := httptest.NewServer(ss)
+	defer srv.Close()
+
+	c := &Crawler{SameDomain: true, PerHost: 1, Workers: 10}
+	crawlPaths(t, c, srv)
+	if ss.peak != 1 {
+		t.Errorf("peak concurrent requests = %d, want 1", ss.peak)
+	}
+}
+
+func TestDelay(t *testing.T) {
+	srv := httptest.NewServer(new(siteServer))
+	defer srv.Close()
+
+	const delay = 30 * time.Millisecond
+	c := &Crawler{SameDomain: true, MaxDepth: 1, Delay: delay, PerHost: 4}
+	start := time.Now()
+	pages := crawlPaths(t, c, srv)
+	// Three pages are fetched (the fourth is disallowed), so at
+	// least two delays must separate them.
+	if len(pages) != 4 {
+		t.Fatalf("visited %d pages, want 4", len(pages))
+	}
+	if elapsed := time.Since(start); elapsed < 2*delay {
+		t.Errorf("crawl took %s, want at least %s", elapsed, 2*delay)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	srv := httptest.NewServer(new(siteServer))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Crawler{SameDomain: true}
+	var n int
+	err := c.Crawl(ctx, []string{srv.URL + "/"}, func(p *Page) {
+		n++
+		cancel()
+	})
+	if err != context.Canceled {
+		t.Errorf("Crawl returned %v, want %v", err, context.Canceled)
+	}
+	if n != 1 {
+		t.Errorf("visited %d pages after cancellation, want 1", n)
+	}
+}
+
+func TestRobots(t *testing.T) {
+	const txt = `
+User-agent: gopl
+Disallow: /tmp/
+Allow: /tmp/keep$
+Disallow: /*.pdf$
+Crawl-delay: 1.5
+
+User-agent: *
+Disallow: /
+`
+	for _, test := range []struct {
+		agent, path string
+		want        bool
+	}{
+		{"gopl-crawl/1.0", "/index.html", true},
+		{"gopl-crawl/1.0", "/tmp/x", false},
+		{"gopl-crawl/1.0", "/tmp/keep", true},
+		{"gopl-crawl/1.0", "/tmp/keeper", false},
+		{"gopl-crawl/1.0", "/docs/a.pdf", false},
+		{"gopl-crawl/1.0", "/docs/a.pdf?x=1", true},
+		{"somebot", "/index.html", false},
+	} {
+		rb := parseRobots(strings.NewReader(txt), test.agent)
+		if got := rb.allowed(test.path); got != test.want {
+			t.Errorf("%s: allowed(%q) = %t, want %t", test.agent, test.path, got, test.want)
+		}
+	}
+	if rb := parseRobots(strings.NewReader(txt), "gopl"); rb.delay != 1500*time.Millisecond {
+		t.Errorf("Crawl-delay = %s, want 1.5s", rb.delay)
+	}
+}
+
+func TestFollow(t *testing.T) {
+	var mu sync.Mutex
+	var fetched []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetched = append(fetched, r.URL.Path)
+		mu.Unlock()
+		if r.URL.Path != "/" {
+			fmt.Fprint(w, "x")
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<a href="/page">page</a> <a href="/secret" rel="nofollow">no</a>
+<img src="/img.png"> <script src="/app.js"></script> <link rel=stylesheet href="/style.css">`)
+	}))
+	defer srv.Close()
+
+	for _, test := range []struct {
+		follow func(l links.Link) bool
+		want   string
+	}{
+		{nil, "/ /page"},
+		{func(l links.Link) bool { return !l.NoFollow() }, "/ /app.js /img.png /page /style.css"},
+	} {
+		fetched = nil
+		c := &Crawler{SameDomain: true, NoRobots: true, Follow: test.follow}
+		pages := crawlPaths(t, c, srv)
+		if got := keys(pages); got != test.want {
+			t.Errorf("visited %s, want %s", got, test.want)
+		}
+		sort.Strings(fetched)
+		if got := strings.Join(fetched, " "); got != test.want {
+			t.Errorf("fetched %s, want %s", got, test.want)
+		}
+	}
+}
+
+func TestRobotsUnavailable(t *testing.T) {
+	defer func(d time.Duration) { robotsRetry = d }(robotsRetry)
+	robotsRetry = 10 * time.Millisecond
+
+	for _, test := range []struct {
+		name     string
+		failures int // robots.txt requests that fail with 503
+		status   int // thereafter
+		want     string
+	}{
+		{"recovers", 2, 200, "/ /a"},
+		{"missing", 0, 404, "/ /a /private/x"},
+		{"down", 100, 200, ""},
+	} {
+		var mu sync.Mutex
+		tries := 0
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/robots.txt":
+				mu.Lock()
+				tries++
+				fail := tries <= test.failures
+				mu.Unlock()
+				if fail {
+					http.Error(w, "busy", http.StatusServiceUnavailable)
+				} else if test.status != 200 {
+					http.Error(w, "none", test.status)
+				} else {
+					fmt.Fprint(w, robotsTxt)
+				}
+			case "/":
+				w.Header().Set("Content-Type", "text/html")
+				fmt.Fprint(w, `<a href="/a">a</a> <a href="/private/x">x</a>`)
+			default:
+				fmt.Fprint(w, "x")
+			}
+		}))
+		var fetched []string
+		err := (&Crawler{SameDomain: true}).Crawl(context.Background(), []string{srv.URL + "/"}, func(p *Page) {
+			if p.Err == nil {
+				fetched = append(fetched, strings.TrimPrefix(p.URL, srv.URL))
+			}
+		})
+		srv.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(fetched)
+		if got := strings.Join(fetched, " "); got != test.want {
+			t.Errorf("%s: fetched %q, want %q", test.name, got, test.want)
+		}
+	}
+}
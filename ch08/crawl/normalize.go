@@ -0,0 +1,45 @@
+package crawl
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+// Normalize returns a canonical form of rawurl, so that URLs that
+// differ only in the case of their scheme or host, in an explicit
+// default port, in their fragment, or in the order of their query
+// parameters are recognized as the same.  An empty path becomes "/".
+func Normalize(rawurl string) (string, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", err
+	}
+	normalize(u)
+	return u.String(), nil
+}
+
+func normalize(u *url.URL) {
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if host, port, err := net.SplitHostPort(u.Host); err == nil {
+		if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+			u.Host = host
+			if strings.Contains(host, ":") {
+				u.Host = "[" + host + "]" // IPv6 literal
+			}
+		}
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	if u.Path == "" && u.Opaque == "" {
+		u.Path = "/"
+	}
+	if u.RawQuery != "" {
+		// Values.Encode sorts by key; values of the same key keep
+		// their order, which may be significant.
+		if q, err := url.ParseQuery(u.RawQuery); err == nil {
+			u.RawQuery = q.Encode()
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package crawl
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"gopl.io/ch05/links"
+)
+
+// A Graph records the pages of a crawl and the links between them,
+// from which it produces reports.  Its Add method may be passed
+// directly to Crawler.Crawl as the visit function.
+type Graph struct {
+	pages map[string]*Page
+}
+
+// Add records p in the graph.
+func (g *Graph) Add(p *Page) {
+	if g.pages == nil {
+		g.pages = make(map[string]*Page)
+	}
+	g.pages[p.URL] = p
+}
+
+// urls returns the URLs of the recorded pages in sorted order.
+func (g *Graph) urls() []string {
+	var urls []string
+	for url := range g.pages {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// WriteSitemap writes an XML sitemap (see sitemaps.org) listing
+// each in-scope HTML page that was fetched successfully.
+func (g *Graph) WriteSitemap(w io.Writer) error {
+	type entry struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod,omitempty"`
+	}
+	var sitemap struct {
+		XMLName xml.Name `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+		URLs    []entry  `xml:"url"`
+	}
+	for _, url := range g.urls() {
+		p := g.pages[url]
+		if p.External || p.Status != 200 || p.Type != "text/html" {
+			continue
+		}
+		e := entry{Loc: url}
+		if !p.Modified.IsZero() {
+			e.LastMod = p.Modified.UTC().Format("2006-01-02T15:04:05Z")
+		}
+		sitemap.URLs = append(sitemap.URLs, e)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(sitemap); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// An Edge is a link from one page to another.
+type Edge struct {
+	Source string     `json:"source"`
+	Target string     `json:"target"`
+	Kind   links.Kind `json:"kind"`
+	Text   string     `json:"text,omitempty"` // anchor text
+}
+
+// A Node is a page in the JSON form of the link graph.
+type Node struct {
+	URL      string `json:"url"`
+	Status   int    `json:"status,omitempty"`
+	External bool   `json:"external,omitempty"`
+}
+
+// edges returns the links of the recorded pages, in order of source.
+// Targets are normalized, as they are by the crawler.
+func (g *Graph) edges() []Edge {
+	var edges []Edge
+	for _, url := range g.urls() {
+		for _, l := range g.pages[url].Links {
+			target, err := Normalize(l.URL)
+			if err != nil {
+				target = l.URL
+			}
+			edges = append(edges, Edge{url, target, l.Kind, l.Text})
+		}
+	}
+	return edges
+}
+
+// WriteDOT writes the link graph in the Graphviz DOT language.
+// Each edge is labelled with its anchor text.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	fmt.Fprintln(w, "digraph crawl {")
+	for _, url := range g.urls() {
+		p := g.pages[url]
+		attrs := ""
+		switch {
+		case p.Status >= 400:
+			attrs = " [color=red]"
+		case p.External:
+			attrs = " [style=dashed]"
+		}
+		fmt.Fprintf(w, "\t%s%s;\n", strconv.Quote(url), attrs)
+	}
+	for _, e := range g.edges() {
+		fmt.Fprintf(w, "\t%s -> %s", strconv.Quote(e.Source), strconv.Quote(e.Target))
+		if e.Text != "" {
+			fmt.Fprintf(w, " [label=%s]", strconv.Quote(e.Text))
+		}
+		fmt.Fprintln(w, ";")
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// WriteJSON writes the link graph as a JSON object
+// with "nodes" and "edges" arrays.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	var graph struct {
+		Nodes []Node `json:"nodes"`
+		Edges []Edge `json:"edges"`
+	}
+	for _, url := range g.urls() {
+		p := g.pages[url]
+		graph.Nodes = append(graph.Nodes, Node{url, p.Status, p.External})
+	}
+	graph.Edges = g.edges()
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(graph)
+}
+
+// Broken returns the links whose targets responded with a 4xx or 5xx
+// status, grouped by target.
+func (g *Graph) Broken() map[string][]Edge {
+	broken := make(map[string][]Edge)
+	for _, e := range g.edges() {
+		if p := g.pages[e.Target]; p != nil && p.Status >= 400 {
+			broken[e.Target] = append(broken[e.Target], e)
+		}
+	}
+	return broken
+}
+
+// WriteBroken writes a report of each broken link target, its
+// status, and the pages that refer to it.
+func (g *Graph) WriteBroken(w io.Writer) error {
+	broken := g.Broken()
+	var targets []string
+	for t := range broken {
+		targets = append(targets, t)
+	}
+	sort.Strings(targets)
+	for _, t := range targets {
+		fmt.Fprintf(w, "%d %s\n", g.pages[t].Status, t)
+		for _, e := range broken[t] {
+			fmt.Fprintf(w, "\t%s", e.Source)
+			if e.Text != "" {
+				fmt.Fprintf(w, " %q", e.Text)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d broken link targets\n", len(targets))
+	return err
+}
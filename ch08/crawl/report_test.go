@@ -0,0 +1,95 @@
+package crawl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func crawlGraph(t *testing.T) (*Graph, string) {
+	t.Helper()
+	srv := httptest.NewServer(new(siteServer))
+	t.Cleanup(srv.Close)
+
+	g := new(Graph)
+	c := &Crawler{SameDomain: true}
+	if err := c.Crawl(context.Background(), []string{srv.URL + "/"}, g.Add); err != nil {
+		t.Fatal(err)
+	}
+	return g, srv.URL
+}
+
+func TestSitemap(t *testing.T) {
+	g, base := crawlGraph(t)
+	var buf bytes.Buffer
+	if err := g.WriteSitemap(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := strings.Replace(buf.String(), base, "", -1)
+	for _, want := range []string{
+		`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`,
+		"<loc>/a/1/deep</loc>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("sitemap lacks %s:\n%s", want, out)
+		}
+	}
+	for _, bad := range []string{"/missing", "/private/x"} {
+		if strings.Contains(out, bad) {
+			t.Errorf("sitemap contains %s:\n%s", bad, out)
+		}
+	}
+}
+
+func TestBroken(t *testing.T) {
+	g, base := crawlGraph(t)
+	var buf bytes.Buffer
+	if err := g.WriteBroken(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Replace(buf.String(), base, "", -1)
+	want := "404 /missing\n\t/b/1 \"/missing\"\n1 broken link targets\n"
+	if got != want {
+		t.Errorf("broken-link report:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGraph(t *testing.T) {
+	g, base := crawlGraph(t)
+	var buf bytes.Buffer
+	if err := g.WriteDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dot := strings.Replace(buf.String(), base, "", -1)
+	if want := `"/a" -> "/a/1" [label="/a/1"];`; !strings.Contains(dot, want) {
+		t.Errorf("DOT output lacks %s:\n%s", want, dot)
+	}
+
+	buf.Reset()
+	if err := g.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var graph struct {
+		Nodes []Node
+		Edges []Edge
+	}
+	if err := json.Unmarshal(buf.Bytes(), &graph); err != nil {
+		t.Fatal(err)
+	}
+	if len(graph.Nodes) != 8 {
+		t.Errorf("got %d nodes, want 8", len(graph.Nodes))
+	}
+	// "/a" links to "/#top", which is recorded as a link to "/".
+	var found bool
+	for _, e := range graph.Edges {
+		if e.Source == base+"/a" && e.Target == base+"/" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("no edge from /a to /")
+	}
+}
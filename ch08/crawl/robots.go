@@ -0,0 +1,227 @@
+package crawl
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A robots holds the rules from a robots.txt file that apply to
+// one particular user agent.  A nil *robots allows everything.
+type robots struct {
+	rules []rule
+	delay time.Duration // Crawl-delay, if any
+}
+
+// A rule is a single Allow or Disallow line.
+type rule struct {
+	pattern string
+	re      *regexp.Regexp
+	allow   bool
+}
+
+// parseRobots parses a robots.txt file and returns the rules of the
+// group that best matches agent: the group naming the agent, or
+// failing that the "*" group.
+func parseRobots(r io.Reader, agent string) *robots {
+	agent = strings.ToLower(agent)
+	var (
+		specific, generic *robots
+		current           []*robots // groups the current lines apply to
+		inAgents          bool      // whether the previous line was User-agent
+	)
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		val := strings.TrimSpace(line[i+1:])
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				current = nil
+			}
+			inAgents = true
+			name := strings.ToLower(val)
+			switch {
+			case name == "*":
+				if generic == nil {
+					generic = new(robots)
+				}
+				current = append(current, generic)
+			case name != "" && strings.Contains(agent, name):
+				if specific == nil {
+					specific = new(robots)
+				}
+				current = append(current, specific)
+			default:
+				current = append(current, new(robots)) // someone else's group
+			}
+		case "allow", "disallow":
+			inAgents = false
+			if val == "" {
+				continue // "Disallow:" with no path allows everything
+			}
+			re := compilePattern(val)
+			for _, g := range current {
+				g.rules = append(g.rules, rule{val, re, key == "allow"})
+			}
+		case "crawl-delay":
+			inAgents = false
+			secs, err := strconv.ParseFloat(val, 64)
+			if err != nil || secs < 0 {
+				continue
+			}
+			for _, g := range current {
+				g.delay = time.Duration(secs * float64(time.Second))
+			}
+		default:
+			inAgents = false // Sitemap and friends end the agent list
+		}
+	}
+	if specific != nil {
+		return specific
+	}
+	return generic
+}
+
+// allowed reports whether the rules permit fetching the path (plus
+// query) p.  The longest matching pattern wins; on a tie, Allow wins.
+func (rb *robots) allowed(p string) bool {
+	if rb == nil {
+		return true
+	}
+	best, allow := -1, true
+	for _, r := range rb.rules {
+		if !r.re.MatchString(p) {
+			continue
+		}
+		if n := len(r.pattern); n > best || (n == best && r.allow) {
+			best, allow = n, r.allow
+		}
+	}
+	return allow
+}
+
+// compilePattern converts a robots.txt path pattern, in which "*"
+// matches any sequence of characters and a trailing "$" anchors the
+// pattern at the end of the path, to a regular expression.  Patterns
+// are otherwise prefixes.
+func compilePattern(pattern string) *regexp.Regexp {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = pattern[:len(pattern)-1]
+	}
+	expr := "^" + strings.Replace(regexp.QuoteMeta(pattern), `\*`, ".*", -1)
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+// robots.txt files that cannot be fetched, because of a network error
+// or a server error, disallow everything until they are fetched again,
+// after robotsRetry.  A page is given up after robotsAttempts.
+var (
+	robotsRetry    = 5 * time.Second
+	robotsAttempts = 3
+)
+
+// A robotsCache fetches and caches the robots.txt rules of each host.
+// Concurrent requests for the same host wait for the first to finish,
+// in the manner of gopl.io/ch09/memo4.
+type robotsCache struct {
+	client *http.Client
+	agent  string
+
+	mu    sync.Mutex // guards hosts
+	hosts map[string]*robotsEntry
+}
+
+type robotsEntry struct {
+	rb      *robots
+	err     error         // if robots.txt could not be fetched
+	expires time.Time     // when to fetch it again, if err != nil
+	ready   chan struct{} // closed when rb and err are ready
+}
+
+// get returns the rules for the scheme and host of u.  A missing
+// robots.txt (status 4xx) allows everything.  If robots.txt cannot be
+// fetched, get returns an error, which is cached until robotsRetry
+// has passed.
+func (c *robotsCache) get(ctx context.Context, u *url.URL) (*robots, error) {
+	key := u.Scheme + "://" + u.Host
+	c.mu.Lock()
+	e := c.hosts[key]
+	if e != nil && e.expired() {
+		e = nil
+	}
+	if e == nil {
+		e = &robotsEntry{ready: make(chan struct{})}
+		c.hosts[key] = e
+		c.mu.Unlock()
+
+		e.rb, e.err = c.fetch(ctx, key+"/robots.txt")
+		if e.err != nil {
+			e.expires = time.Now().Add(robotsRetry)
+		}
+		close(e.ready)
+	} else {
+		c.mu.Unlock()
+		select {
+		case <-e.ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return e.rb, e.err
+}
+
+// expired reports whether e holds an error that is too old to use.
+// The caller must hold c.mu.
+func (e *robotsEntry) expired() bool {
+	select {
+	case <-e.ready:
+		return e.err != nil && !time.Now().Before(e.expires)
+	default:
+		return false // still fetching
+	}
+}
+
+func (c *robotsCache) fetch(ctx context.Context, url string) (*robots, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if c.agent != "" {
+		req.Header.Set("User-Agent", c.agent)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching robots.txt: %v", err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return parseRobots(io.LimitReader(resp.Body, 500<<10), c.agent), nil
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+	default:
+		return nil, nil // no robots.txt: anything goes
+	}
+}
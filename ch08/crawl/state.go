@@ -0,0 +1,218 @@
+package crawl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A State records the progress of a crawl on disk so that an
+// interrupted crawl can be resumed.  It holds the set of URLs seen
+// so far and the frontier: those seen but not yet fetched.
+//
+// Progress is appended to a log file, path+".log", as it happens;
+// every so often the whole state is written to a snapshot file,
+// path, and the log is truncated.  Each line of either file is a
+// record:
+//
+//	Q depth url	url was queued at the given depth
+//	D url		url was fetched
+//	S url		url was seen and fetched (snapshots only)
+//
+// A State is not concurrency-safe; Crawler.Crawl uses it only from
+// the goroutine that called it.
+type State struct {
+	path    string
+	log     *os.File
+	w       *bufio.Writer
+	seen    map[string]bool
+	pending map[string]int // URL -> depth
+	nlog    int            // number of records in the log
+	err     error          // first write error, if any
+
+	// SnapshotEvery is the number of log records after which
+	// a snapshot is taken.  Zero means 10000.
+	SnapshotEvery int
+}
+
+// OpenState opens the crawl state stored at path.  If resume is
+// false, or no state exists, the state starts out empty.
+func OpenState(path string, resume bool) (*State, error) {
+	s := &State{
+		path:    path,
+		seen:    make(map[string]bool),
+		pending: make(map[string]int),
+	}
+	if resume {
+		for _, name := range []string{path, path + ".log"} {
+			if err := s.replay(name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// Start from a fresh snapshot, so that the log holds
+	// only what happens from now on.
+	if err := s.snapshot(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// replay applies the records of the named file, if it exists.
+// A malformed record, such as a line cut short by a crash,
+// ends the file.
+func (s *State) replay(name string) error {
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadString('\n')
+		if err == io.EOF {
+			return nil // including any partial final line
+		} else if err != nil {
+			return err
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 3 && fields[0] == "Q":
+			depth, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return nil
+			}
+			if !s.seen[fields[2]] {
+				s.seen[fields[2]] = true
+				s.pending[fields[2]] = depth
+			}
+		case len(fields) == 2 && fields[0] == "D":
+			delete(s.pending, fields[1])
+		case len(fields) == 2 && fields[0] == "S":
+			s.seen[fields[1]] = true
+		default:
+			return nil
+		}
+	}
+}
+
+// snapshot writes the whole state to s.path and truncates the log.
+func (s *State) snapshot() error {
+	tmp := s.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for url := range s.seen {
+		if depth, ok := s.pending[url]; ok {
+			fmt.Fprintf(w, "Q %d %s\n", depth, url)
+		} else {
+			fmt.Fprintf(w, "S %s\n", url)
+		}
+	}
+	err = w.Flush()
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, s.path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	// The snapshot now holds everything in the log.
+	if s.log != nil {
+		s.log.Close()
+	}
+	s.log, err = os.Create(s.path + ".log")
+	if err != nil {
+		return err
+	}
+	s.w = bufio.NewWriter(s.log)
+	s.nlog = 0
+	return nil
+}
+
+// record appends a record to the log, taking a snapshot if the log
+// has grown long enough.  After the first error, it does nothing.
+func (s *State) record(format string, args ...interface{}) {
+	if s.err != nil {
+		return
+	}
+	fmt.Fprintf(s.w, format, args...)
+	if s.err = s.w.Flush(); s.err != nil {
+		return
+	}
+	s.nlog++
+	every := s.SnapshotEvery
+	if every <= 0 {
+		every = 10000
+	}
+	if s.nlog >= every {
+		s.err = s.snapshot()
+	}
+}
+
+// queued records that url has been queued at the given depth.
+func (s *State) queued(url string, depth int) {
+	s.seen[url] = true
+	s.pending[url] = depth
+	s.record("Q %d %s\n", depth, url)
+}
+
+// done records that url has been fetched.
+func (s *State) done(url string) {
+	delete(s.pending, url)
+	s.record("D %s\n", url)
+}
+
+// Seen reports whether url, in normalized form, has been seen.
+func (s *State) Seen(url string) bool { return s.seen[url] }
+
+// Len returns the number of URLs seen and the number yet to be fetched.
+func (s *State) Len() (seen, pending int) { return len(s.seen), len(s.pending) }
+
+// frontier returns the URLs yet to be fetched, shallowest first.
+func (s *State) frontier() []item {
+	var items []item
+	for url, depth := range s.pending {
+		items = append(items, item{url: url, depth: depth})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].depth != items[j].depth {
+			return items[i].depth < items[j].depth
+		}
+		return items[i].url < items[j].url
+	})
+	return items
+}
+
+// Err returns the first error encountered while writing the state.
+func (s *State) Err() error { return s.err }
+
+// Close takes a final snapshot and closes the log.
+func (s *State) Close() error {
+	err := s.err
+	if err == nil {
+		err = s.snapshot()
+	}
+	if s.log != nil {
+		if cerr := s.log.Close(); err == nil {
+			err = cerr
+		}
+		s.log = nil
+	}
+	return err
+}
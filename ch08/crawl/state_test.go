@@ -0,0 +1,87 @@
+package crawl
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	for _, test := range []struct{ in, want string }{
+		{"HTTP://Example.COM", "http://example.com/"},
+		{"http://example.com:80/a#frag", "http://example.com/a"},
+		{"https://example.com:443/", "https://example.com/"},
+		{"https://example.com:8443/", "https://example.com:8443/"},
+		{"http://[::1]:80/", "http://[::1]/"},
+		{"http://example.com/p?b=2&a=1&b=1", "http://example.com/p?a=1&b=2&b=1"},
+		{"http://example.com/Case/Path", "http://example.com/Case/Path"},
+	} {
+		got, err := Normalize(test.in)
+		if err != nil {
+			t.Errorf("Normalize(%q): %v", test.in, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("Normalize(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestResume(t *testing.T) {
+	ss := new(siteServer)
+	srv := httptest.NewServer(ss)
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "crawl.state")
+
+	// Crawl until two pages have been visited, then stop.
+	st, err := OpenState(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.SnapshotEvery = 3 // exercise both the snapshot and the log
+	visited := make(map[string]int)
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Crawler{SameDomain: true, Workers: 1, State: st}
+	err = c.Crawl(ctx, []string{srv.URL + "/"}, func(p *Page) {
+		if p.Err == nil {
+			visited[strings.TrimPrefix(p.URL, srv.URL)]++
+		}
+		if len(visited) == 2 {
+			cancel()
+		}
+	})
+	if err != context.Canceled {
+		t.Fatalf("Crawl returned %v, want %v", err, context.Canceled)
+	}
+	if err := st.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Resume, and check that the rest of the site is visited,
+	// and nothing twice.
+	st, err = OpenState(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, pending := st.Len(); pending == 0 {
+		t.Fatal("resumed state has an empty frontier")
+	}
+	c = &Crawler{SameDomain: true, State: st}
+	if err := c.Crawl(context.Background(), []string{srv.URL + "/"}, func(p *Page) {
+		if p.Err == nil {
+			visited[strings.TrimPrefix(p.URL, srv.URL)]++
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/", "/a", "/a/1", "/a/1/deep", "/b", "/b/1"} {
+		if visited[path] != 1 {
+			t.Errorf("%s visited %d times, want once", path, visited[path])
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Crawl4 crawls web links starting with the command-line arguments.
+//
+// This version uses gopl.io/ch08/crawl, so unlike crawl3 it
+// terminates, limits its depth, is polite to each host it visits,
+// and stops cleanly on interrupt.
+//
+// The -o flag selects the output: a list of URLs as they are
+// crawled (the default), an XML sitemap, the link graph in Graphviz
+// DOT or JSON form, or a report of broken links.  For example:
+//
+//	$ crawl4 -o broken -external https://go.dev/doc/
+//
+// With -state, progress is saved to a file as the crawl proceeds,
+// and a later run with -resume continues where it left off.  The
+// state records only which URLs remain to be fetched, not the pages
+// fetched already, so a resumed crawl can list only the URLs it
+// fetches itself, and cannot produce the other outputs.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"gopl.io/ch08/crawl"
+)
+
+var c crawl.Crawler
+
+func init() {
+	flag.IntVar(&c.MaxDepth, "depth", 3, "maximum link depth; 0 means no limit")
+	flag.IntVar(&c.Workers, "workers", 20, "maximum concurrent requests")
+	flag.IntVar(&c.PerHost, "perhost", 2, "maximum concurrent requests per host")
+	flag.DurationVar(&c.Delay, "delay", 0, "minimum interval between requests to a host")
+	flag.StringVar(&c.UserAgent, "agent", "gopl-crawl/1.0", "User-Agent header and robots.txt name")
+	flag.BoolVar(&c.SameDomain, "samedomain", true, "only follow links to the hosts of the arguments")
+	flag.BoolVar(&c.NoRobots, "norobots", false, "ignore robots.txt")
+	flag.BoolVar(&c.CheckExternal, "external", false, "check the status of out-of-scope links")
+}
+
+var (
+	allow  = flag.String("allow", "", "comma-separated list of additional hosts to follow")
+	output = flag.String("o", "list", "output: list, sitemap, dot, json, or broken")
+	state  = flag.String("state", "", "file in which to save the progress of the crawl")
+	resume = flag.Bool("resume", false, "resume the crawl saved in the -state file")
+)
+
+func main() {
+	flag.Parse()
+	if *allow != "" {
+		c.Allow = strings.Split(*allow, ",")
+	}
+	var g crawl.Graph
+	var write func(io.Writer) error
+	switch *output {
+	case "list":
+	case "sitemap":
+		write = g.WriteSitemap
+	case "dot":
+		write = g.WriteDOT
+	case "json":
+		write = g.WriteJSON
+	case "broken":
+		write = g.WriteBroken
+	default:
+		log.Fatalf("unknown output %q", *output)
+	}
+
+	if *resume && *state == "" {
+		log.Fatal("-resume requires -state")
+	}
+	if *resume && write != nil {
+		// The pages fetched before the interruption are not in
+		// the state, so the report would silently omit them.
+		log.Fatalf("-o %s cannot be used with -resume: the report would lack the pages fetched before", *output)
+	}
+	if *state != "" {
+		st, err := crawl.OpenState(*state, *resume)
+		if err != nil {
+			log.Fatal(err)
+		}
+		c.State = st
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sigint := make(chan os.Signal, 1)
+		signal.Notify(sigint, os.Interrupt)
+		<-sigint
+		cancel()
+	}()
+
+	err := c.Crawl(ctx, flag.Args(), func(p *crawl.Page) {
+		if write != nil {
+			g.Add(p)
+			return
+		}
+		if p.Err != nil {
+			log.Print(p.Err)
+			return
+		}
+		fmt.Println(p.URL)
+	})
+	if c.State != nil {
+		if err := c.State.Close(); err != nil {
+			log.Print(err)
+		}
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if write != nil {
+		if err := write(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const browseHelp = `Commands:
+  N or name  enter the Nth or named subdirectory
+  ..         go up to the parent directory
+  q          quit
+`
+
+// browse lets the user explore the tree t, reading commands from in
+// and writing listings to out, until the user quits or in is exhausted.
+func browse(in io.Reader, out io.Writer, t *tree) {
+	// A nil current directory stands for the list of roots.
+	var cur *node
+	if len(t.roots) == 1 {
+		cur = t.roots[0]
+	}
+	sc := bufio.NewScanner(in)
+	for {
+		children := list(out, t, cur)
+		fmt.Fprint(out, "> ")
+		if !sc.Scan() {
+			fmt.Fprintln(out)
+			return
+		}
+		cmd := strings.TrimSpace(sc.Text())
+		switch cmd {
+		case "":
+		case "q", "quit":
+			return
+		case "..":
+			if cur != nil && (cur.parent != nil || len(t.roots) > 1) {
+				cur = cur.parent
+			}
+		case "?", "help":
+			fmt.Fprint(out, browseHelp)
+		default:
+			var next *node
+			if i, err := strconv.Atoi(cmd); err == nil && i >= 1 && i <= len(children) {
+				next = children[i-1]
+			} else {
+				for _, c := range children {
+					if c.name == cmd {
+						next = c
+					}
+				}
+			}
+			if next == nil {
+				fmt.Fprintf(out, "no such directory: %s (type ? for help)\n", cmd)
+				continue
+			}
+			cur = next
+		}
+	}
+}
+
+// list writes a listing of the subdirectories of dir, or of the roots
+// of t if dir is nil, largest first, and returns them in that order.
+func list(out io.Writer, t *tree, dir *node) []*node {
+	var children []*node
+	var total int64
+	if dir == nil {
+		children, total = t.roots, t.total()
+		fmt.Fprintf(out, "\n%s in %d roots\n", human(total), len(children))
+	} else {
+		children, total = dir.sorted(), dir.size
+		fmt.Fprintf(out, "\n%s\t%s (%d files)\n", human(total), dir.path(), dir.nfiles)
+	}
+	for i, c := range children {
+		pct := 0.0
+		if total > 0 {
+			pct = 100 * float64(c.size) / float64(total)
+		}
+		fmt.Fprintf(out, "%4d %8s %5.1f%%  %s/\n", i+1, human(c.size), pct, c.name)
+	}
+	if dir != nil && dir.own > 0 {
+		fmt.Fprintf(out, "     %8s %5.1f%%  (files)\n", human(dir.own), 100*float64(dir.own)/float64(total))
+	}
+	return children
+}
@@ -0,0 +1,156 @@
+// The du5 command reports the disk usage of the files in a directory,
+// broken down by directory.
+package main
+
+// The du5 variant builds on du4: it walks the roots in parallel,
+// using gopl.io/ch08/walk, and stops early on interrupt, but rather
+// than printing only grand totals it aggregates sizes into a tree of
+// directories, which it can print to a given depth (like du -d N),
+// summarize as the largest files and directories, or browse
+// interactively.  Files with several hard links are counted once.
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopl.io/ch08/walk"
+)
+
+var (
+	depth       = flag.Int("d", 0, "print totals for directories at most `N` levels below the roots")
+	top         = flag.Int("top", 0, "list the `N` largest files and directories")
+	apparent    = flag.Bool("apparent", false, "report apparent sizes rather than disk allocation")
+	interactive = flag.Bool("i", false, "browse the results interactively")
+	verbose     = flag.Bool("v", false, "show verbose progress messages")
+	excludes    globs
+)
+
+func init() {
+	flag.Var(&excludes, "exclude", "skip files and directories matching `glob` (repeatable)")
+}
+
+// globs is a flag.Value that accumulates patterns.
+type globs []string
+
+func (g *globs) String() string { return strings.Join(*g, ",") }
+
+func (g *globs) Set(pattern string) error {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return err
+	}
+	*g = append(*g, pattern)
+	return nil
+}
+
+// excluded reports whether the file at path matches an exclusion.
+// A pattern containing a separator is matched against the whole
+// path, otherwise only against the file's name.
+func excluded(path string) bool {
+	for _, pattern := range excludes {
+		name := filepath.Base(path)
+		if strings.ContainsRune(pattern, filepath.Separator) {
+			name = path
+		}
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func main() {
+	flag.Parse()
+
+	// Determine the initial directories.
+	roots := flag.Args()
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+
+	// Cancel traversal on interrupt.  (Unlike du4, we cannot use
+	// standard input, which the interactive browser needs.)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sigint := make(chan os.Signal, 1)
+		signal.Notify(sigint, os.Interrupt)
+		<-sigint
+		cancel()
+		signal.Stop(sigint)
+	}()
+
+	// Traverse the roots in parallel, aggregating the results
+	// and printing progress periodically.
+	var tick <-chan time.Time
+	if *verbose {
+		tick = time.Tick(500 * time.Millisecond)
+	}
+	t := newTree(*apparent, *top)
+	for _, root := range roots {
+		t.root(filepath.Clean(root))
+	}
+	entries := walker().Walk(ctx, roots...)
+loop:
+	for {
+		select {
+		case e, ok := <-entries:
+			if !ok {
+				break loop // entries was closed
+			}
+			if f, ok := fileOf(e); ok {
+				t.add(f)
+			}
+		case <-tick:
+			fmt.Fprintf(os.Stderr, "%d files  %s\n", t.nfiles, human(t.total()))
+		}
+	}
+	if ctx.Err() != nil {
+		fmt.Fprintln(os.Stderr, "du5: interrupted; results are incomplete")
+	}
+
+	for _, r := range t.roots {
+		r.print(os.Stdout, *depth)
+	}
+	if *top > 0 {
+		t.printTop(os.Stdout, *top)
+	}
+	fmt.Printf("%d files  %s\n", t.nfiles, human(t.total()))
+	if *interactive {
+		browse(os.Stdin, os.Stdout, t)
+	}
+}
+
+// walker returns a Walker that applies the -exclude patterns.
+func walker() *walk.Walker {
+	return &walk.Walker{
+		Skip: func(e walk.Entry) bool { return excluded(e.Path) },
+		OnError: func(path string, err error) {
+			fmt.Fprintf(os.Stderr, "du5: %v\n", err)
+		},
+	}
+}
+
+// A file is a regular file found by the walk.
+type file struct {
+	dir  string // the directory containing the file
+	name string
+	stat stat
+}
+
+// fileOf returns the file for e, if e is a regular file.
+func fileOf(e walk.Entry) (file, bool) {
+	if !e.Type().IsRegular() {
+		return file{}, false
+	}
+	info, err := e.Info()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "du5: %v\n", err)
+		return file{}, false
+	}
+	return file{filepath.Dir(e.Path), e.Name(), statOf(info)}, true
+}
@@ -0,0 +1,13 @@
+//go:build !(aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris)
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package main
+
+import "os"
+
+// statOf returns the sizes of the file described by fi.  On this
+// platform the allocated size is not known, so the apparent size is
+// used in its place, and hard links cannot be detected.
+func statOf(fi os.FileInfo) stat {
+	return stat{size: fi.Size(), allocated: fi.Size(), nlink: 1}
+}
@@ -0,0 +1,21 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// statOf returns the sizes and identity of the file described by fi.
+func statOf(fi os.FileInfo) stat {
+	st := stat{size: fi.Size(), allocated: fi.Size(), nlink: 1}
+	if sys, ok := fi.Sys().(*syscall.Stat_t); ok {
+		st.allocated = int64(sys.Blocks) * 512 // st_blocks is always in 512-byte units
+		st.dev = uint64(sys.Dev)
+		st.ino = uint64(sys.Ino)
+		st.nlink = uint64(sys.Nlink)
+	}
+	return st
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+)
+
+// A stat holds what du5 needs to know about a file.
+type stat struct {
+	size      int64  // apparent size, in bytes
+	allocated int64  // disk space allocated, in bytes
+	dev, ino  uint64 // identity, for detecting hard links
+	nlink     uint64 // number of hard links
+}
+
+// A node is a directory in the tree of results.
+type node struct {
+	name     string // the path, for a root; otherwise the base name
+	parent   *node
+	children map[string]*node
+	own      int64 // size of the files directly within
+	size     int64 // size of the files within, at any depth
+	nfiles   int
+}
+
+func (n *node) path() string {
+	if n.parent == nil {
+		return n.name
+	}
+	return filepath.Join(n.parent.path(), n.name)
+}
+
+// sorted returns the children of n, largest first.
+func (n *node) sorted() []*node {
+	var children []*node
+	for _, c := range n.children {
+		children = append(children, c)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		if children[i].size != children[j].size {
+			return children[i].size > children[j].size
+		}
+		return children[i].name < children[j].name
+	})
+	return children
+}
+
+// print writes the size of n and of its descendants to at most
+// depth levels below n, in the manner of du: subdirectories first.
+func (n *node) print(w io.Writer, depth int) {
+	if depth > 0 {
+		var names []string
+		for name := range n.children {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			n.children[name].print(w, depth-1)
+		}
+	}
+	fmt.Fprintf(w, "%s\t%s\n", human(n.size), n.path())
+}
+
+// A tree accumulates the files found by the walk into directories.
+// It is used only by the main goroutine.
+type tree struct {
+	apparent bool
+	roots    []*node
+	dirs     map[string]*node // keyed by path
+	inodes   map[[2]uint64]bool
+	nfiles   int
+	largest  fileHeap // the ntop largest files seen so far
+	ntop     int
+}
+
+func newTree(apparent bool, ntop int) *tree {
+	return &tree{
+		apparent: apparent,
+		dirs:     make(map[string]*node),
+		inodes:   make(map[[2]uint64]bool),
+		ntop:     ntop,
+	}
+}
+
+// root adds the directory at path as a root of the tree.
+func (t *tree) root(path string) {
+	if t.dirs[path] == nil {
+		n := &node{name: path, children: make(map[string]*node)}
+		t.dirs[path] = n
+		t.roots = append(t.roots, n)
+	}
+}
+
+// dir returns the node for the directory at path, which must be
+// within a root, creating it and its ancestors as needed.
+func (t *tree) dir(path string) *node {
+	if n := t.dirs[path]; n != nil {
+		return n
+	}
+	parent := t.dir(filepath.Dir(path))
+	n := &node{name: filepath.Base(path), parent: parent, children: make(map[string]*node)}
+	parent.children[n.name] = n
+	t.dirs[path] = n
+	return n
+}
+
+// add adds the file f to the tree.  A file with several hard links
+// is counted only the first time it is seen.
+func (t *tree) add(f file) {
+	if f.stat.nlink > 1 {
+		id := [2]uint64{f.stat.dev, f.stat.ino}
+		if t.inodes[id] {
+			return
+		}
+		t.inodes[id] = true
+	}
+	size := f.stat.allocated
+	if t.apparent {
+		size = f.stat.size
+	}
+	d := t.dir(f.dir)
+	d.own += size
+	for n := d; n != nil; n = n.parent {
+		n.size += size
+		n.nfiles++
+	}
+	t.nfiles++
+
+	if t.ntop > 0 {
+		heap.Push(&t.largest, sized{filepath.Join(f.dir, f.name), size})
+		if t.largest.Len() > t.ntop {
+			heap.Pop(&t.largest)
+		}
+	}
+}
+
+// total returns the size of all the files in the tree.
+func (t *tree) total() int64 {
+	var total int64
+	for _, r := range t.roots {
+		total += r.size
+	}
+	return total
+}
+
+// printTop writes the n largest files, and the n directories whose
+// own files are largest.
+func (t *tree) printTop(w io.Writer, n int) {
+	files := append(fileHeap(nil), t.largest...)
+	sort.Sort(sort.Reverse(files))
+	fmt.Fprintf(w, "\nLargest files:\n")
+	for _, f := range files {
+		fmt.Fprintf(w, "%s\t%s\n", human(f.size), f.path)
+	}
+
+	var dirs []sized
+	for path, d := range t.dirs {
+		if d.own > 0 {
+			dirs = append(dirs, sized{path, d.own})
+		}
+	}
+	sort.Slice(dirs, func(i, j int) bool { return dirs[i].size > dirs[j].size })
+	if len(dirs) > n {
+		dirs = dirs[:n]
+	}
+	fmt.Fprintf(w, "\nLargest directories (excluding subdirectories):\n")
+	for _, d := range dirs {
+		fmt.Fprintf(w, "%s\t%s\n", human(d.size), d.path)
+	}
+	fmt.Fprintln(w)
+}
+
+// A sized is a path and its size.
+type sized struct {
+	path string
+	size int64
+}
+
+// A fileHeap is a min-heap of files by size (see container/heap).
+type fileHeap []sized
+
+func (h fileHeap) Len() int            { return len(h) }
+func (h fileHeap) Less(i, j int) bool  { return h[i].size < h[j].size }
+func (h fileHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *fileHeap) Push(x interface{}) { *h = append(*h, x.(sized)) }
+func (h *fileHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// human formats a number of bytes in the manner of du -h.
+func human(n int64) string {
+	const units = "KMGTPE"
+	if n < 1024 {
+		return fmt.Sprintf("%dB", n)
+	}
+	f, i := float64(n)/1024, 0
+	for f >= 1024 && i < len(units)-1 {
+		f /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f%c", f, units[i])
+}
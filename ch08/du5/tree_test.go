@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// scan walks root as main does and returns the resulting tree.
+func scan(t *testing.T, root string) *tree {
+	t.Helper()
+	tr := newTree(true, 2)
+	tr.root(root)
+	for e := range walker().Walk(context.Background(), root) {
+		if f, ok := fileOf(e); ok {
+			tr.add(f)
+		}
+	}
+	return tr
+}
+
+func TestTree(t *testing.T) {
+	root := t.TempDir()
+	for path, size := range map[string]int{
+		"a/1":       100,
+		"a/b/2":     200,
+		"a/b/3.tmp": 5000,
+		"c/4":       50,
+		"5":         1,
+	} {
+		path = filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A hard link to a/1 must not be counted again.  Which of the
+	// two names is counted depends on the order of the walk.
+	if err := os.Link(filepath.Join(root, "a", "1"), filepath.Join(root, "a", "link")); err != nil {
+		t.Logf("no hard links: %v", err)
+	}
+
+	excludes = globs{"*.tmp"}
+	defer func() { excludes = nil }()
+	tr := scan(t, root)
+
+	if got, want := tr.total(), int64(351); got != want {
+		t.Errorf("total = %d, want %d", got, want)
+	}
+	var buf bytes.Buffer
+	tr.roots[0].print(&buf, 1)
+	got := strings.Replace(buf.String(), root, "R", -1)
+	want := "300B\tR/a\n50B\tR/c\n351B\tR\n"
+	if got != want {
+		t.Errorf("print to depth 1:\n%s\nwant:\n%s", got, want)
+	}
+
+	buf.Reset()
+	tr.printTop(&buf, 2)
+	got = strings.Replace(buf.String(), root, "R", -1)
+	for _, want := range []string{"200B\tR/a/b/2\n100B\tR/a/", "200B\tR/a/b\n100B\tR/a\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("top 2 lacks %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestBrowse(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "x", "y"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "x", "y", "f"), make([]byte, 10), 0666); err != nil {
+		t.Fatal(err)
+	}
+	tr := scan(t, root)
+	var out bytes.Buffer
+	browse(strings.NewReader("1\ny\n..\nnope\nq\n"), &out, tr)
+	got := strings.Replace(out.String(), root, "R", -1)
+	for _, want := range []string{
+		"10B\tR (1 files)\n   1      10B 100.0%  x/\n",
+		"10B\tR/x/y (1 files)\n          10B 100.0%  (files)\n",
+		"no such directory: nope",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("browse output lacks %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"gopl.io/ch08/walk"
+)
+
+// partialSize is the number of leading bytes hashed to tell apart
+// files of the same size cheaply, before hashing them in full.
+const partialSize = 4096
+
+// An inode identifies a file independently of its names.
+type inode struct{ dev, ino uint64 }
+
+// A Set is a set of files with identical contents.
+type Set struct {
+	Size  int64    // size of each file
+	Paths []string // in sorted order
+
+	modTimes map[string]time.Time // of each path, when it was scanned
+}
+
+// Reclaimable returns the number of bytes that would be freed if all
+// but one of the files in the set were removed.
+func (s Set) Reclaimable() int64 { return s.Size * int64(len(s.Paths)-1) }
+
+// A finder finds duplicate files.
+type finder struct {
+	minSize int64 // smaller files are ignored
+	workers int   // number of files hashed at once
+	onError func(path string, err error)
+}
+
+// find walks the trees rooted at roots and returns the sets of
+// duplicate files within them, most reclaimable first.
+//
+// Files are grouped first by size, then by a hash of their first
+// few kilobytes, and only then by a SHA-256 hash of their entire
+// contents, so that most files are never read in full.  Names that
+// are hard links to the same file count as one file.
+func (fd *finder) find(ctx context.Context, roots []string) ([]Set, error) {
+	// Group the files found by size.
+	bySize := make(map[int64][]string)
+	modTimes := make(map[string]time.Time)
+	seen := make(map[inode]bool)
+	w := &walk.Walker{OnError: fd.onError}
+	for e := range w.Walk(ctx, roots...) {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			fd.onError(e.Path, err)
+			continue
+		}
+		if info.Size() < fd.minSize {
+			continue
+		}
+		if id, ok := inodeOf(info); ok {
+			if seen[id] {
+				continue // another name for a file already seen
+			}
+			seen[id] = true
+		}
+		bySize[info.Size()] = append(bySize[info.Size()], e.Path)
+		modTimes[e.Path] = info.ModTime()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Refine groups by partial hash, then by full hash.
+	var sets []Set
+	for size, paths := range bySize {
+		if len(paths) < 2 {
+			continue
+		}
+		for _, group := range fd.refine(ctx, paths, partialSize) {
+			if size > partialSize {
+				// Files no larger than partialSize were
+				// hashed in full the first time.
+				for _, g := range fd.refine(ctx, group, -1) {
+					sets = append(sets, newSet(size, g, modTimes))
+				}
+			} else {
+				sets = append(sets, newSet(size, group, modTimes))
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(sets, func(i, j int) bool {
+		if ri, rj := sets[i].Reclaimable(), sets[j].Reclaimable(); ri != rj {
+			return ri > rj
+		}
+		return sets[i].Paths[0] < sets[j].Paths[0]
+	})
+	return sets, nil
+}
+
+func newSet(size int64, paths []string, modTimes map[string]time.Time) Set {
+	sort.Strings(paths)
+	s := Set{Size: size, Paths: paths, modTimes: make(map[string]time.Time)}
+	for _, p := range paths {
+		s.modTimes[p] = modTimes[p]
+	}
+	return s
+}
+
+// refine hashes the first n bytes (or all, if n < 0) of each of
+// paths concurrently, and returns the groups of two or more files
+// whose hashes are equal.  Once ctx is cancelled, no more files are
+// hashed, and the groups returned are incomplete.
+func (fd *finder) refine(ctx context.Context, paths []string, n int64) [][]string {
+	type result struct {
+		path string
+		sum  [sha256.Size]byte
+		err  error
+	}
+	results := make(chan result)
+	sema := make(chan struct{}, fd.workers)
+	var wg sync.WaitGroup
+	for _, path := range paths {
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			sema <- struct{}{}
+			var sum [sha256.Size]byte
+			err := ctx.Err()
+			if err == nil {
+				sum, err = hashFile(ctx, path, n)
+			}
+			<-sema
+			results <- result{path, sum, err}
+		}(path)
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	byHash := make(map[[sha256.Size]byte][]string)
+	for r := range results {
+		if r.err != nil {
+			if ctx.Err() == nil {
+				fd.onError(r.path, r.err)
+			}
+			continue
+		}
+		byHash[r.sum] = append(byHash[r.sum], r.path)
+	}
+	var groups [][]string
+	for _, group := range byHash {
+		if len(group) > 1 {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// hashFile returns the SHA-256 hash of the first n bytes of the named
+// file, or of all of it if n < 0.  It gives up if ctx is cancelled.
+func hashFile(ctx context.Context, path string, n int64) ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+	f, err := os.Open(path)
+	if err != nil {
+		return sum, err
+	}
+	defer f.Close()
+	var r io.Reader = ctxReader{ctx, f}
+	if n >= 0 {
+		r = io.LimitReader(r, n)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return sum, err
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
+
+// A ctxReader is a reader that fails once its context is cancelled,
+// so that a long copy from it stops promptly.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// link replaces each file in s but the first with a hard link to
+// the first, and returns the number of bytes reclaimed.  Each file is
+// replaced atomically, by creating the link under a temporary name
+// and renaming it over the duplicate.  A file whose size or
+// modification time has changed since it was scanned is left alone,
+// as is one that cannot be replaced, such as a file on another
+// device; the error is reported to fd.onError and link goes on to
+// the next.  link stops once ctx is cancelled.
+func (fd *finder) link(ctx context.Context, s Set) int64 {
+	var reclaimed int64
+	keep := s.Paths[0]
+	if err := s.unchanged(keep); err != nil {
+		fd.onError(keep, err)
+		return 0
+	}
+	for _, dup := range s.Paths[1:] {
+		if ctx.Err() != nil {
+			break
+		}
+		err := s.unchanged(dup)
+		if err == nil {
+			err = replace(keep, dup)
+		}
+		if err != nil {
+			fd.onError(dup, err)
+			continue
+		}
+		reclaimed += s.Size
+	}
+	return reclaimed
+}
+
+// unchanged returns an error if the file at path, a member of s, is
+// not as it was when it was scanned.
+func (s Set) unchanged(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() || info.Size() != s.Size || !info.ModTime().Equal(s.modTimes[path]) {
+		return fmt.Errorf("%s has changed since it was scanned", path)
+	}
+	return nil
+}
+
+// replace replaces the file dup with a hard link to keep.
+func replace(keep, dup string) error {
+	// Choose a unique name in the same directory, so that the
+	// rename is atomic, then make the link under that name.
+	f, err := ioutil.TempFile(filepath.Dir(dup), ".dupfiles")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	f.Close()
+	if err := os.Remove(tmp); err != nil {
+		return err
+	}
+	if err := os.Link(keep, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dup); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFind(t *testing.T) {
+	root := t.TempDir()
+	big := strings.Repeat("x", 3*partialSize)
+	for name, content := range map[string]string{
+		"a/small1": "hello",
+		"b/small2": "hello",
+		"small3":   "jello", // same size, different contents
+		"a/big1":   big,
+		"b/big2":   big,
+		"b/big3":   big[:len(big)-1] + "y", // same prefix, differs at the end
+		"empty1":   "",
+		"empty2":   "",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// An existing hard link is not a duplicate.
+	if err := os.Link(filepath.Join(root, "small3"), filepath.Join(root, "small3link")); err != nil {
+		t.Skipf("cannot create hard links: %v", err)
+	}
+
+	fd := &finder{minSize: 1, workers: 2, onError: func(path string, err error) { t.Error(err) }}
+	sets, err := fd.find(context.Background(), []string{root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range sets {
+		var rel []string
+		for _, p := range s.Paths {
+			p, _ = filepath.Rel(root, p)
+			rel = append(rel, filepath.ToSlash(p))
+		}
+		got = append(got, strings.Join(rel, " "))
+	}
+	want := []string{"a/big1 b/big2", "a/small1 b/small2"}
+	if strings.Join(got, "; ") != strings.Join(want, "; ") {
+		t.Errorf("sets = %q, want %q", got, want)
+	}
+	if len(sets) > 0 && sets[0].Reclaimable() != int64(len(big)) {
+		t.Errorf("reclaimable = %d, want %d", sets[0].Reclaimable(), len(big))
+	}
+
+	// A file modified since the scan is not replaced.
+	small2 := filepath.Join(root, "b", "small2")
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(small2, later, later); err != nil {
+		t.Fatal(err)
+	}
+	var errs []string
+	fd.onError = func(path string, err error) { errs = append(errs, path) }
+	var reclaimed int64
+	for _, s := range sets {
+		reclaimed += fd.link(context.Background(), s)
+	}
+	if reclaimed != int64(len(big)) || len(errs) != 1 || errs[0] != small2 {
+		t.Errorf("linking reclaimed %d bytes with errors for %q, want %d, [%s]", reclaimed, errs, len(big), small2)
+	}
+
+	// Linking the duplicates again leaves nothing to reclaim.
+	fd.onError = func(path string, err error) { t.Error(err) }
+	sets, err = fd.find(context.Background(), []string{root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range sets {
+		fd.link(context.Background(), s)
+	}
+	sets, err = fd.find(context.Background(), []string{root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sets) != 0 {
+		t.Errorf("after linking, found %d sets, want 0", len(sets))
+	}
+	if data, _ := os.ReadFile(small2); string(data) != "hello" {
+		t.Errorf("after linking, b/small2 contains %q", data)
+	}
+}
+
+func TestFindCancel(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a", "b"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("same"), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	fd := &finder{minSize: 1, workers: 2, onError: func(path string, err error) {}}
+	if sets, err := fd.find(ctx, []string{root}); err != context.Canceled || sets != nil {
+		t.Errorf("find after cancel = %v, %v, want nil, %v", sets, err, context.Canceled)
+	}
+	if _, err := hashFile(ctx, filepath.Join(root, "a"), -1); err != context.Canceled {
+		t.Errorf("hashFile after cancel: %v, want %v", err, context.Canceled)
+	}
+}
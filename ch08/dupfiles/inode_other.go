@@ -0,0 +1,12 @@
+//go:build !(aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris)
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package main
+
+import "os"
+
+// inodeOf reports false: on this platform the identity of a file
+// is not known, so existing hard links are not recognized.
+func inodeOf(fi os.FileInfo) (inode, bool) {
+	return inode{}, false
+}
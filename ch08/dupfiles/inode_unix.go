@@ -0,0 +1,19 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// inodeOf returns the identity of the file described by fi,
+// so that hard links to the same file can be recognized.
+func inodeOf(fi os.FileInfo) (inode, bool) {
+	sys, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return inode{}, false
+	}
+	return inode{uint64(sys.Dev), uint64(sys.Ino)}, true
+}
@@ -0,0 +1,69 @@
+// The dupfiles command reports sets of files with identical contents.
+//
+// Like du4 it walks the directories named on the command line in
+// parallel, and it stops early on interrupt.  It prints each set of
+// duplicates, largest saving first, followed by the total space that
+// could be reclaimed.  With -link, it replaces all but one file in
+// each set by a hard link to the one that remains.
+//
+//	$ dupfiles -min 1048576 ~/Downloads ~/Documents
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+)
+
+var (
+	minSize = flag.Int64("min", 1, "ignore files smaller than `bytes`")
+	workers = flag.Int("workers", 8, "number of files to hash at once")
+	doLink  = flag.Bool("link", false, "replace duplicates with hard links")
+)
+
+func main() {
+	flag.Parse()
+	roots := flag.Args()
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sigint := make(chan os.Signal, 1)
+		signal.Notify(sigint, os.Interrupt)
+		<-sigint
+		cancel()
+	}()
+
+	fd := &finder{
+		minSize: *minSize,
+		workers: *workers,
+		onError: func(path string, err error) {
+			fmt.Fprintf(os.Stderr, "dupfiles: %v\n", err)
+		},
+	}
+	sets, err := fd.find(ctx, roots)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dupfiles: %v\n", err)
+		os.Exit(1)
+	}
+
+	var total, reclaimed int64
+	for _, s := range sets {
+		fmt.Printf("%d files of %d bytes, %d reclaimable:\n", len(s.Paths), s.Size, s.Reclaimable())
+		for _, path := range s.Paths {
+			fmt.Printf("\t%s\n", path)
+		}
+		total += s.Reclaimable()
+		if *doLink {
+			reclaimed += fd.link(ctx, s)
+		}
+	}
+	fmt.Printf("%d sets of duplicates, %.1f MB reclaimable\n", len(sets), float64(total)/1e6)
+	if *doLink {
+		fmt.Printf("%.1f MB reclaimed\n", float64(reclaimed)/1e6)
+	}
+}
@@ -0,0 +1,80 @@
+// Mirror makes an offline copy of the web sites named by its
+// command-line arguments.
+//
+// It crawls each site with gopl.io/ch08/crawl, saving every page and
+// asset found by gopl.io/ch05/links under a directory named for the
+// host, with links between saved files rewritten to relative paths so
+// that the copy can be browsed locally.  A later run over the same
+// directory makes conditional requests, so only what has changed is
+// downloaded again.
+//
+//	$ mirror -dir wiki https://wiki.example.com/
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+
+	"gopl.io/ch08/crawl"
+)
+
+var (
+	dir      = flag.String("dir", ".", "directory in which to store the mirror")
+	depth    = flag.Int("depth", 0, "maximum link depth; 0 means no limit")
+	perHost  = flag.Int("perhost", 2, "maximum concurrent requests per host")
+	delay    = flag.Duration("delay", 0, "minimum interval between requests to a host")
+	agent    = flag.String("agent", "gopl-mirror/1.0", "User-Agent header and robots.txt name")
+	noRobots = flag.Bool("norobots", false, "ignore robots.txt")
+)
+
+func main() {
+	flag.Parse()
+	m, err := openMirror(*dir, flag.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+	c := &crawl.Crawler{
+		MaxDepth:   *depth,
+		PerHost:    *perHost,
+		Delay:      *delay,
+		UserAgent:  *agent,
+		SameDomain: true,
+		NoRobots:   *noRobots,
+		Request:    m.request,
+		Response:   m.response,
+		Follow:     m.follow,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sigint := make(chan os.Signal, 1)
+		signal.Notify(sigint, os.Interrupt)
+		<-sigint
+		cancel()
+	}()
+
+	var nsaved, nfresh, nfailed int
+	err = c.Crawl(ctx, flag.Args(), func(p *crawl.Page) {
+		switch {
+		case p.Err != nil:
+			log.Print(p.Err)
+			nfailed++
+		case p.Status == 304:
+			nfresh++
+		default:
+			nsaved++
+		}
+	})
+	// Save the index even if interrupted, so that the
+	// next run can make conditional requests.
+	if err := m.close(); err != nil {
+		log.Print(err)
+	}
+	log.Printf("%d saved, %d unchanged, %d failed", nsaved, nfresh, nfailed)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopl.io/ch08/crawl"
+)
+
+var site = map[string]string{
+	"/": `<html><head><link rel="stylesheet" href="/style.css"></head><body>
+<a href="/docs/">Docs</a> <img src="logo.png"> <a href="http://other.example/">Elsewhere</a>
+<a href="/private" rel="nofollow">Private</a>
+</body></html>`,
+	"/docs/":      `<html><body><a href="/">Home</a> <a href="intro#start">Intro</a></body></html>`,
+	"/docs/intro": `<html><body><a href="../">Up</a> <a href="intro?b=2&amp;a=1">Sorted</a></body></html>`,
+	"/style.css":  `body { color: black }`,
+	"/logo.png":   "\x89PNG",
+}
+
+// siteServer serves pages with ETags, counting the responses of each status.
+type siteServer struct {
+	mu     sync.Mutex
+	pages  map[string]string
+	counts map[int]int
+}
+
+func (s *siteServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	body, ok := s.pages[r.URL.Path]
+	s.mu.Unlock()
+	status := http.StatusOK
+	etag := fmt.Sprintf(`"%x"`, len(body))
+	switch {
+	case !ok:
+		status = http.StatusNotFound
+		http.NotFound(w, r)
+	case r.Header.Get("If-None-Match") == etag:
+		status = http.StatusNotModified
+		w.WriteHeader(status)
+	default:
+		w.Header().Set("ETag", etag)
+		http.ServeContent(w, r, r.URL.Path, time.Time{}, strings.NewReader(body))
+	}
+	s.mu.Lock()
+	s.counts[status]++
+	s.mu.Unlock()
+}
+
+func run(t *testing.T, dir, seed string) {
+	t.Helper()
+	m, err := openMirror(dir, []string{seed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &crawl.Crawler{SameDomain: true, Request: m.request, Response: m.response, Follow: m.follow}
+	err = c.Crawl(context.Background(), []string{seed}, func(p *crawl.Page) {
+		if p.Err != nil {
+			t.Error(p.Err)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMirror(t *testing.T) {
+	ss := &siteServer{pages: site, counts: make(map[int]int)}
+	srv := httptest.NewServer(ss)
+	defer srv.Close()
+	dir := t.TempDir()
+	host := strings.Replace(strings.TrimPrefix(srv.URL, "http://"), ":", "_", -1)
+
+	// The intro page is fetched twice, with and without a query.
+	const nfetch = 6
+	run(t, dir, srv.URL+"/")
+	if ss.counts[200] != nfetch {
+		t.Errorf("first run: %d pages fetched, want %d", ss.counts[200], nfetch)
+	}
+	for path, want := range map[string]string{
+		"index.html":      `<a href="docs/index.html">Docs</a> <img src="logo.png"/> <a href="http://other.example/">Elsewhere</a>`,
+		"docs/index.html": `<a href="../index.html">Home</a> <a href="intro.html#start">Intro</a>`,
+		"docs/intro.html": `<a href="../index.html">Up</a>`,
+		"style.css":       `body { color: black }`,
+		"logo.png":        "\x89PNG",
+	} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, host, path))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if !strings.Contains(string(data), want) {
+			t.Errorf("%s does not contain %s:\n%s", path, want, data)
+		}
+	}
+
+	// A link that was not followed stays absolute, and one with
+	// its query in another order leads to the same copy.
+	for path, want := range map[string]string{
+		"index.html":      `<a href="` + srv.URL + `/private" rel="nofollow">Private</a>`,
+		"docs/intro.html": fmt.Sprintf(`<a href="intro@%x.html">Sorted</a>`, sha1.Sum([]byte("a=1&b=2"))),
+	} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, host, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), want) {
+			t.Errorf("%s does not contain %s:\n%s", path, want, data)
+		}
+	}
+
+	// A second run finds nothing changed, but must still
+	// follow the links of the unchanged pages.
+	ss.counts = make(map[int]int)
+	run(t, dir, srv.URL+"/")
+	if ss.counts[200] != 0 || ss.counts[304] != nfetch {
+		t.Errorf("second run: %d fetched, %d unchanged, want 0, %d", ss.counts[200], ss.counts[304], nfetch)
+	}
+}
+
+func TestMirrorCollision(t *testing.T) {
+	ss := &siteServer{counts: make(map[int]int), pages: map[string]string{
+		"/":               `<a href="/a">A</a> <a href="/a.html">A.html</a> <a href="/dir">Dir</a> <a href="/dir/">Dir/</a>`,
+		"/a":              "first",
+		"/a.html":         "second",
+		"/dir":            "third",
+		"/dir/":           "fourth",
+		"/dir/index.html": "fifth", // linked only from a later run
+	}}
+	srv := httptest.NewServer(ss)
+	defer srv.Close()
+	dir := t.TempDir()
+
+	// check verifies that each page has a copy of its own, with
+	// its own text in it.
+	check := func(run int) {
+		t.Helper()
+		m, err := openMirror(dir, []string{srv.URL})
+		if err != nil {
+			t.Fatal(err)
+		}
+		owners := make(map[string]string)
+		for url, e := range m.index {
+			if prev, ok := owners[e.Path]; ok {
+				t.Errorf("run %d: %s and %s both saved at %s", run, prev, url, e.Path)
+			}
+			owners[e.Path] = url
+			body := ss.pages[strings.TrimPrefix(url, srv.URL)]
+			if strings.Contains(body, "<") {
+				continue
+			}
+			data, err := ioutil.ReadFile(filepath.Join(dir, e.Path))
+			if err != nil {
+				t.Error(err)
+			} else if !strings.Contains(string(data), body) {
+				t.Errorf("run %d: copy of %s at %s is %q, want it to contain %q", run, url, e.Path, data, body)
+			}
+		}
+	}
+	run(t, dir, srv.URL+"/")
+	check(1)
+	if n := ss.counts[200]; n != 5 {
+		t.Errorf("first run: %d pages fetched, want 5", n)
+	}
+
+	// A page is linked from an unchanged page only in its saved copy,
+	// and must take a name distinct from that of /dir/.
+	ss.mu.Lock()
+	ss.pages["/dir/"] = `<a href="index.html">Index</a>`
+	ss.mu.Unlock()
+	run(t, dir, srv.URL+"/")
+	check(2)
+}
+
+func TestMirrorUnchangedLinks(t *testing.T) {
+	ss := &siteServer{counts: make(map[int]int), pages: map[string]string{
+		"/": `<a href="/new">New</a>`,
+	}}
+	srv := httptest.NewServer(ss)
+	defer srv.Close()
+	dir := t.TempDir()
+	host := strings.Replace(strings.TrimPrefix(srv.URL, "http://"), ":", "_", -1)
+
+	// The first run cannot fetch /new, so its link stays absolute.
+	m, err := openMirror(dir, []string{srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &crawl.Crawler{SameDomain: true, Request: m.request, Response: m.response, Follow: m.follow}
+	if err := c.Crawl(context.Background(), []string{srv.URL + "/"}, func(*crawl.Page) {}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second run finds / unchanged, but must still reach /new
+	// through the saved copy, and point the copy at it.
+	ss.mu.Lock()
+	ss.pages["/new"] = "new"
+	ss.mu.Unlock()
+	ss.counts = make(map[int]int)
+	run(t, dir, srv.URL+"/")
+	if ss.counts[304] != 1 || ss.counts[200] != 1 {
+		t.Errorf("second run: %d fetched, %d unchanged, want 1, 1", ss.counts[200], ss.counts[304])
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, host, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `<a href="new.html">New</a>`; !strings.Contains(string(data), want) {
+		t.Errorf("index.html does not contain %s:\n%s", want, data)
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopl.io/ch05/links"
+	"gopl.io/ch08/crawl"
+)
+
+// indexFile is the name, within the mirror directory, of the file
+// recording what has been saved.
+const indexFile = ".mirror.json"
+
+// A mirror is a local copy of one or more web sites.
+type mirror struct {
+	dir   string
+	hosts []string // lower case
+
+	mu     sync.Mutex        // guards index, owners and pages
+	index  map[string]*entry // keyed by URL
+	owners map[string]string // URL of each saved file, by path
+	pages  []pending         // the HTML pages whose links need rewriting
+}
+
+// A pending page is an HTML page whose links are yet to be rewritten.
+type pending struct {
+	path string   // relative to the mirror directory
+	base *url.URL // against which its links are resolved
+}
+
+// An entry records a saved file.
+type entry struct {
+	Path         string `json:"path"` // relative to the mirror directory
+	Type         string `json:"type,omitempty"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// openMirror opens the mirror in dir, creating it if necessary,
+// to hold copies of the sites of the given URLs.
+func openMirror(dir string, sites []string) (*mirror, error) {
+	m := &mirror{dir: dir, index: make(map[string]*entry), owners: make(map[string]string)}
+	for _, site := range sites {
+		u, err := url.Parse(site)
+		if err != nil {
+			return nil, err
+		}
+		m.hosts = append(m.hosts, strings.ToLower(u.Hostname()))
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, indexFile))
+	if os.IsNotExist(err) {
+		return m, os.MkdirAll(dir, 0777)
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &m.index); err != nil {
+		return nil, fmt.Errorf("reading %s: %v", indexFile, err)
+	}
+	for url, e := range m.index {
+		m.owners[e.Path] = url
+	}
+	return m, nil
+}
+
+// close rewrites the links of the pages saved by this run and saves
+// the index of the mirror.
+func (m *mirror) close() error {
+	if err := m.relink(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	data, err := json.MarshalIndent(m.index, "", "\t")
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(m.dir, indexFile), data)
+}
+
+func (m *mirror) lookup(url string) *entry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.index[url]
+}
+
+// request makes req conditional if its target has already been saved.
+func (m *mirror) request(req *http.Request) {
+	e := m.lookup(req.URL.String())
+	if e == nil {
+		return
+	}
+	if _, err := os.Stat(filepath.Join(m.dir, e.Path)); err != nil {
+		return // the file has gone; fetch it afresh
+	}
+	if e.ETag != "" {
+		req.Header.Set("If-None-Match", e.ETag)
+	}
+	if e.LastModified != "" {
+		req.Header.Set("If-Modified-Since", e.LastModified)
+	}
+}
+
+// response saves the body of resp and sets the page's links.  The
+// links of an HTML page are rewritten by close, once it is known
+// which of their targets have been saved.
+func (m *mirror) response(page *crawl.Page, resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		if e := m.lookup(page.URL); e != nil && e.Type == "text/html" {
+			return m.rescan(page, e)
+		}
+		return nil
+	case http.StatusOK:
+	default:
+		return fmt.Errorf("getting %s: %s", page.URL, resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("getting %s: %v", page.URL, err)
+	}
+	u, err := url.Parse(page.URL)
+	if err != nil {
+		return err
+	}
+	e := &entry{
+		Path:         m.claim(page.URL, localPath(u)),
+		Type:         page.Type,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	if page.Type == "text/html" {
+		if page.Links, err = links.ExtractHTML(resp.Request.URL, bytes.NewReader(body)); err != nil {
+			return err
+		}
+	}
+
+	if err := writeFile(filepath.Join(m.dir, e.Path), body); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.index[page.URL] = e
+	if page.Type == "text/html" {
+		m.pages = append(m.pages, pending{e.Path, resp.Request.URL})
+	}
+	m.mu.Unlock()
+	return nil
+}
+
+// claim returns the path at which to save the resource at rawurl,
+// which localPath would store at path, and reserves it.  A resource
+// keeps the path it was saved at before.  Since several URLs may have
+// the same local path, such as /a and /a.html, a path already taken
+// by another URL is made distinct by a hash of rawurl.
+func (m *mirror) claim(rawurl, path string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e := m.index[rawurl]; e != nil {
+		return e.Path
+	}
+	ext := filepath.Ext(path)
+	for i := 0; ; i++ {
+		owner, taken := m.owners[path]
+		if !taken || owner == rawurl {
+			break
+		}
+		path = fmt.Sprintf("%s@%x%s", strings.TrimSuffix(path, ext), sha1.Sum([]byte(fmt.Sprint(rawurl, i))), ext)
+	}
+	m.owners[path] = rawurl
+	return path
+}
+
+// rescan sets the links of page, which is unchanged, from the saved
+// copy e, and schedules that copy's links to be rewritten, since
+// targets not saved before may have been saved now.
+func (m *mirror) rescan(page *crawl.Page, e *entry) error {
+	data, err := ioutil.ReadFile(filepath.Join(m.dir, e.Path))
+	if err != nil {
+		return err
+	}
+	base := fileURL(e.Path)
+	ls, err := links.ExtractHTML(base, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for _, l := range ls {
+		if orig, ok := m.original(l.URL); ok {
+			l.URL = orig
+			page.Links = append(page.Links, l)
+		} else if !strings.HasPrefix(l.URL, "file:") {
+			page.Links = append(page.Links, l)
+		}
+	}
+	m.mu.Lock()
+	m.pages = append(m.pages, pending{e.Path, base})
+	m.mu.Unlock()
+	return nil
+}
+
+// fileURL returns the URL against which the relative links of the
+// saved copy at path, relative to the mirror directory, are resolved.
+func fileURL(path string) *url.URL {
+	return &url.URL{Scheme: "file", Path: "/" + filepath.ToSlash(path)}
+}
+
+// original returns the URL of the resource saved at target, a URL
+// made by resolving a link in a saved copy against its fileURL, and
+// reports whether there is one.
+func (m *mirror) original(target string) (string, bool) {
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme != "file" {
+		return "", false
+	}
+	m.mu.Lock()
+	owner, ok := m.owners[filepath.FromSlash(strings.TrimPrefix(u.Path, "/"))]
+	m.mu.Unlock()
+	if ok && u.Fragment != "" {
+		owner += "#" + u.Fragment
+	}
+	return owner, ok
+}
+
+// relink rewrites the links of each HTML page saved or rescanned by
+// this run: those to saved files become relative paths, and the rest
+// become absolute URLs, so that they still work from the local copy.
+func (m *mirror) relink() error {
+	m.mu.Lock()
+	pages := m.pages
+	m.pages = nil
+	m.mu.Unlock()
+	for _, p := range pages {
+		name := filepath.Join(m.dir, p.path)
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		err = links.Rewrite(&buf, p.base, bytes.NewReader(data), func(l links.Link) (string, bool) {
+			target := l.URL
+			if p.base.Scheme == "file" {
+				var ok bool
+				if target, ok = m.original(target); !ok {
+					if strings.HasPrefix(l.URL, "file:") {
+						return "", false // leave it be
+					}
+					target = l.URL
+				}
+			}
+			return m.relative(p.path, target)
+		})
+		if err != nil {
+			return err
+		}
+		if err := writeFile(name, buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// follow reports whether the crawl should fetch the target of l:
+// pages and assets alike, but not what the site asks not to follow.
+func (m *mirror) follow(l links.Link) bool { return !l.NoFollow() }
+
+// relative returns the path of the saved copy of target relative to
+// the directory of the file from, or target itself, which is
+// absolute, if no copy of it has been saved.
+func (m *mirror) relative(from, target string) (string, bool) {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", false
+	}
+	var e *entry
+	if m.inScope(u) {
+		if rawurl, err := crawl.Normalize(target); err == nil {
+			e = m.lookup(rawurl)
+		}
+	}
+	if e == nil {
+		return target, true // beyond the mirror, or not downloaded
+	}
+	rel, err := filepath.Rel(filepath.Dir(from), e.Path)
+	if err != nil {
+		return target, true
+	}
+	rel = filepath.ToSlash(rel)
+	if u.Fragment != "" {
+		rel += "#" + u.Fragment
+	}
+	return rel, true
+}
+
+// inScope reports whether u is on one of the mirrored hosts, or a
+// subdomain of one, as the crawler's scope requires.
+func (m *mirror) inScope(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	h := strings.ToLower(u.Hostname())
+	for _, s := range m.hosts {
+		if h == s || strings.HasSuffix(h, "."+s) {
+			return true
+		}
+	}
+	return false
+}
+
+// localPath returns the path, relative to the mirror directory, at
+// which the resource at u is stored.  Directories become index.html
+// files, paths without an extension are assumed to be HTML pages,
+// and a query is represented by a hash of it.
+func localPath(u *url.URL) string {
+	p := u.Path
+	if p == "" || strings.HasSuffix(p, "/") {
+		p += "index.html"
+	} else if path.Ext(p) == "" {
+		p += ".html"
+	}
+	p = path.Clean("/" + p)
+	if u.RawQuery != "" {
+		ext := path.Ext(p)
+		p = fmt.Sprintf("%s@%x%s", strings.TrimSuffix(p, ext), sha1.Sum([]byte(u.RawQuery)), ext)
+	}
+	host := strings.Replace(strings.ToLower(u.Host), ":", "_", -1)
+	return filepath.Join(host, filepath.FromSlash(p))
+}
+
+// writeFile writes data to the named file, creating its directory if
+// necessary.  The file is replaced atomically, so an interrupted run
+// does not leave a truncated copy.
+func writeFile(name string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(name), ".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(0644)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
@@ -0,0 +1,206 @@
+// Package walk provides a bounded, cancellable, concurrent traversal
+// of file trees.
+//
+// It is the walkDir function of gopl.io/ch08/du4 made reusable: the
+// global semaphore becomes a configurable limit on parallelism, the
+// global done channel becomes a context.Context, errors are reported
+// to a callback rather than printed, and every entry found is
+// streamed to the caller rather than just the sizes of files.
+package walk
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// An Entry is a file or directory found by the walk.
+type Entry struct {
+	Path string // the path of the entry, beginning with its root
+	fs.DirEntry
+}
+
+// A SymlinkPolicy determines how the walk treats symbolic links.
+type SymlinkPolicy int
+
+const (
+	// Report reports symbolic links as entries in their own right,
+	// without following them.
+	Report SymlinkPolicy = iota
+	// Follow reports symbolic links as the files or directories they
+	// refer to, and descends into directories, unless the directory
+	// is already being walked on the way down to the link, which
+	// would lead to a cycle.
+	Follow
+	// Ignore omits symbolic links from the walk altogether.
+	Ignore
+)
+
+// A Walker holds the configuration of a walk.
+// Its zero value is ready to use.
+type Walker struct {
+	// Parallelism is the maximum number of directories that are
+	// read at once.  Zero means 20.
+	Parallelism int
+
+	// Symlinks determines the treatment of symbolic links.
+	Symlinks SymlinkPolicy
+
+	// Skip, if non-nil, is called for each entry before it is
+	// reported.  If it returns true the entry is not reported and,
+	// if it is a directory, not descended into.  It is called
+	// concurrently from many goroutines.
+	Skip func(e Entry) bool
+
+	// OnError, if non-nil, is called with the path and error of each
+	// directory that cannot be read in full, or symbolic link that
+	// cannot be followed.  The walk continues.  If nil, such errors
+	// are discarded.  It is called concurrently from many goroutines.
+	OnError func(path string, err error)
+}
+
+// Walk walks the file trees rooted at each of roots, in parallel,
+// and sends each entry beneath them on the returned channel, in no
+// particular order.  The roots themselves are not reported.
+//
+// The channel is closed when the walk is complete or ctx is
+// cancelled.  The caller must either receive until it is closed or
+// cancel ctx, else the walk's goroutines leak.
+func (w *Walker) Walk(ctx context.Context, roots ...string) <-chan Entry {
+	n := w.Parallelism
+	if n <= 0 {
+		n = 20
+	}
+	wk := &walk{
+		Walker: w,
+		ctx:    ctx,
+		sema:   make(chan struct{}, n),
+		out:    make(chan Entry),
+	}
+	for _, root := range roots {
+		root = filepath.Clean(root)
+		var path []os.FileInfo
+		if w.Symlinks == Follow {
+			if info, err := os.Stat(root); err == nil {
+				path = []os.FileInfo{info}
+			}
+		}
+		wk.wg.Add(1)
+		go wk.dir(root, path)
+	}
+	go func() {
+		wk.wg.Wait()
+		close(wk.out)
+	}()
+	return wk.out
+}
+
+// A walk is the state of a single call to Walk.
+type walk struct {
+	*Walker
+	ctx  context.Context
+	sema chan struct{} // concurrency-limiting counting semaphore
+	out  chan Entry
+	wg   sync.WaitGroup // counts active calls to dir
+}
+
+// dir reports the entries of directory dir and descends into its
+// subdirectories, each in a new goroutine.  Under the Follow policy,
+// path holds the directories from the root down to dir, by which
+// follow detects cycles.
+func (wk *walk) dir(dir string, path []os.FileInfo) {
+	defer wk.wg.Done()
+	for _, de := range wk.dirents(dir) {
+		e := Entry{filepath.Join(dir, de.Name()), de}
+		isDir := de.IsDir()
+		if de.Type()&fs.ModeSymlink != 0 {
+			switch wk.Symlinks {
+			case Ignore:
+				continue
+			case Follow:
+				var ok bool
+				if e, ok = wk.follow(e, path); !ok {
+					continue
+				}
+				isDir = e.IsDir()
+			}
+		}
+		if wk.Skip != nil && wk.Skip(e) {
+			continue
+		}
+		select {
+		case wk.out <- e:
+		case <-wk.ctx.Done():
+			return
+		}
+		if isDir {
+			var sub []os.FileInfo
+			if wk.Symlinks == Follow {
+				info, err := e.Info() // of the target, if e was a link
+				if err != nil {
+					wk.error(e.Path, err)
+					continue
+				}
+				sub = append(path[:len(path):len(path)], info)
+			}
+			wk.wg.Add(1)
+			go wk.dir(e.Path, sub)
+		}
+	}
+}
+
+// follow returns the entry for the target of the symbolic link e.
+// It reports false if the link is broken or leads to one of the
+// directories of path, which would make the walk cycle.  Directories
+// are compared by identity, not name, so a cycle through several
+// links, such as a/l -> ../b and b/l -> ../a, is found too.
+func (wk *walk) follow(e Entry, path []os.FileInfo) (Entry, bool) {
+	info, err := os.Stat(e.Path)
+	if err != nil {
+		wk.error(e.Path, err)
+		return e, false
+	}
+	if info.IsDir() {
+		for _, d := range path {
+			if os.SameFile(d, info) {
+				wk.error(e.Path, fmt.Errorf("symbolic link cycle"))
+				return e, false
+			}
+		}
+	}
+	return Entry{e.Path, fs.FileInfoToDirEntry(info)}, true
+}
+
+// dirents returns the entries of directory dir, or nil if the walk
+// is cancelled.
+func (wk *walk) dirents(dir string) []fs.DirEntry {
+	select {
+	case wk.sema <- struct{}{}: // acquire token
+	case <-wk.ctx.Done():
+		return nil // cancelled
+	}
+	defer func() { <-wk.sema }() // release token
+
+	f, err := os.Open(dir)
+	if err != nil {
+		wk.error(dir, err)
+		return nil
+	}
+	defer f.Close()
+
+	entries, err := f.ReadDir(-1) // -1 => no limit; read all entries
+	if err != nil {
+		wk.error(dir, err)
+		// Don't return: ReadDir may return partial results.
+	}
+	return entries
+}
+
+func (wk *walk) error(path string, err error) {
+	if wk.OnError != nil {
+		wk.OnError(path, err)
+	}
+}
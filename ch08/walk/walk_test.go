@@ -0,0 +1,170 @@
+package walk
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// mktree creates a tree of empty files and directories (those ending
+// in "/") under a new temporary directory, which it returns.
+func mktree(t *testing.T, paths ...string) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, p := range paths {
+		isDir := strings.HasSuffix(p, "/")
+		p = filepath.Join(root, filepath.FromSlash(p))
+		if isDir {
+			if err := os.MkdirAll(p, 0777); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// collect returns the paths reported by the walk, relative to root,
+// with a trailing "/" for directories.
+func collect(w *Walker, ctx context.Context, root string) []string {
+	var paths []string
+	for e := range w.Walk(ctx, root) {
+		p, _ := filepath.Rel(root, e.Path)
+		p = filepath.ToSlash(p)
+		if e.IsDir() {
+			p += "/"
+		}
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestWalk(t *testing.T) {
+	root := mktree(t, "a/1", "a/b/2", "a/b/c/", "d/3.tmp", "4")
+	w := &Walker{Parallelism: 2}
+	got := strings.Join(collect(w, context.Background(), root), " ")
+	if want := "4 a/ a/1 a/b/ a/b/2 a/b/c/ d/ d/3.tmp"; got != want {
+		t.Errorf("walk = %s, want %s", got, want)
+	}
+
+	w.Skip = func(e Entry) bool {
+		return e.Name() == "b" || strings.HasSuffix(e.Name(), ".tmp")
+	}
+	got = strings.Join(collect(w, context.Background(), root), " ")
+	if want := "4 a/ a/1 d/"; got != want {
+		t.Errorf("walk with Skip = %s, want %s", got, want)
+	}
+}
+
+func TestSymlinks(t *testing.T) {
+	root := mktree(t, "a/1", "b/2")
+	for old, new := range map[string]string{
+		"../b": "a/tob",    // to a sibling directory
+		"..":   "a/up",     // to an ancestor: a cycle
+		"1":    "a/to1",    // to a file
+		"nope": "a/broken", // to nothing
+	} {
+		if err := os.Symlink(old, filepath.Join(root, filepath.FromSlash(new))); err != nil {
+			t.Skipf("cannot create symbolic links: %v", err)
+		}
+	}
+
+	var mu sync.Mutex
+	var errs []string
+	w := &Walker{OnError: func(path string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		p, _ := filepath.Rel(root, path)
+		errs = append(errs, filepath.ToSlash(p))
+	}}
+	for _, test := range []struct {
+		policy SymlinkPolicy
+		want   string
+		errs   string
+	}{
+		{Report, "a/ a/1 a/broken a/to1 a/tob a/up b/ b/2", ""},
+		{Ignore, "a/ a/1 b/ b/2", ""},
+		{Follow, "a/ a/1 a/to1 a/tob/ a/tob/2 b/ b/2", "a/broken a/up"},
+	} {
+		errs = nil
+		w.Symlinks = test.policy
+		got := strings.Join(collect(w, context.Background(), root), " ")
+		if got != test.want {
+			t.Errorf("policy %d: walk = %s, want %s", test.policy, got, test.want)
+		}
+		sort.Strings(errs)
+		if got := strings.Join(errs, " "); got != test.errs {
+			t.Errorf("policy %d: errors for %s, want %s", test.policy, got, test.errs)
+		}
+	}
+}
+
+func TestSiblingLinks(t *testing.T) {
+	// Each of a and b links to the other, so following the links
+	// leads back to a directory already on the way down.
+	root := mktree(t, "a/1", "b/2")
+	for old, new := range map[string]string{"../b": "a/l", "../a": "b/l"} {
+		if err := os.Symlink(old, filepath.Join(root, filepath.FromSlash(new))); err != nil {
+			t.Skipf("cannot create symbolic links: %v", err)
+		}
+	}
+	var mu sync.Mutex
+	var errs []string
+	w := &Walker{Symlinks: Follow, OnError: func(path string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		p, _ := filepath.Rel(root, path)
+		errs = append(errs, filepath.ToSlash(p))
+	}}
+	got := strings.Join(collect(w, context.Background(), root), " ")
+	if want := "a/ a/1 a/l/ a/l/2 b/ b/2 b/l/ b/l/1"; got != want {
+		t.Errorf("walk = %s, want %s", got, want)
+	}
+	sort.Strings(errs)
+	if got, want := strings.Join(errs, " "), "a/l/l b/l/l"; got != want {
+		t.Errorf("errors for %s, want %s", got, want)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var got []string
+	w := &Walker{OnError: func(path string, err error) { got = append(got, path) }}
+	for range w.Walk(context.Background(), "no/such/dir") {
+		t.Error("walk of nonexistent root reported an entry")
+	}
+	if len(got) != 1 || got[0] != "no/such/dir" {
+		t.Errorf("errors reported for %v, want [no/such/dir]", got)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	var paths []string
+	for i := 0; i < 100; i++ {
+		paths = append(paths, filepath.Join("d", string(rune('a'+i%26)), strings.Repeat("f", i%7+1)))
+	}
+	root := mktree(t, paths...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := (&Walker{}).Walk(ctx, root)
+	<-ch
+	cancel()
+	n := 0
+	for range ch {
+		n++
+	}
+	// Entries already being sent may still arrive, but not all of them.
+	if n >= len(paths) {
+		t.Errorf("received %d entries after cancellation", n)
+	}
+}
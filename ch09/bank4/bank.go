@@ -0,0 +1,293 @@
+// Package bank provides a concurrency-safe bank with many named
+// accounts.
+//
+// Like bank1, it confines its state to a teller goroutine, which
+// performs each operation in turn.  So every operation, including a
+// transfer between two accounts, is atomic, and since there are no
+// locks there can be no deadlock.
+//
+// A bank made by OpenDir is also persistent: its teller writes each
+// transaction to a log before acknowledging it, and recovers its state
+// from the log after a crash.
+package bank
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrName         = errors.New("invalid account name")
+	ErrExists       = errors.New("account already exists")
+	ErrNoAccount    = errors.New("no such account")
+	ErrAmount       = errors.New("amount must be positive")
+	ErrInsufficient = errors.New("insufficient funds")
+)
+
+// An Op is the kind of a transaction.
+type Op string
+
+const (
+	OpOpen     Op = "open"     // open account To
+	OpDeposit  Op = "deposit"  // deposit Amount into To
+	OpWithdraw Op = "withdraw" // withdraw Amount from From
+	OpTransfer Op = "transfer" // move Amount from From to To
+)
+
+// A Transaction is a change to the accounts of a bank.
+type Transaction struct {
+	Seq    int // position in the history, from 1
+	Time   time.Time
+	Op     Op
+	From   string `json:",omitempty"`
+	To     string `json:",omitempty"`
+	Amount int    `json:",omitempty"`
+}
+
+func (tx Transaction) String() string {
+	switch tx.Op {
+	case OpOpen:
+		return fmt.Sprintf("#%d open %s", tx.Seq, tx.To)
+	case OpDeposit:
+		return fmt.Sprintf("#%d deposit %d into %s", tx.Seq, tx.Amount, tx.To)
+	case OpWithdraw:
+		return fmt.Sprintf("#%d withdraw %d from %s", tx.Seq, tx.Amount, tx.From)
+	default:
+		return fmt.Sprintf("#%d %s %d from %s to %s", tx.Seq, tx.Op, tx.Amount, tx.From, tx.To)
+	}
+}
+
+// A Snapshot is the state of every account at one point in the
+// history.
+type Snapshot struct {
+	Seq      int // the last transaction included
+	Balances map[string]int
+}
+
+// A Bank is a set of accounts.  Clients must call Close when done.
+type Bank struct {
+	ops  chan func(*ledger)
+	done chan struct{}
+}
+
+// New returns a bank with no accounts.
+func New() *Bank {
+	b := &Bank{
+		ops:  make(chan func(*ledger)),
+		done: make(chan struct{}),
+	}
+	go b.teller(newLedger())
+	return b
+}
+
+func (b *Bank) teller(l *ledger) {
+	for {
+		select {
+		case op := <-b.ops:
+			op(l)
+		case <-b.done:
+			return
+		}
+	}
+}
+
+// Close stops the bank's teller, and closes its log, if it has one.
+// The bank must not be used afterward.
+func (b *Bank) Close() error {
+	var err error
+	b.exec(func(l *ledger) {
+		if l.wal != nil {
+			err = l.wal.close()
+		}
+	})
+	close(b.done)
+	return err
+}
+
+// exec calls f with the ledger, in the teller goroutine.
+func (b *Bank) exec(f func(l *ledger)) {
+	done := make(chan struct{})
+	b.ops <- func(l *ledger) {
+		f(l)
+		close(done)
+	}
+	<-done
+}
+
+// Apply performs the operation described by tx, ignoring its Seq and
+// Time, and returns the transaction as recorded in the history.
+func (b *Bank) Apply(tx Transaction) (Transaction, error) {
+	var err error
+	b.exec(func(l *ledger) { tx, err = l.apply(tx) })
+	return tx, err
+}
+
+func (b *Bank) do(tx Transaction) error {
+	_, err := b.Apply(tx)
+	return err
+}
+
+// Open opens an account with a zero balance.
+func (b *Bank) Open(account string) error {
+	return b.do(Transaction{Op: OpOpen, To: account})
+}
+
+func (b *Bank) Deposit(account string, amount int) error {
+	return b.do(Transaction{Op: OpDeposit, To: account, Amount: amount})
+}
+
+// Withdraw removes amount from account, or fails with ErrInsufficient,
+// changing nothing, if the balance is less than amount.
+func (b *Bank) Withdraw(account string, amount int) error {
+	return b.do(Transaction{Op: OpWithdraw, From: account, Amount: amount})
+}
+
+// Transfer moves amount from one account to another, or fails with
+// ErrInsufficient, changing nothing, if the balance of from is less
+// than amount.
+func (b *Bank) Transfer(from, to string, amount int) error {
+	return b.do(Transaction{Op: OpTransfer, From: from, To: to, Amount: amount})
+}
+
+func (b *Bank) Balance(account string) (balance int, err error) {
+	b.exec(func(l *ledger) {
+		var ok bool
+		if balance, ok = l.balances[account]; !ok {
+			err = fmt.Errorf("%s: %w", account, ErrNoAccount)
+		}
+	})
+	return balance, err
+}
+
+// History returns the most recent transactions, oldest first: every
+// transaction so far, up to the bank's limit (see Options.MaxHistory).
+func (b *Bank) History() []Transaction {
+	var history []Transaction
+	b.exec(func(l *ledger) {
+		h := l.history
+		if len(h) > l.maxHistory {
+			h = h[len(h)-l.maxHistory:]
+		}
+		history = append(history, h...)
+	})
+	return history
+}
+
+// Snapshot returns the balances of all accounts at a single point in
+// the history.
+func (b *Bank) Snapshot() Snapshot {
+	var s Snapshot
+	b.exec(func(l *ledger) { s = l.snapshot() })
+	return s
+}
+
+// A ledger is the state of a bank, confined to its teller goroutine.
+type ledger struct {
+	balances   map[string]int
+	history    []Transaction // the last maxHistory, or up to twice as many
+	maxHistory int
+	seq        int  // of the last transaction applied
+	wal        *wal // if the bank is persistent
+}
+
+// defaultMaxHistory is the number of transactions kept in the history
+// of a bank, unless Options.MaxHistory says otherwise.
+const defaultMaxHistory = 100000
+
+func newLedger() *ledger {
+	return &ledger{balances: make(map[string]int), maxHistory: defaultMaxHistory}
+}
+
+// apply checks that tx is valid, and if so, numbers it, writes it
+// to the log, if any, and commits it.  It changes nothing if tx is
+// invalid or cannot be logged.
+func (l *ledger) apply(tx Transaction) (Transaction, error) {
+	if err := l.check(tx); err != nil {
+		return tx, err
+	}
+	tx.Seq = l.seq + 1
+	tx.Time = time.Now()
+	if l.wal != nil {
+		if err := l.wal.append(tx); err != nil {
+			return tx, err
+		}
+	}
+	l.commit(tx)
+	if l.wal != nil {
+		l.wal.compact(l)
+	}
+	return tx, nil
+}
+
+// commit applies the valid transaction tx to the balances and
+// records it in the history.
+func (l *ledger) commit(tx Transaction) {
+	switch tx.Op {
+	case OpOpen:
+		l.balances[tx.To] = 0
+	case OpDeposit:
+		l.balances[tx.To] += tx.Amount
+	case OpWithdraw:
+		l.balances[tx.From] -= tx.Amount
+	case OpTransfer:
+		l.balances[tx.From] -= tx.Amount
+		l.balances[tx.To] += tx.Amount
+	}
+	l.seq = tx.Seq
+	l.history = append(l.history, tx)
+	if len(l.history) >= 2*l.maxHistory {
+		// Drop the older half, copying so that its memory is freed.
+		// Trimming only at twice the limit makes the copy cheap
+		// in amortization.
+		l.history = append([]Transaction(nil), l.history[len(l.history)-l.maxHistory:]...)
+	}
+}
+
+// check reports whether tx could be applied to the ledger.
+func (l *ledger) check(tx Transaction) error {
+	exists := func(account string) error {
+		if _, ok := l.balances[account]; !ok {
+			return fmt.Errorf("%s: %w", account, ErrNoAccount)
+		}
+		return nil
+	}
+	switch tx.Op {
+	case OpOpen:
+		if tx.To == "" {
+			return ErrName
+		}
+		if _, ok := l.balances[tx.To]; ok {
+			return fmt.Errorf("%s: %w", tx.To, ErrExists)
+		}
+		return nil
+	case OpDeposit, OpWithdraw, OpTransfer:
+	default:
+		return fmt.Errorf("unknown operation %q", tx.Op)
+	}
+	if tx.Amount <= 0 {
+		return ErrAmount
+	}
+	if tx.Op != OpWithdraw {
+		if err := exists(tx.To); err != nil {
+			return err
+		}
+	}
+	if tx.Op != OpDeposit {
+		if err := exists(tx.From); err != nil {
+			return err
+		}
+		if l.balances[tx.From] < tx.Amount {
+			return fmt.Errorf("%s: %w", tx.From, ErrInsufficient)
+		}
+	}
+	return nil
+}
+
+func (l *ledger) snapshot() Snapshot {
+	s := Snapshot{Seq: l.seq, Balances: make(map[string]int, len(l.balances))}
+	for account, balance := range l.balances {
+		s.Balances[account] = balance
+	}
+	return s
+}
@@ -0,0 +1,135 @@
+package bank_test
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"gopl.io/ch09/bank4"
+)
+
+func TestBank(t *testing.T) {
+	b := bank.New()
+	defer b.Close()
+
+	for _, account := range []string{"alice", "bob"} {
+		if err := b.Open(account); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, test := range []struct {
+		op   func() error
+		want error
+	}{
+		{func() error { return b.Open("alice") }, bank.ErrExists},
+		{func() error { return b.Deposit("alice", 100) }, nil},
+		{func() error { return b.Deposit("carol", 100) }, bank.ErrNoAccount},
+		{func() error { return b.Deposit("alice", 0) }, bank.ErrAmount},
+		{func() error { return b.Withdraw("alice", 30) }, nil},
+		{func() error { return b.Withdraw("alice", 71) }, bank.ErrInsufficient},
+		{func() error { return b.Transfer("alice", "bob", 50) }, nil},
+		{func() error { return b.Transfer("alice", "bob", 21) }, bank.ErrInsufficient},
+		{func() error { return b.Transfer("alice", "carol", 1) }, bank.ErrNoAccount},
+		{func() error { return b.Transfer("alice", "bob", -5) }, bank.ErrAmount},
+	} {
+		if err := test.op(); !errors.Is(err, test.want) {
+			t.Errorf("got error %v, want %v", err, test.want)
+		}
+	}
+
+	// Failed operations changed nothing.
+	for account, want := range map[string]int{"alice": 20, "bob": 50} {
+		if got, err := b.Balance(account); got != want || err != nil {
+			t.Errorf("Balance(%s) = %d, %v, want %d", account, got, err, want)
+		}
+	}
+	if _, err := b.Balance("carol"); !errors.Is(err, bank.ErrNoAccount) {
+		t.Errorf("Balance(carol) returned error %v, want %v", err, bank.ErrNoAccount)
+	}
+
+	var got []string
+	for _, tx := range b.History() {
+		got = append(got, tx.String())
+	}
+	want := []string{
+		"#1 open alice",
+		"#2 open bob",
+		"#3 deposit 100 into alice",
+		"#4 withdraw 30 from alice",
+		"#5 transfer 50 from alice to bob",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("History() = %q, want %q", got, want)
+	}
+}
+
+// TestStress moves money at random among accounts from many
+// goroutines at once, while others take snapshots, and checks that
+// money is neither created nor destroyed.  Run it with -race.
+func TestStress(t *testing.T) {
+	b := bank.New()
+	defer b.Close()
+
+	const accounts, initial = 10, 1000
+	for i := 0; i < accounts; i++ {
+		name := fmt.Sprint("acct", i)
+		b.Open(name)
+		b.Deposit(name, initial)
+	}
+	total := func(s bank.Snapshot) int {
+		sum := 0
+		for _, balance := range s.Balances {
+			if balance < 0 {
+				t.Errorf("snapshot #%d: negative balance", s.Seq)
+			}
+			sum += balance
+		}
+		return sum
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 20; g++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+			for i := 0; i < 500; i++ {
+				from := fmt.Sprint("acct", rng.Intn(accounts))
+				to := fmt.Sprint("acct", rng.Intn(accounts))
+				err := b.Transfer(from, to, 1+rng.Intn(initial))
+				if err != nil && !errors.Is(err, bank.ErrInsufficient) {
+					t.Error(err)
+				}
+			}
+		}(int64(g))
+	}
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				if s := b.Snapshot(); total(s) != accounts*initial {
+					t.Errorf("snapshot #%d: total = %d, want %d", s.Seq, total(s), accounts*initial)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Replaying the history yields the final balances.
+	replay := make(map[string]int)
+	for _, tx := range b.History() {
+		switch tx.Op {
+		case bank.OpDeposit:
+			replay[tx.To] += tx.Amount
+		case bank.OpTransfer:
+			replay[tx.From] -= tx.Amount
+			replay[tx.To] += tx.Amount
+		}
+	}
+	if s := b.Snapshot(); fmt.Sprint(s.Balances) != fmt.Sprint(replay) {
+		t.Errorf("final balances %v, replayed history gives %v", s.Balances, replay)
+	}
+}
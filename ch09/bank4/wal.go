@@ -0,0 +1,269 @@
+package bank
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// The files of a persistent bank's directory.
+const (
+	logFile      = "wal.log"       // transactions since the snapshot
+	snapshotFile = "snapshot.json" // balances as of some transaction
+)
+
+// Options configure a persistent bank.
+type Options struct {
+	// SnapshotEvery is the number of transactions between
+	// snapshots, which allow the log to be truncated.
+	// Zero means 1000.
+	SnapshotEvery int
+
+	// MaxHistory is the number of recent transactions that History
+	// returns.  Zero means 100000.
+	MaxHistory int
+}
+
+// OpenDir returns a bank whose state is kept in directory dir,
+// creating it if necessary.  The bank's state is recovered from the
+// most recent snapshot in dir, followed by the transactions in the
+// log since then, so its History begins after that snapshot.
+//
+// Each transaction is written to the log, and the log flushed to
+// stable storage, before the operation returns.  If the log cannot
+// be written, the operation fails, and the partial record is removed.
+// If even that fails, so do all operations after it.
+func OpenDir(dir string, opts Options) (*Bank, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	l := newLedger()
+	if opts.MaxHistory > 0 {
+		l.maxHistory = opts.MaxHistory
+	}
+	if err := l.loadSnapshot(filepath.Join(dir, snapshotFile)); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, logFile), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	w := &wal{dir: dir, f: f, every: opts.SnapshotEvery, snapSeq: l.seq}
+	if w.every <= 0 {
+		w.every = 1000
+	}
+	if err := l.replay(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("recovering %s: %v", dir, err)
+	}
+	l.wal = w
+
+	b := &Bank{
+		ops:  make(chan func(*ledger)),
+		done: make(chan struct{}),
+	}
+	go b.teller(l)
+	return b, nil
+}
+
+// A wal is the write-ahead log of a persistent bank.
+type wal struct {
+	dir     string
+	f       *os.File // positioned at the end of the last good record
+	every   int      // snapshot interval
+	snapSeq int      // seq of the last snapshot
+	err     error    // sticky write error
+}
+
+// encode returns the log record for tx: a line holding the
+// checksum of the transaction's JSON encoding, then the encoding.
+func encode(tx Transaction) []byte {
+	data, _ := json.Marshal(tx) // can't fail
+	return []byte(fmt.Sprintf("%08x %s\n", crc32.ChecksumIEEE(data), data))
+}
+
+// decode parses a log record, less its newline.
+func decode(line []byte) (Transaction, error) {
+	var tx Transaction
+	var sum uint32
+	if len(line) < 10 || line[8] != ' ' {
+		return tx, fmt.Errorf("malformed record")
+	}
+	if _, err := fmt.Sscanf(string(line[:8]), "%08x", &sum); err != nil {
+		return tx, fmt.Errorf("malformed record")
+	}
+	if crc32.ChecksumIEEE(line[9:]) != sum {
+		return tx, fmt.Errorf("bad checksum")
+	}
+	if err := json.Unmarshal(line[9:], &tx); err != nil {
+		return tx, err
+	}
+	return tx, nil
+}
+
+// append writes tx to the log and flushes it to stable storage.
+// If it cannot, it truncates the log to remove any part of the record
+// that was written, so that later records do not follow a torn one,
+// which replay would take for corruption.  If it cannot do that
+// either, the log is unusable.
+func (w *wal) append(tx Transaction) error {
+	if w.err != nil {
+		return w.err
+	}
+	off, err := w.f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		w.err = err
+		return err
+	}
+	_, err = w.f.Write(encode(tx))
+	if err == nil {
+		err = w.f.Sync()
+	}
+	if err != nil {
+		if terr := w.truncate(off); terr != nil {
+			w.err = fmt.Errorf("%v; removing the partial record: %v", err, terr)
+			return w.err
+		}
+		return err
+	}
+	return nil
+}
+
+// truncate truncates the log to off bytes, and positions it there.
+func (w *wal) truncate(off int64) error {
+	if err := w.f.Truncate(off); err != nil {
+		return err
+	}
+	if _, err := w.f.Seek(off, io.SeekStart); err != nil {
+		return err
+	}
+	return w.f.Sync()
+}
+
+// compact writes a snapshot of l, if one is due, and truncates the
+// log.  A crash between the two leaves records in the log that the
+// snapshot already includes, which replay skips.
+func (w *wal) compact(l *ledger) {
+	if w.err != nil || l.seq-w.snapSeq < w.every {
+		return
+	}
+	s := l.snapshot()
+	data, err := json.Marshal(s)
+	if err == nil {
+		err = writeFile(filepath.Join(w.dir, snapshotFile), data)
+	}
+	if err == nil {
+		err = w.truncate(0)
+	}
+	if err != nil {
+		w.err = err
+		return
+	}
+	w.snapSeq = s.Seq
+}
+
+func (w *wal) close() error {
+	err := w.f.Close()
+	if w.err != nil {
+		return w.err
+	}
+	return err
+}
+
+// writeFile writes data to the named file atomically, by writing a
+// temporary file and renaming it, and flushes both to stable storage.
+func writeFile(name string, data []byte) error {
+	tmp := name + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, name)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	// Flush the directory, so that the rename is durable.
+	d, err := os.Open(filepath.Dir(name))
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// loadSnapshot sets l to the snapshot in the named file, if it exists.
+func (l *ledger) loadSnapshot(name string) error {
+	data, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	l.seq = s.Seq
+	for account, balance := range s.Balances {
+		l.balances[account] = balance
+	}
+	return nil
+}
+
+// replay applies the transactions in the log f that follow l.seq.
+//
+// Since a transaction is acknowledged only once its record is
+// flushed, a crash can damage only the last record, so replay
+// discards a malformed last record, truncating the log before it.
+// A malformed record anywhere else means the log is corrupt.
+func (l *ledger) replay(f *os.File) error {
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	var good int64 // length of the good records
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		var tx Transaction
+		if i >= 0 {
+			tx, err = decode(data[:i])
+		}
+		if i < 0 || err != nil {
+			if i >= 0 && i+1 < len(data) {
+				return fmt.Errorf("%s: offset %d: %v", logFile, good, err)
+			}
+			break // torn write at the end of the log
+		}
+		if tx.Seq > l.seq { // not already in the snapshot
+			if tx.Seq != l.seq+1 {
+				return fmt.Errorf("%s: offset %d: transaction #%d follows #%d", logFile, good, tx.Seq, l.seq)
+			}
+			if err := l.check(tx); err != nil {
+				return fmt.Errorf("%s: offset %d: %v", logFile, good, err)
+			}
+			l.commit(tx)
+		}
+		good += int64(i + 1)
+		data = data[i+1:]
+	}
+	if err := f.Truncate(good); err != nil {
+		return err
+	}
+	_, err = f.Seek(good, 0)
+	return err
+}
@@ -0,0 +1,62 @@
+package bank
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// TestTornAppend makes a write to the log fail partway through, by
+// limiting the size of files, and checks that the partial record is
+// removed, so that the bank goes on working and can be reopened.
+func TestTornAppend(t *testing.T) {
+	dir := t.TempDir()
+	b, err := OpenDir(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := run(b)
+	before, _ := b.Balance("alice")
+	info, err := os.Stat(filepath.Join(dir, logFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var old syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_FSIZE, &old); err != nil {
+		t.Skip(err)
+	}
+	signal.Ignore(syscall.SIGXFSZ)
+	defer signal.Reset(syscall.SIGXFSZ)
+	lim := old
+	lim.Cur = uint64(info.Size()) + 10 // room for part of a record
+	if err := syscall.Setrlimit(syscall.RLIMIT_FSIZE, &lim); err != nil {
+		t.Skip(err)
+	}
+	err = b.Deposit("alice", 1)
+	syscall.Setrlimit(syscall.RLIMIT_FSIZE, &old)
+	if err == nil {
+		t.Fatal("Deposit succeeded beyond the file size limit")
+	}
+	if got := fmt.Sprint(b.Snapshot().Balances); got != states[len(states)-1] {
+		t.Errorf("after failed Deposit, balances are %s, want %s", got, states[len(states)-1])
+	}
+	if err := b.Deposit("alice", 2); err != nil {
+		t.Fatalf("Deposit after a failed one: %v", err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err = OpenDir(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if balance, _ := b.Balance("alice"); balance != before+2 {
+		t.Errorf("after reopening, alice has %d, want %d", balance, before+2)
+	}
+}
@@ -0,0 +1,186 @@
+package bank
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// run performs a fixed series of operations on b, some of which
+// fail, and returns the balances after each transaction, starting
+// with none.
+func run(b *Bank) []string {
+	states := []string{fmt.Sprint(b.Snapshot().Balances)}
+	ops := []func() error{
+		func() error { return b.Open("alice") },
+		func() error { return b.Open("bob") },
+		func() error { return b.Deposit("alice", 100) },
+		func() error { return b.Withdraw("bob", 1) }, // fails
+		func() error { return b.Transfer("alice", "bob", 40) },
+		func() error { return b.Open("carol") },
+		func() error { return b.Transfer("bob", "carol", 15) },
+		func() error { return b.Withdraw("alice", 60) },
+		func() error { return b.Deposit("bob", 7) },
+		func() error { return b.Transfer("carol", "alice", 5) },
+	}
+	for _, op := range ops {
+		if op() == nil {
+			states = append(states, fmt.Sprint(b.Snapshot().Balances))
+		}
+	}
+	return states
+}
+
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	b, err := OpenDir(dir, Options{SnapshotEvery: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := run(b)
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The log holds only the transactions since the last snapshot.
+	data, _ := ioutil.ReadFile(filepath.Join(dir, logFile))
+	if n := bytes.Count(data, []byte("\n")); n >= 4 {
+		t.Errorf("log has %d records after compaction", n)
+	}
+
+	b, err = OpenDir(dir, Options{SnapshotEvery: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if got, want := fmt.Sprint(b.Snapshot().Balances), states[len(states)-1]; got != want {
+		t.Errorf("after reopening, balances are %s, want %s", got, want)
+	}
+	if err := b.Deposit("carol", 1); err != nil {
+		t.Errorf("Deposit after reopening: %v", err)
+	}
+}
+
+// TestCrash simulates a crash at every offset within the log, and
+// checks that recovery yields the state after the last complete record.
+func TestCrash(t *testing.T) {
+	dir := t.TempDir()
+	b, err := OpenDir(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := run(b)
+	b.Close()
+	log, err := ioutil.ReadFile(filepath.Join(dir, logFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for n := 0; n <= len(log); n++ {
+		dir := t.TempDir()
+		if err := ioutil.WriteFile(filepath.Join(dir, logFile), log[:n], 0644); err != nil {
+			t.Fatal(err)
+		}
+		b, err := OpenDir(dir, Options{})
+		if err != nil {
+			t.Errorf("crash at offset %d: %v", n, err)
+			continue
+		}
+		k := bytes.Count(log[:n], []byte("\n"))
+		if got := fmt.Sprint(b.Snapshot().Balances); got != states[k] {
+			t.Errorf("crash at offset %d: balances %s, want %s", n, got, states[k])
+		}
+		// The torn record was discarded, so the log may be extended.
+		if k > 0 {
+			if err := b.Open("dave"); err != nil {
+				t.Errorf("crash at offset %d: Open after recovery: %v", n, err)
+			}
+		}
+		b.Close()
+		if k > 0 {
+			b, err := OpenDir(dir, Options{})
+			if err != nil {
+				t.Errorf("crash at offset %d: reopening after recovery: %v", n, err)
+				continue
+			}
+			if _, err := b.Balance("dave"); err != nil {
+				t.Errorf("crash at offset %d: after recovery and reopening: %v", n, err)
+			}
+			b.Close()
+		}
+	}
+}
+
+// TestCrashDuringCompaction checks recovery from a crash after a
+// snapshot was written but before the log was truncated.
+func TestCrashDuringCompaction(t *testing.T) {
+	dir := t.TempDir()
+	b, err := OpenDir(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := run(b)
+	b.Close()
+
+	// Write a snapshot as of transaction 4, leaving the whole log.
+	s := Snapshot{Seq: 4}
+	json.Unmarshal([]byte(`{"alice":60,"bob":40}`), &s.Balances)
+	data, _ := json.Marshal(s)
+	if err := writeFile(filepath.Join(dir, snapshotFile), data); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err = OpenDir(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if got, want := fmt.Sprint(b.Snapshot().Balances), states[len(states)-1]; got != want {
+		t.Errorf("balances %s, want %s", got, want)
+	}
+	if n := len(b.History()); n != len(states)-1-4 {
+		t.Errorf("recovered %d transactions, want %d", n, len(states)-1-4)
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	b, err := OpenDir(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	run(b)
+	b.Close()
+
+	// Damage a record that is not the last.
+	name := filepath.Join(dir, logFile)
+	data, _ := ioutil.ReadFile(name)
+	i := bytes.Index(data, []byte("bob"))
+	data[i] = 'B'
+	ioutil.WriteFile(name, data, 0644)
+
+	if b, err := OpenDir(dir, Options{}); err == nil {
+		b.Close()
+		t.Error("OpenDir succeeded with a corrupt log")
+	}
+}
+
+func TestMaxHistory(t *testing.T) {
+	b, err := OpenDir(t.TempDir(), Options{MaxHistory: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	states := run(b)
+	var seqs []int
+	for _, tx := range b.History() {
+		seqs = append(seqs, tx.Seq)
+	}
+	n := len(states) - 1
+	if got, want := fmt.Sprint(seqs), fmt.Sprint([]int{n - 2, n - 1, n}); got != want {
+		t.Errorf("History() holds transactions %s, want %s", got, want)
+	}
+}
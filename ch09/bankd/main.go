@@ -0,0 +1,44 @@
+// Bankd serves a bank over HTTP, exchanging JSON.
+//
+//	POST /accounts  {"account": "alice"}
+//	POST /deposit   {"account": "alice", "amount": 100}
+//	POST /withdraw  {"account": "alice", "amount": 30}
+//	POST /transfer  {"from": "alice", "to": "bob", "amount": 50}
+//	GET  /balance/alice
+//
+// Each POST returns the transaction it recorded.  A POST with an
+// Idempotency-Key header is performed at most once: a retry with the
+// same key receives the original response.  The keys are remembered
+// only in memory, even with -dir, so a retry that reaches the server
+// after it has restarted is performed again.
+//
+// With -dir, the bank is persistent (see gopl.io/ch09/bank4.OpenDir).
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"gopl.io/ch09/bank4"
+)
+
+var (
+	addr = flag.String("addr", "localhost:8000", "listen on `address`")
+	dir  = flag.String("dir", "", "keep the bank's state in `directory`")
+)
+
+func main() {
+	flag.Parse()
+	var b *bank.Bank
+	if *dir == "" {
+		b = bank.New()
+	} else {
+		var err error
+		if b, err = bank.OpenDir(*dir, bank.Options{}); err != nil {
+			log.Fatal(err)
+		}
+	}
+	s := newServer(b)
+	log.Fatal(http.ListenAndServe(*addr, s.routes()))
+}
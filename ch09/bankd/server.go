@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"gopl.io/ch09/bank4"
+)
+
+// maxBody is the largest request body accepted.
+const maxBody = 1 << 16
+
+// maxReplies is the number of idempotency keys remembered.
+const maxReplies = 100000
+
+type server struct {
+	bank    *bank.Bank
+	replies replies
+}
+
+func newServer(b *bank.Bank) *server {
+	return &server{bank: b, replies: replies{max: maxReplies}}
+}
+
+func (s *server) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/accounts", s.post(s.open))
+	mux.HandleFunc("/deposit", s.post(s.deposit))
+	mux.HandleFunc("/withdraw", s.post(s.withdraw))
+	mux.HandleFunc("/transfer", s.post(s.transfer))
+	mux.HandleFunc("/balance/", s.balance)
+	return mux
+}
+
+// A request is the body of a POST request.
+type request struct {
+	Account string `json:"account"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Amount  int    `json:"amount"`
+}
+
+// An op performs a POST request and returns the status and the value
+// to send in reply.
+type op func(r *request) (status int, v interface{})
+
+func (s *server) open(r *request) (int, interface{}) {
+	return s.apply(http.StatusCreated, bank.Transaction{Op: bank.OpOpen, To: r.Account})
+}
+
+func (s *server) deposit(r *request) (int, interface{}) {
+	return s.apply(http.StatusOK, bank.Transaction{Op: bank.OpDeposit, To: r.Account, Amount: r.Amount})
+}
+
+func (s *server) withdraw(r *request) (int, interface{}) {
+	return s.apply(http.StatusOK, bank.Transaction{Op: bank.OpWithdraw, From: r.Account, Amount: r.Amount})
+}
+
+func (s *server) transfer(r *request) (int, interface{}) {
+	return s.apply(http.StatusOK, bank.Transaction{Op: bank.OpTransfer, From: r.From, To: r.To, Amount: r.Amount})
+}
+
+func (s *server) apply(ok int, tx bank.Transaction) (int, interface{}) {
+	tx, err := s.bank.Apply(tx)
+	if err != nil {
+		return errorReply(err)
+	}
+	return ok, tx
+}
+
+// A balanceReply is the reply to a GET /balance request.
+type balanceReply struct {
+	Account string
+	Balance int
+}
+
+func (s *server) balance(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeJSON(w, http.StatusMethodNotAllowed, errorBody{"method not allowed"})
+		return
+	}
+	account := strings.TrimPrefix(req.URL.Path, "/balance/")
+	balance, err := s.bank.Balance(account)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, errorBody{err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, balanceReply{account, balance})
+}
+
+// post returns a handler that decodes a POST request and performs f,
+// at most once for each Idempotency-Key.
+func (s *server) post(f op) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "POST" {
+			w.Header().Set("Allow", "POST")
+			writeJSON(w, http.StatusMethodNotAllowed, errorBody{"method not allowed"})
+			return
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(req.Body, maxBody))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorBody{err.Error()})
+			return
+		}
+		perform := func() (int, []byte) {
+			var r request
+			if err := json.Unmarshal(body, &r); err != nil {
+				return encode(http.StatusBadRequest, errorBody{"bad request: " + err.Error()})
+			}
+			return encode(f(&r))
+		}
+
+		key := req.Header.Get("Idempotency-Key")
+		if key == "" {
+			status, data := perform()
+			write(w, status, data)
+			return
+		}
+		rep, first := s.replies.get(key, req.URL.Path+"\n"+string(body))
+		if rep == nil {
+			writeJSON(w, http.StatusUnprocessableEntity,
+				errorBody{"Idempotency-Key was used for a different request"})
+			return
+		}
+		if first {
+			rep.status, rep.body = perform()
+			s.replies.done(key, rep)
+		} else {
+			<-rep.ready
+			w.Header().Set("Idempotent-Replayed", "true")
+		}
+		write(w, rep.status, rep.body)
+	}
+}
+
+// A reply is the response to a request with an idempotency key.
+type reply struct {
+	request string        // the path and body of the request
+	status  int           // set before ready is closed
+	body    []byte        // set before ready is closed
+	ready   chan struct{} // closed when the response is ready
+}
+
+// replies remembers the replies to the most recent requests with
+// idempotency keys.  Like gopl.io/ch09/memo4, it makes concurrent
+// duplicate requests wait for the first.
+type replies struct {
+	mu   sync.Mutex // guards m and keys
+	m    map[string]*reply
+	keys []string // in order of arrival
+	max  int
+}
+
+// get returns the reply for key, and reports whether the caller is
+// the first to ask and so must perform the request and call done.
+// It returns nil if key was used for a different request.
+func (rs *replies) get(key, request string) (*reply, bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rep := rs.m[key]; rep != nil {
+		if rep.request != request {
+			return nil, false
+		}
+		return rep, false
+	}
+	if rs.m == nil {
+		rs.m = make(map[string]*reply)
+	}
+	rep := &reply{request: request, ready: make(chan struct{})}
+	rs.m[key] = rep
+	rs.keys = append(rs.keys, key)
+	if len(rs.keys) > rs.max {
+		delete(rs.m, rs.keys[0]) // forget the oldest
+		rs.keys = rs.keys[1:]
+	}
+	return rep, true
+}
+
+// done marks the reply for key as ready.  A reply reporting a server
+// error is forgotten, so that the request may be retried.
+func (rs *replies) done(key string, rep *reply) {
+	if rep.status >= 500 {
+		rs.mu.Lock()
+		if rs.m[key] == rep {
+			delete(rs.m, key)
+			// Forget its arrival too, lest a retry's reply be
+			// forgotten early, in place of this one.
+			for i := len(rs.keys) - 1; i >= 0; i-- {
+				if rs.keys[i] == key {
+					rs.keys = append(rs.keys[:i], rs.keys[i+1:]...)
+					break
+				}
+			}
+		}
+		rs.mu.Unlock()
+	}
+	close(rep.ready)
+}
+
+// An errorBody is the reply to a request that failed.
+type errorBody struct {
+	Error string
+}
+
+// errorReply returns the status and reply for an error from the bank.
+func errorReply(err error) (int, interface{}) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, bank.ErrName), errors.Is(err, bank.ErrAmount):
+		status = http.StatusBadRequest
+	case errors.Is(err, bank.ErrNoAccount):
+		status = http.StatusNotFound
+	case errors.Is(err, bank.ErrExists):
+		status = http.StatusConflict
+	case errors.Is(err, bank.ErrInsufficient):
+		status = http.StatusUnprocessableEntity
+	default:
+		log.Print(err)
+	}
+	return status, errorBody{err.Error()}
+}
+
+func encode(status int, v interface{}) (int, []byte) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return http.StatusInternalServerError, []byte(`{"Error":"encoding reply"}`)
+	}
+	return status, append(data, '\n')
+}
+
+func write(w http.ResponseWriter, status int, data []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	status, data := encode(status, v)
+	write(w, status, data)
+}
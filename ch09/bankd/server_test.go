@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"gopl.io/ch09/bank4"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	b := bank.New()
+	ts := httptest.NewServer(newServer(b).routes())
+	t.Cleanup(func() {
+		ts.Close()
+		b.Close()
+	})
+	return ts
+}
+
+// do sends a request and returns the status and decoded reply.
+func do(t *testing.T, ts *httptest.Server, method, path, key, body string) (int, map[string]interface{}) {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("%s %s: Content-Type %q", method, path, ct)
+	}
+	var reply map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		t.Errorf("%s %s: decoding reply: %v", method, path, err)
+	}
+	return resp.StatusCode, reply
+}
+
+func TestAPI(t *testing.T) {
+	ts := newTestServer(t)
+	for _, test := range []struct {
+		method, path, body string
+		status             int
+	}{
+		{"POST", "/accounts", `{"account": "alice"}`, 201},
+		{"POST", "/accounts", `{"account": "bob"}`, 201},
+		{"POST", "/accounts", `{"account": "alice"}`, 409},
+		{"POST", "/accounts", `{"account": ""}`, 400},
+		{"POST", "/deposit", `{"account": "alice", "amount": 100}`, 200},
+		{"POST", "/deposit", `{"account": "carol", "amount": 100}`, 404},
+		{"POST", "/deposit", `{"account": "alice", "amount": -1}`, 400},
+		{"POST", "/deposit", `{"account": "alice", "amount": "lots"}`, 400},
+		{"POST", "/withdraw", `{"account": "alice", "amount": 30}`, 200},
+		{"POST", "/withdraw", `{"account": "alice", "amount": 71}`, 422},
+		{"POST", "/transfer", `{"from": "alice", "to": "bob", "amount": 50}`, 200},
+		{"POST", "/transfer", `{"from": "alice", "to": "bob", "amount": 50}`, 422},
+		{"GET", "/deposit", ``, 405},
+		{"POST", "/balance/alice", ``, 405},
+		{"GET", "/balance/carol", ``, 404},
+	} {
+		if status, reply := do(t, ts, test.method, test.path, "", test.body); status != test.status {
+			t.Errorf("%s %s %s: status %d (%v), want %d",
+				test.method, test.path, test.body, status, reply, test.status)
+		}
+	}
+
+	for account, want := range map[string]float64{"alice": 20, "bob": 50} {
+		status, reply := do(t, ts, "GET", "/balance/"+account, "", "")
+		if status != 200 || reply["Balance"] != want {
+			t.Errorf("GET /balance/%s: %d %v, want balance %v", account, status, reply, want)
+		}
+	}
+}
+
+func TestIdempotency(t *testing.T) {
+	ts := newTestServer(t)
+	do(t, ts, "POST", "/accounts", "", `{"account": "alice"}`)
+
+	// Many concurrent retries of one deposit deposit once.
+	var wg sync.WaitGroup
+	seqs := make(chan interface{}, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, reply := do(t, ts, "POST", "/deposit", "k1", `{"account": "alice", "amount": 10}`)
+			if status != 200 {
+				t.Errorf("deposit: status %d", status)
+			}
+			seqs <- reply["Seq"]
+		}()
+	}
+	wg.Wait()
+	close(seqs)
+	for seq := range seqs {
+		if seq != float64(2) {
+			t.Errorf("retried deposit has Seq %v, want 2", seq)
+		}
+	}
+	if _, reply := do(t, ts, "GET", "/balance/alice", "", ""); reply["Balance"] != float64(10) {
+		t.Errorf("balance after retried deposits = %v, want 10", reply["Balance"])
+	}
+
+	// Failures are replayed too.
+	for i := 0; i < 2; i++ {
+		if status, _ := do(t, ts, "POST", "/withdraw", "k2", `{"account": "alice", "amount": 11}`); status != 422 {
+			t.Errorf("withdraw: status %d, want 422", status)
+		}
+	}
+
+	// A key may not be reused for a different request.
+	if status, _ := do(t, ts, "POST", "/deposit", "k1", `{"account": "alice", "amount": 20}`); status != 422 {
+		t.Errorf("reused key: status %d, want 422", status)
+	}
+}
+
+func TestRepliesLimit(t *testing.T) {
+	rs := replies{max: 2}
+	for _, key := range []string{"a", "b", "c"} {
+		rep, first := rs.get(key, "req")
+		if !first {
+			t.Fatalf("get(%q) not first", key)
+		}
+		rs.done(key, rep)
+	}
+	if _, first := rs.get("a", "req"); !first {
+		t.Error("oldest key was not forgotten")
+	}
+	if _, first := rs.get("c", "req"); first {
+		t.Error("newest key was forgotten")
+	}
+}
+
+func TestRepliesServerError(t *testing.T) {
+	rs := replies{max: 2}
+	rep, _ := rs.get("a", "req")
+	rep.status = 500
+	rs.done("a", rep) // forgotten, so that it may be retried
+
+	// The retry's reply is remembered as long as any other.
+	rep, first := rs.get("a", "req")
+	if !first {
+		t.Fatal("reply with server error was not forgotten")
+	}
+	rs.done("a", rep)
+	rep, _ = rs.get("b", "req")
+	rs.done("b", rep)
+	if _, first := rs.get("a", "req"); first {
+		t.Error("retried key was forgotten too soon")
+	}
+	if len(rs.keys) != len(rs.m) {
+		t.Errorf("%d keys in order of arrival, %d replies", len(rs.keys), len(rs.m))
+	}
+}
@@ -0,0 +1,252 @@
+// Bankload generates load on a bankd server.
+//
+// It opens a number of accounts, deposits an initial balance in each,
+// and then makes random deposits, withdrawals and transfers among
+// them from many goroutines for a while.  Some requests are sent
+// twice with the same idempotency key, as a client would retry after
+// a timeout.  Finally it reports the throughput and latency of the
+// requests, and checks the balance of each account against a ledger
+// of the requests that succeeded, which a retry applied twice would
+// upset.
+//
+//	$ bankd &
+//	$ bankload -workers 16 -t 10s
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	server    = flag.String("url", "http://localhost:8000", "base `URL` of the bankd server")
+	accounts  = flag.Int("accounts", 10, "number of accounts")
+	initial   = flag.Int("initial", 1000, "initial balance of each account")
+	workers   = flag.Int("workers", 8, "number of concurrent clients")
+	duration  = flag.Duration("t", 5*time.Second, "how long to generate load")
+	retries   = flag.Float64("retry", 0.1, "fraction of requests to send twice")
+	transfers = flag.Float64("transfers", 0.5, "fraction of requests that are transfers; the rest are deposits and withdrawals")
+)
+
+// run is the unique prefix of this run's account names and keys.
+var run = fmt.Sprintf("load%d", time.Now().UnixNano())
+
+func account(i int) string { return fmt.Sprintf("%s-%d", run, i) }
+
+// A ledger records the balances that the accounts should have, given
+// the requests that succeeded.
+type ledger struct {
+	mu       sync.Mutex
+	balances []int
+	unknown  map[int]bool // accounts affected by requests of unknown outcome
+	mismatch int          // retries answered differently from the first send
+}
+
+// record records the outcome of a request for amount from and to
+// the given accounts, either of which may be -1, given the statuses
+// of its sends.
+func (l *ledger) record(from, to, amount int, statuses []int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch {
+	case len(statuses) == 0 || statuses[0] >= 500:
+		// The request may or may not have been applied.
+	case len(statuses) == 2 && statuses[0] != statuses[1]:
+		l.mismatch++
+	case statuses[0] == http.StatusOK:
+		if from >= 0 {
+			l.balances[from] -= amount
+		}
+		if to >= 0 {
+			l.balances[to] += amount
+		}
+		return
+	default:
+		return // refused, and not applied
+	}
+	for _, i := range []int{from, to} {
+		if i >= 0 {
+			l.unknown[i] = true
+		}
+	}
+}
+
+func main() {
+	flag.Parse()
+	log.SetPrefix("bankload: ")
+	log.SetFlags(0)
+	if *accounts < 1 {
+		log.Fatal("-accounts must be at least 1")
+	}
+	if *transfers > 0 && *accounts < 2 {
+		log.Fatal("transfers need -accounts of at least 2; use -transfers 0 for one account")
+	}
+
+	for i := 0; i < *accounts; i++ {
+		if status, err := post("/accounts", "", map[string]interface{}{"account": account(i)}); err != nil || status != http.StatusCreated {
+			log.Fatalf("opening account: %d %v", status, err)
+		}
+		if status, err := post("/deposit", "", map[string]interface{}{"account": account(i), "amount": *initial}); err != nil || status != http.StatusOK {
+			log.Fatalf("depositing: %d %v", status, err)
+		}
+	}
+	want := &ledger{balances: make([]int, *accounts), unknown: make(map[int]bool)}
+	for i := range want.balances {
+		want.balances[i] = *initial
+	}
+
+	type sample struct {
+		status  int
+		latency time.Duration
+	}
+	samples := make(chan sample)
+	deadline := time.Now().Add(*duration)
+	var wg sync.WaitGroup
+	for w := 0; w < *workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(int64(w)))
+			for n := 0; time.Now().Before(deadline); n++ {
+				key := fmt.Sprintf("%s-%d-%d", run, w, n)
+				amount := 1 + rng.Intn(*initial/10+1)
+				from, to := -1, -1 // the accounts debited and credited
+				var path string
+				var tx map[string]interface{}
+				switch r := rng.Float64(); {
+				case r < *transfers:
+					from = rng.Intn(*accounts)
+					to = (from + 1 + rng.Intn(*accounts-1)) % *accounts
+					path = "/transfer"
+					tx = map[string]interface{}{"from": account(from), "to": account(to), "amount": amount}
+				case r < (1+*transfers)/2:
+					to = rng.Intn(*accounts)
+					path = "/deposit"
+					tx = map[string]interface{}{"account": account(to), "amount": amount}
+				default:
+					from = rng.Intn(*accounts)
+					path = "/withdraw"
+					tx = map[string]interface{}{"account": account(from), "amount": amount}
+				}
+				sends := 1
+				if rng.Float64() < *retries {
+					sends = 2
+				}
+				var statuses []int
+				for i := 0; i < sends; i++ {
+					start := time.Now()
+					status, err := post(path, key, tx)
+					if err != nil {
+						log.Print(err)
+						continue
+					}
+					statuses = append(statuses, status)
+					samples <- sample{status, time.Since(start)}
+				}
+
+				want.record(from, to, amount, statuses)
+			}
+		}(w)
+	}
+	go func() {
+		wg.Wait()
+		close(samples)
+	}()
+
+	statuses := make(map[int]int)
+	var latencies []time.Duration
+	for s := range samples {
+		statuses[s.status]++
+		latencies = append(latencies, s.latency)
+	}
+	if len(latencies) == 0 {
+		log.Fatal("no requests succeeded")
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	percentile := func(p int) time.Duration { return latencies[(len(latencies)-1)*p/100] }
+
+	fmt.Printf("%d requests in %s, %.0f/s\n", len(latencies), *duration,
+		float64(len(latencies))/duration.Seconds())
+	for status, n := range statuses {
+		fmt.Printf("  %d %s: %d\n", status, http.StatusText(status), n)
+	}
+	fmt.Printf("latency p50 %s  p90 %s  p99 %s  max %s\n",
+		percentile(50), percentile(90), percentile(99), latencies[len(latencies)-1])
+
+	ok := true
+	if want.mismatch > 0 {
+		fmt.Printf("FAIL: %d retries answered differently from the first send\n", want.mismatch)
+		ok = false
+	}
+	checked := 0
+	for i := 0; i < *accounts; i++ {
+		if want.unknown[i] {
+			continue
+		}
+		balance, err := getBalance(account(i))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if balance != want.balances[i] {
+			fmt.Printf("FAIL: balance of %s is %d, want %d\n", account(i), balance, want.balances[i])
+			ok = false
+		}
+		checked++
+	}
+	if !ok {
+		os.Exit(1)
+	}
+	fmt.Printf("balances of %d accounts as expected", checked)
+	if n := *accounts - checked; n > 0 {
+		fmt.Printf(" (%d not checked: some of their requests had unknown outcomes)", n)
+	}
+	fmt.Println()
+}
+
+// post sends a POST request with the JSON encoding of v, and returns
+// the status.
+func post(path, key string, v interface{}) (int, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequest("POST", *server+path, bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+func getBalance(account string) (int, error) {
+	resp, err := http.Get(*server + "/balance/" + account)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("balance of %s: %s", account, resp.Status)
+	}
+	var reply struct{ Balance int }
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return 0, err
+	}
+	return reply.Balance, nil
+}
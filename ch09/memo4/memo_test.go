@@ -21,3 +21,8 @@ func TestConcurrent(t *testing.T) {
 	m := memo.New(httpGetBody)
 	memotest.Concurrent(t, m)
 }
+
+func Benchmark(b *testing.B) {
+	m := memo.New(memotest.Identity)
+	memotest.Benchmark(b, m)
+}
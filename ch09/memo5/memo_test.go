@@ -23,3 +23,9 @@ func TestConcurrent(t *testing.T) {
 	defer m.Close()
 	memotest.Concurrent(t, m)
 }
+
+func Benchmark(b *testing.B) {
+	m := memo.New(memotest.Identity)
+	defer m.Close()
+	memotest.Benchmark(b, m)
+}
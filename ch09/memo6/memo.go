@@ -0,0 +1,288 @@
+// Package memo provides a concurrency-safe non-blocking memoization
+// of a function, with bounds on the size and age of its cache.
+// Requests for different keys proceed in parallel.
+// Concurrent requests for the same key block until the first completes.
+// This implementation uses a monitor goroutine, like memo5.
+//
+// Unlike memo1 through memo5, which cache every result forever, a Memo
+// may expire results after a time, evict the least recently used
+// results when it holds too many, and decline to cache errors.
+//
+// Requests may also be cancelled (see exercise 9.3).  A call of the
+// function is itself cancelled only when every request waiting for it
+// has been cancelled, and its result is then not cached.  This is the
+// place for cancellation rather than memo4 and memo5, which are kept
+// as they appear in the book: a client of either that needs it can
+// use a Memo made by NewContext, whose zero Options cache like memo5
+// except that errors are not cached.
+package memo
+
+import (
+	"container/list"
+	"context"
+	"time"
+)
+
+// Func is the type of the function to memoize.
+type Func func(key string) (interface{}, error)
+
+// ContextFunc is the type of a cancellable function to memoize.
+type ContextFunc func(ctx context.Context, key string) (interface{}, error)
+
+// Options bound the cache of a Memo.  The zero value caches
+// successful results forever and does not cache errors.
+type Options struct {
+	// TTL is how long a successful result is cached.
+	// Zero means forever.
+	TTL time.Duration
+
+	// ErrTTL is how long an error is cached.  Zero means that
+	// an error is returned only to the requests that were waiting
+	// for it, and the next request calls the function again.
+	// A negative value means errors are cached like other results.
+	ErrTTL time.Duration
+
+	// MaxEntries is the maximum number of results cached, beyond
+	// which the least recently used is evicted.  Zero means no limit.
+	MaxEntries int
+}
+
+// Stats are counts of the outcomes of requests to a Memo.
+type Stats struct {
+	Hits          int // requests answered from the cache, or by a call in progress
+	Misses        int // requests that called the function
+	Evictions     int // results removed to respect MaxEntries
+	Expirations   int // results removed because they were too old
+	Cancellations int // calls cancelled because all their requests were
+}
+
+// A result is the result of calling a Func.
+type result struct {
+	value interface{}
+	err   error
+}
+
+type entry struct {
+	key     string
+	res     result
+	expires time.Time     // zero if never; set before ready is closed
+	ready   chan struct{} // closed when res is ready
+	elem    *list.Element // position in the recency list
+
+	// These fields are used only by the monitor goroutine.
+	waiters int                // requests waiting for the call
+	cancel  context.CancelFunc // cancels the call
+}
+
+// A request is a message requesting that the Func be applied to key.
+type request struct {
+	ctx      context.Context
+	key      string
+	response chan<- result // the client wants a single result
+}
+
+type Memo struct {
+	requests chan request
+	abandons chan *entry // entries whose request was cancelled
+	forgets  chan string
+	stats    chan chan Stats
+	done     chan struct{}
+}
+
+// New returns a memoization of f whose cache is bounded by opts.
+// Clients must subsequently call Close.
+func New(f Func, opts Options) *Memo {
+	return NewContext(func(_ context.Context, key string) (interface{}, error) {
+		return f(key)
+	}, opts)
+}
+
+// NewContext is like New, but for a function that can be cancelled.
+func NewContext(f ContextFunc, opts Options) *Memo {
+	memo := &Memo{
+		requests: make(chan request),
+		abandons: make(chan *entry),
+		forgets:  make(chan string),
+		stats:    make(chan chan Stats),
+		done:     make(chan struct{}),
+	}
+	go memo.server(f, opts)
+	return memo
+}
+
+func (memo *Memo) Get(key string) (interface{}, error) {
+	return memo.GetContext(context.Background(), key)
+}
+
+// GetContext is like Get, but returns ctx.Err() if ctx is cancelled
+// before the result is ready.
+func (memo *Memo) GetContext(ctx context.Context, key string) (interface{}, error) {
+	response := make(chan result)
+	memo.requests <- request{ctx, key, response}
+	res := <-response
+	return res.value, res.err
+}
+
+// Forget removes any cached result for key, so that the next request
+// for it calls the function again.  Requests already waiting for a
+// call in progress still receive its result.
+func (memo *Memo) Forget(key string) { memo.forgets <- key }
+
+// Stats returns the counts of outcomes so far.
+func (memo *Memo) Stats() Stats {
+	ch := make(chan Stats)
+	memo.stats <- ch
+	return <-ch
+}
+
+func (memo *Memo) Close() { close(memo.done) }
+
+// A cache is the state of the monitor goroutine.
+type cache struct {
+	entries map[string]*entry
+	recency *list.List // of *entry, most recently used first
+	stats   Stats
+}
+
+func (memo *Memo) server(f ContextFunc, opts Options) {
+	c := &cache{
+		entries: make(map[string]*entry),
+		recency: list.New(),
+	}
+
+	// Sweep out expired results periodically, so that they do
+	// not accumulate when their keys are not requested again.
+	var sweep <-chan time.Time
+	if period := sweepPeriod(opts); period > 0 {
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+		sweep = ticker.C
+	}
+
+	for {
+		select {
+		case req := <-memo.requests:
+			e := c.entries[req.key]
+			if e != nil && e.expired(time.Now()) {
+				c.remove(e)
+				c.stats.Expirations++
+				e = nil
+			}
+			if e == nil {
+				// This is the first request for this key.
+				c.stats.Misses++
+				ctx, cancel := context.WithCancel(context.Background())
+				e = &entry{key: req.key, ready: make(chan struct{}), cancel: cancel}
+				e.elem = c.recency.PushFront(e)
+				c.entries[req.key] = e
+				go e.call(ctx, f, req.key, opts) // call f(ctx, key)
+				if opts.MaxEntries > 0 && len(c.entries) > opts.MaxEntries {
+					c.remove(c.recency.Back().Value.(*entry))
+					c.stats.Evictions++
+				}
+			} else {
+				c.stats.Hits++
+				c.recency.MoveToFront(e.elem)
+			}
+			e.waiters++
+			go e.deliver(req.ctx, req.response, memo)
+
+		case e := <-memo.abandons:
+			e.waiters--
+			if e.waiters == 0 && !e.isReady() {
+				// No one wants the result: cancel the call,
+				// and forget it, so that it is not cached.
+				e.cancel()
+				if c.entries[e.key] == e {
+					c.remove(e)
+				}
+				c.stats.Cancellations++
+			}
+
+		case key := <-memo.forgets:
+			if e := c.entries[key]; e != nil {
+				c.remove(e)
+			}
+
+		case ch := <-memo.stats:
+			ch <- c.stats
+
+		case <-sweep:
+			now := time.Now()
+			for _, e := range c.entries {
+				if e.expired(now) {
+					c.remove(e)
+					c.stats.Expirations++
+				}
+			}
+
+		case <-memo.done:
+			return
+		}
+	}
+}
+
+// sweepPeriod returns how often to sweep the cache for expired
+// results, or zero if nothing ever expires.
+func sweepPeriod(opts Options) time.Duration {
+	period := opts.TTL
+	if opts.ErrTTL > 0 && (period == 0 || opts.ErrTTL < period) {
+		period = opts.ErrTTL
+	}
+	if opts.ErrTTL == 0 && (period == 0 || time.Minute < period) {
+		period = time.Minute // errors expire at once
+	}
+	return period
+}
+
+func (c *cache) remove(e *entry) {
+	delete(c.entries, e.key)
+	c.recency.Remove(e.elem)
+}
+
+func (e *entry) isReady() bool {
+	select {
+	case <-e.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// expired reports whether e holds a result that is too old to use.
+// A call in progress has not expired.
+func (e *entry) expired(now time.Time) bool {
+	return e.isReady() && !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+func (e *entry) call(ctx context.Context, f ContextFunc, key string, opts Options) {
+	defer e.cancel()
+	// Evaluate the function.
+	e.res.value, e.res.err = f(ctx, key)
+	// Determine how long the result may be used.
+	ttl := opts.TTL
+	if e.res.err != nil && opts.ErrTTL >= 0 {
+		ttl = opts.ErrTTL
+	}
+	if ttl > 0 || (e.res.err != nil && opts.ErrTTL == 0) || ctx.Err() != nil {
+		e.expires = time.Now().Add(ttl)
+	}
+	// Broadcast the ready condition.
+	close(e.ready)
+}
+
+func (e *entry) deliver(ctx context.Context, response chan<- result, memo *Memo) {
+	select {
+	case <-e.ready:
+		// Send the result to the client.
+		response <- e.res
+	case <-ctx.Done():
+		// Tell the monitor that this request no longer waits,
+		// then tell the client why.
+		select {
+		case memo.abandons <- e:
+		case <-memo.done:
+		}
+		response <- result{err: ctx.Err()}
+	}
+}
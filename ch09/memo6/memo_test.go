@@ -0,0 +1,186 @@
+package memo_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"gopl.io/ch09/memo6"
+	"gopl.io/ch09/memotest"
+)
+
+var httpGetBody = memotest.HTTPGetBody
+
+func Test(t *testing.T) {
+	m := memo.New(httpGetBody, memo.Options{})
+	defer m.Close()
+	memotest.Sequential(t, m)
+}
+
+func TestConcurrent(t *testing.T) {
+	m := memo.New(httpGetBody, memo.Options{MaxEntries: 2})
+	defer m.Close()
+	memotest.Concurrent(t, m)
+}
+
+// counter is a Func that counts its calls for each key, and fails
+// for keys beginning with "err".
+type counter struct {
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (c *counter) f(key string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.calls == nil {
+		c.calls = make(map[string]int)
+	}
+	c.calls[key]++
+	if len(key) >= 3 && key[:3] == "err" {
+		return nil, fmt.Errorf("%s failed", key)
+	}
+	return key, nil
+}
+
+func (c *counter) n(key string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls[key]
+}
+
+func TestTTL(t *testing.T) {
+	var c counter
+	m := memo.New(c.f, memo.Options{TTL: 50 * time.Millisecond})
+	defer m.Close()
+
+	m.Get("a")
+	m.Get("a")
+	if n := c.n("a"); n != 1 {
+		t.Errorf("before expiry, f called %d times, want 1", n)
+	}
+	time.Sleep(60 * time.Millisecond)
+	m.Get("a")
+	if n := c.n("a"); n != 2 {
+		t.Errorf("after expiry, f called %d times, want 2", n)
+	}
+	if got, want := m.Stats(), (memo.Stats{Hits: 1, Misses: 2, Expirations: 1}); got != want {
+		t.Errorf("Stats = %+v, want %+v", got, want)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var c counter
+	m := memo.New(c.f, memo.Options{})
+	defer m.Close()
+	for i := 0; i < 3; i++ {
+		if _, err := m.Get("err1"); err == nil {
+			t.Error("Get(err1) succeeded")
+		}
+	}
+	if n := c.n("err1"); n != 3 {
+		t.Errorf("errors not cached: f called %d times, want 3", n)
+	}
+
+	m2 := memo.New(c.f, memo.Options{ErrTTL: time.Hour})
+	defer m2.Close()
+	for i := 0; i < 3; i++ {
+		m2.Get("err2")
+	}
+	if n := c.n("err2"); n != 1 {
+		t.Errorf("errors cached: f called %d times, want 1", n)
+	}
+}
+
+func TestLRU(t *testing.T) {
+	var c counter
+	m := memo.New(c.f, memo.Options{MaxEntries: 2})
+	defer m.Close()
+	m.Get("a")
+	m.Get("b")
+	m.Get("a") // a is now more recently used than b
+	m.Get("c") // evicts b
+	m.Get("a")
+	m.Get("b")
+	if c.n("a") != 1 || c.n("b") != 2 || c.n("c") != 1 {
+		t.Errorf("calls a=%d b=%d c=%d, want 1 2 1", c.n("a"), c.n("b"), c.n("c"))
+	}
+	if got, want := m.Stats(), (memo.Stats{Hits: 2, Misses: 4, Evictions: 2}); got != want {
+		t.Errorf("Stats = %+v, want %+v", got, want)
+	}
+}
+
+func TestForget(t *testing.T) {
+	var c counter
+	m := memo.New(c.f, memo.Options{})
+	defer m.Close()
+	m.Get("a")
+	m.Forget("a")
+	m.Forget("never seen")
+	m.Get("a")
+	if n := c.n("a"); n != 2 {
+		t.Errorf("after Forget, f called %d times, want 2", n)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	// f blocks until it is cancelled or released.
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	cancelled := make(chan struct{}, 10)
+	f := func(ctx context.Context, key string) (interface{}, error) {
+		started <- struct{}{}
+		select {
+		case <-ctx.Done():
+			cancelled <- struct{}{}
+			return nil, ctx.Err()
+		case <-release:
+			return key, nil
+		}
+	}
+	m := memo.NewContext(f, memo.Options{})
+	defer m.Close()
+
+	// Two requests wait for the same call.
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	errs := make(chan error, 2)
+	go func() { _, err := m.GetContext(ctx1, "a"); errs <- err }()
+	<-started
+	go func() { _, err := m.GetContext(ctx2, "a"); errs <- err }()
+	for m.Stats().Hits == 0 {
+		time.Sleep(time.Millisecond) // wait for the second request
+	}
+
+	// Abandoning one request does not cancel the call.
+	cancel1()
+	if err := <-errs; err != context.Canceled {
+		t.Fatalf("first GetContext returned %v, want %v", err, context.Canceled)
+	}
+	select {
+	case <-cancelled:
+		t.Fatal("call cancelled while a request still waits for it")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// Abandoning the other does.
+	cancel2()
+	if err := <-errs; err != context.Canceled {
+		t.Fatalf("second GetContext returned %v, want %v", err, context.Canceled)
+	}
+	<-cancelled
+
+	// The cancelled result was not cached: the next request calls f again.
+	close(release)
+	if v, err := m.Get("a"); v != "a" || err != nil {
+		t.Errorf(`Get("a") = %v, %v, want "a", nil`, v, err)
+	}
+	if n := len(started); n != 1 {
+		t.Errorf("f called %d more times, want 1", n)
+	}
+	if got := m.Stats().Cancellations; got != 1 {
+		t.Errorf("Stats().Cancellations = %d, want 1", got)
+	}
+}
@@ -0,0 +1,144 @@
+// Package memo provides a concurrency-safe memoization of a function
+// with keys of any comparable type and results of any type.
+// Requests for different keys proceed in parallel.
+// Concurrent requests for the same key block until the first completes.
+//
+// This implementation uses mutexes, like memo4, but it divides the
+// cache among many shards, each with its own mutex, so that requests
+// for different keys seldom contend for the same lock.
+package memo
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Func is the type of the function to memoize.
+type Func[K comparable, V any] func(key K) (V, error)
+
+type entry[V any] struct {
+	value V
+	err   error
+	ready chan struct{} // closed when value and err are ready
+}
+
+// A shard is a part of the cache, holding the keys whose hashes
+// select it.
+type shard[K comparable, V any] struct {
+	mu    sync.Mutex // guards cache
+	cache map[K]*entry[V]
+}
+
+type Memo[K comparable, V any] struct {
+	f      Func[K, V]
+	hash   func(K) uint64
+	shards []shard[K, V] // len is a power of two
+}
+
+// New returns a memoization of f.  The cache is divided among shards
+// by hash, which must return equal values for equal keys.  If hash is
+// nil, the cache has a single shard, and all requests contend for
+// the same lock, as in memo4.
+func New[K comparable, V any](f Func[K, V], hash func(K) uint64) *Memo[K, V] {
+	n := 1
+	if hash != nil {
+		for n < 4*runtime.GOMAXPROCS(0) {
+			n *= 2
+		}
+	}
+	memo := &Memo[K, V]{f: f, hash: hash, shards: make([]shard[K, V], n)}
+	for i := range memo.shards {
+		memo.shards[i].cache = make(map[K]*entry[V])
+	}
+	return memo
+}
+
+func (memo *Memo[K, V]) Get(key K) (V, error) {
+	e := memo.get(key)
+	return e.value, e.err
+}
+
+// TryGet is like Get, but does not keep an error in the cache: once
+// the requests waiting for a failed call have its result, the next
+// request for key calls the function again.
+func (memo *Memo[K, V]) TryGet(key K) (V, error) {
+	e := memo.get(key)
+	if e.err != nil {
+		// Remove the failed entry, unless another request has
+		// already done so and a new call has taken its place.
+		s := memo.shard(key)
+		s.mu.Lock()
+		if s.cache[key] == e {
+			delete(s.cache, key)
+		}
+		s.mu.Unlock()
+	}
+	return e.value, e.err
+}
+
+// get returns the entry for key once it is ready.
+func (memo *Memo[K, V]) get(key K) *entry[V] {
+	s := memo.shard(key)
+	s.mu.Lock()
+	e := s.cache[key]
+	if e == nil {
+		// This is the first request for this key.
+		// This goroutine becomes responsible for computing
+		// the value and broadcasting the ready condition.
+		e = &entry[V]{ready: make(chan struct{})}
+		s.cache[key] = e
+		s.mu.Unlock()
+
+		e.value, e.err = memo.f(key)
+
+		close(e.ready) // broadcast ready condition
+	} else {
+		// This is a repeat request for this key.
+		s.mu.Unlock()
+
+		<-e.ready // wait for ready condition
+	}
+	return e
+}
+
+// Forget removes any cached result for key, so that the next request
+// for it calls the function again.  Requests already waiting for a
+// call in progress still receive its result.
+func (memo *Memo[K, V]) Forget(key K) {
+	s := memo.shard(key)
+	s.mu.Lock()
+	delete(s.cache, key)
+	s.mu.Unlock()
+}
+
+// shard returns the shard that holds key.
+func (memo *Memo[K, V]) shard(key K) *shard[K, V] {
+	if memo.hash == nil {
+		return &memo.shards[0]
+	}
+	return &memo.shards[memo.hash(key)&uint64(len(memo.shards)-1)]
+}
+
+// String is a hash function for string keys.
+func String(key string) uint64 {
+	// FNV-1a, which is cheap for the short keys typical of a cache.
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+	return h
+}
+
+// Int is a hash function for integer keys.
+func Int(key int) uint64 {
+	// The finalizer of MurmurHash3, which mixes every bit of the key
+	// into the low bits that select a shard.
+	x := uint64(key)
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
@@ -0,0 +1,121 @@
+package memo_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"gopl.io/ch09/memo7"
+	"gopl.io/ch09/memotest"
+)
+
+var httpGetBody = memotest.HTTPGetBody
+
+func Test(t *testing.T) {
+	m := memo.New(httpGetBody, memo.String)
+	memotest.Sequential(t, m)
+}
+
+func TestConcurrent(t *testing.T) {
+	m := memo.New(httpGetBody, memo.String)
+	memotest.Concurrent(t, m)
+}
+
+// TestTyped checks that results of any type are returned without
+// conversion, and that concurrent requests for a key call f once.
+func TestTyped(t *testing.T) {
+	var mu sync.Mutex
+	calls := make(map[int]int)
+	square := func(x int) (string, error) {
+		mu.Lock()
+		calls[x]++
+		mu.Unlock()
+		if x < 0 {
+			return "", fmt.Errorf("negative: %d", x)
+		}
+		return fmt.Sprint(x * x), nil
+	}
+	for _, hash := range []func(int) uint64{nil, memo.Int} {
+		for k := range calls {
+			delete(calls, k)
+		}
+		m := memo.New(square, hash)
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			for x := -5; x < 100; x++ {
+				wg.Add(1)
+				go func(x int) {
+					defer wg.Done()
+					s, err := m.Get(x)
+					if x < 0 {
+						if err == nil {
+							t.Errorf("Get(%d) succeeded", x)
+						}
+					} else if want := fmt.Sprint(x * x); s != want || err != nil {
+						t.Errorf("Get(%d) = %q, %v, want %q", x, s, err, want)
+					}
+				}(x)
+			}
+		}
+		wg.Wait()
+		for x, n := range calls {
+			if n != 1 {
+				t.Errorf("f(%d) called %d times, want 1", x, n)
+			}
+		}
+
+		// A forgotten result is computed again.
+		m.Forget(7)
+		if s, err := m.Get(7); s != "49" || err != nil || calls[7] != 2 {
+			t.Errorf("after Forget, Get(7) = %q, %v, with %d calls", s, err, calls[7])
+		}
+	}
+}
+
+// TestTryGet checks that TryGet does not cache errors, and that the
+// requests for a failed call do not remove the result of a later one.
+func TestTryGet(t *testing.T) {
+	var mu sync.Mutex
+	calls := make(map[int]int)
+	f := func(x int) (int, error) { // fails the first time for each x
+		mu.Lock()
+		calls[x]++
+		n := calls[x]
+		mu.Unlock()
+		if n == 1 {
+			return 0, fmt.Errorf("call %d failed", n)
+		}
+		return x * x, nil
+	}
+	m := memo.New(f, memo.Int)
+	var wg sync.WaitGroup
+	for i := 0; i < 1000; i++ {
+		wg.Add(1)
+		go func(x int) {
+			defer wg.Done()
+			if v, err := m.TryGet(x); err == nil && v != x*x {
+				t.Errorf("TryGet(%d) = %d", x, v)
+			}
+		}(i % 10)
+	}
+	wg.Wait()
+	for x := 0; x < 10; x++ {
+		if v, err := m.TryGet(x); v != x*x || err != nil {
+			t.Errorf("TryGet(%d) = %d, %v, want %d", x, v, err, x*x)
+		}
+		// One failed call, and one whose result is kept.
+		if calls[x] != 2 {
+			t.Errorf("f(%d) called %d times, want 2", x, calls[x])
+		}
+	}
+}
+
+func Benchmark(b *testing.B) {
+	m := memo.New(memotest.Identity, memo.String)
+	memotest.Benchmark(b, m)
+}
+
+func BenchmarkUnsharded(b *testing.B) {
+	m := memo.New(memotest.Identity, nil)
+	memotest.Benchmark(b, m)
+}
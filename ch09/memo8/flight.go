@@ -0,0 +1,41 @@
+package memo
+
+import "sync"
+
+// A flight collapses concurrent calls for the same key into one.
+// Unlike a memo, it forgets each result as soon as it is delivered.
+type flight struct {
+	mu    sync.Mutex // guards calls
+	calls map[string]*call
+}
+
+type call struct {
+	value []byte
+	err   error
+	done  chan struct{} // closed when value and err are ready
+}
+
+// do calls f, unless a call for key is already in progress, in which
+// case it waits for that call's result instead.
+func (fl *flight) do(key string, f func() ([]byte, error)) ([]byte, error) {
+	fl.mu.Lock()
+	if c := fl.calls[key]; c != nil {
+		fl.mu.Unlock()
+		<-c.done
+		return c.value, c.err
+	}
+	if fl.calls == nil {
+		fl.calls = make(map[string]*call)
+	}
+	c := &call{done: make(chan struct{})}
+	fl.calls[key] = c
+	fl.mu.Unlock()
+
+	c.value, c.err = f()
+	close(c.done)
+
+	fl.mu.Lock()
+	delete(fl.calls, key)
+	fl.mu.Unlock()
+	return c.value, c.err
+}
@@ -0,0 +1,165 @@
+// Package memo provides a memoization of a function that is shared
+// among a group of processes, or peers, in the style of groupcache.
+//
+// Each key is owned by one peer, chosen by consistent hashing.  A peer
+// calls the function only for the keys it owns, and caches the
+// results, using gopl.io/ch09/memo7; for other keys it asks the owner
+// over HTTP.  So, however many peers request a key, the function is
+// called for it only once.  Concurrent requests for the same key,
+// whether local or from other peers, are collapsed into one.
+//
+// Errors are not cached: the next request for a key whose call
+// failed calls the function again.  If the owner of a key cannot be
+// reached, the requesting peer calls the function itself, but does
+// not cache the result.
+package memo
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	memo7 "gopl.io/ch09/memo7"
+)
+
+// Func is the type of the function to memoize.  Its results are bytes
+// so that they may be sent between peers.
+type Func func(key string) ([]byte, error)
+
+// BasePath is the path at which a Memo expects its peers to serve it.
+const BasePath = "/_memo/"
+
+type Memo struct {
+	// Client is the client used to ask peers for results.
+	// If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	self   string // base URL of this peer
+	f      Func
+	local  *memo7.Memo[string, []byte] // results of keys owned by self
+	remote flight                      // requests to other peers
+	served flight                      // calls for other peers' keys
+
+	mu   sync.RWMutex // guards ring
+	ring *ring
+}
+
+// New returns a memoization of f for the peer whose base URL is self.
+// The peer must serve the Memo at BasePath; see ServeHTTP.
+// Until SetPeers is called, the peer owns every key.
+func New(self string, f Func) *Memo {
+	return &Memo{
+		self:  self,
+		f:     f,
+		local: memo7.New(memo7.Func[string, []byte](f), memo7.String),
+		ring:  newRing(nil),
+	}
+}
+
+// SetPeers sets the base URLs of the group's peers, which should
+// include this one.  Every peer should be given the same set.
+func (memo *Memo) SetPeers(peers ...string) {
+	r := newRing(peers)
+	memo.mu.Lock()
+	memo.ring = r
+	memo.mu.Unlock()
+}
+
+// owner returns the base URL of the peer that owns key.
+func (memo *Memo) owner(key string) string {
+	memo.mu.RLock()
+	defer memo.mu.RUnlock()
+	if peer := memo.ring.owner(key); peer != "" {
+		return peer
+	}
+	return memo.self
+}
+
+func (memo *Memo) Get(key string) ([]byte, error) {
+	peer := memo.owner(key)
+	if peer == memo.self {
+		return memo.getLocal(key)
+	}
+	return memo.remote.do(key, func() ([]byte, error) {
+		value, err := memo.fetch(peer, key)
+		if _, ok := err.(*peerError); ok {
+			// The owner is unavailable; do the work ourselves.
+			return memo.f(key)
+		}
+		return value, err
+	})
+}
+
+// getLocal returns the result for key, which this peer owns, from
+// its cache, but does not keep an error there.
+func (memo *Memo) getLocal(key string) ([]byte, error) {
+	return memo.local.TryGet(key)
+}
+
+// A peerError reports a failure to get a result from a peer,
+// as opposed to an error returned by the function.
+type peerError struct {
+	peer string
+	err  error
+}
+
+func (e *peerError) Error() string { return fmt.Sprintf("peer %s: %v", e.peer, e.err) }
+
+// fetch asks peer for the result for key.
+func (memo *Memo) fetch(peer, key string) ([]byte, error) {
+	client := memo.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Get(peer + BasePath + "?key=" + url.QueryEscape(key))
+	if err != nil {
+		return nil, &peerError{peer, err}
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &peerError{peer, err}
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return body, nil
+	case http.StatusUnprocessableEntity:
+		// The function failed; http.Error added a newline.
+		return nil, errors.New(strings.TrimSuffix(string(body), "\n"))
+	default:
+		return nil, &peerError{peer, fmt.Errorf("%s", resp.Status)}
+	}
+}
+
+// ServeHTTP serves requests from other peers for the results of
+// keys.  It calls the function for any key it is asked for, whether
+// or not this peer owns it, so that peers whose sets of peers differ
+// briefly do not forward requests in circles, but it caches only the
+// results of the keys it owns.
+//
+// A result is sent with status 200, and an error from the function
+// as text with status 422.
+func (memo *Memo) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	key := req.URL.Query().Get("key")
+	if key == "" {
+		http.Error(w, "missing key", http.StatusBadRequest)
+		return
+	}
+	var value []byte
+	var err error
+	if memo.owner(key) == memo.self {
+		value, err = memo.getLocal(key)
+	} else {
+		value, err = memo.served.do(key, func() ([]byte, error) { return memo.f(key) })
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(value)
+}
@@ -0,0 +1,152 @@
+package memo_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"gopl.io/ch09/memo8"
+)
+
+// counter is a Func that counts its calls for each key, and fails
+// for keys beginning with "err".
+type counter struct {
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (c *counter) f(key string) ([]byte, error) {
+	c.mu.Lock()
+	c.calls[key]++
+	c.mu.Unlock()
+	if strings.HasPrefix(key, "err") {
+		return nil, fmt.Errorf("%s failed", key)
+	}
+	return []byte("value of " + key), nil
+}
+
+// group starts n peers that share c.f.
+func group(t *testing.T, n int, c *counter) ([]*memo.Memo, []*httptest.Server) {
+	c.calls = make(map[string]int)
+	var memos []*memo.Memo
+	var servers []*httptest.Server
+	var urls []string
+	for i := 0; i < n; i++ {
+		mux := http.NewServeMux()
+		srv := httptest.NewServer(mux)
+		t.Cleanup(srv.Close)
+		m := memo.New(srv.URL, c.f)
+		mux.Handle(memo.BasePath, m)
+		memos = append(memos, m)
+		servers = append(servers, srv)
+		urls = append(urls, srv.URL)
+	}
+	for _, m := range memos {
+		m.SetPeers(urls...)
+	}
+	return memos, servers
+}
+
+func TestGroup(t *testing.T) {
+	var c counter
+	memos, _ := group(t, 3, &c)
+
+	// Every peer requests every key several times at once.
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		for _, m := range memos {
+			for k := 0; k < 30; k++ {
+				wg.Add(1)
+				go func(m *memo.Memo, key string) {
+					defer wg.Done()
+					value, err := m.Get(key)
+					if want := "value of " + key; string(value) != want || err != nil {
+						t.Errorf("Get(%q) = %q, %v, want %q", key, value, err, want)
+					}
+				}(m, fmt.Sprintf("key%d", k))
+			}
+		}
+	}
+	wg.Wait()
+
+	// The function was called once per key, in all.
+	if len(c.calls) != 30 {
+		t.Errorf("function called for %d keys, want 30", len(c.calls))
+	}
+	for key, n := range c.calls {
+		if n != 1 {
+			t.Errorf("function called %d times for %s, want 1", n, key)
+		}
+	}
+}
+
+func TestError(t *testing.T) {
+	var c counter
+	memos, _ := group(t, 2, &c)
+	for _, m := range memos {
+		for k := 0; k < 10; k++ {
+			key := fmt.Sprintf("err%d", k)
+			_, err := m.Get(key)
+			if want := key + " failed"; err == nil || err.Error() != want {
+				t.Errorf("Get(%q) returned error %v, want %q", key, err, want)
+			}
+		}
+	}
+	// Errors are not cached, so each peer's request called the
+	// function again.
+	for key, n := range c.calls {
+		if n != len(memos) {
+			t.Errorf("function called %d times for %s, want %d", n, key, len(memos))
+		}
+	}
+}
+
+func TestServeUnowned(t *testing.T) {
+	var c counter
+	_, servers := group(t, 2, &c)
+
+	// A peer asked directly for keys, as one with a different set
+	// of peers might, caches only the results of those it owns.
+	for i := 0; i < 2; i++ {
+		for k := 0; k < 20; k++ {
+			resp, err := http.Get(servers[0].URL + memo.BasePath + "?key=key" + fmt.Sprint(k))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+		}
+	}
+	owned := 0
+	for key, n := range c.calls {
+		switch n {
+		case 1:
+			owned++
+		case 2:
+		default:
+			t.Errorf("function called %d times for %s, want 1 or 2", n, key)
+		}
+	}
+	if owned == 0 || owned == 20 {
+		t.Errorf("peer cached %d of 20 keys, want some but not all", owned)
+	}
+}
+
+func TestPeerDown(t *testing.T) {
+	var c counter
+	memos, servers := group(t, 2, &c)
+	servers[1].Close()
+
+	// Keys owned by the missing peer are computed by the other.
+	for k := 0; k < 20; k++ {
+		key := fmt.Sprintf("key%d", k)
+		if value, err := memos[0].Get(key); string(value) != "value of "+key || err != nil {
+			t.Errorf("Get(%q) = %q, %v", key, value, err)
+		}
+	}
+	if len(c.calls) != 20 {
+		t.Errorf("function called for %d keys, want 20", len(c.calls))
+	}
+}
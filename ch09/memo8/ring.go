@@ -0,0 +1,54 @@
+package memo
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// replicas is the number of points on the ring for each peer.
+// Many points per peer spread the keys evenly among peers.
+const replicas = 50
+
+// A ring assigns keys to peers by consistent hashing: each peer owns
+// the keys whose hashes fall just before its points on a circle, so
+// adding or removing a peer moves only the keys of that peer.
+type ring struct {
+	points []uint32          // sorted
+	owners map[uint32]string // peer at each point
+}
+
+func newRing(peers []string) *ring {
+	r := &ring{owners: make(map[uint32]string)}
+	for _, peer := range peers {
+		for i := 0; i < replicas; i++ {
+			p := crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + peer))
+			if prev, ok := r.owners[p]; ok {
+				// Two peers have the same point.  Give it to the
+				// lesser, so that every peer agrees on its owner
+				// whatever the order of its list of peers.
+				if peer < prev {
+					r.owners[p] = peer
+				}
+				continue
+			}
+			r.points = append(r.points, p)
+			r.owners[p] = peer
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+	return r
+}
+
+// owner returns the peer that owns key, or "" if there are no peers.
+func (r *ring) owner(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	h := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0 // wrap around the circle
+	}
+	return r.owners[r.points[i]]
+}
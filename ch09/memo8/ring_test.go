@@ -0,0 +1,65 @@
+package memo
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRing(t *testing.T) {
+	peers := []string{"http://a", "http://b", "http://c"}
+	r := newRing(peers)
+
+	// Keys are spread among all peers.
+	const n = 3000
+	owners := make(map[string]string)
+	count := make(map[string]int)
+	for i := 0; i < n; i++ {
+		key := fmt.Sprintf("key%d", i)
+		owners[key] = r.owner(key)
+		count[owners[key]]++
+	}
+	for _, peer := range peers {
+		if count[peer] < n/6 {
+			t.Errorf("peer %s owns %d of %d keys", peer, count[peer], n)
+		}
+	}
+
+	// Adding a peer moves keys only to the new peer.
+	r = newRing(append(peers, "http://d"))
+	moved := 0
+	for key, old := range owners {
+		if owner := r.owner(key); owner != old {
+			if owner != "http://d" {
+				t.Fatalf("key %s moved from %s to %s", key, old, owner)
+			}
+			moved++
+		}
+	}
+	if moved == 0 || moved > n/2 {
+		t.Errorf("adding a fourth peer moved %d of %d keys", moved, n)
+	}
+
+	if owner := newRing(nil).owner("key"); owner != "" {
+		t.Errorf("empty ring: owner = %q", owner)
+	}
+}
+
+// TestRingCollision checks that peers agree on the owner of a point
+// that two peers share, whatever the order of their lists of peers.
+func TestRingCollision(t *testing.T) {
+	// These have a point in common, 1372318963.
+	a, b := "http://10.0.32.9:8080", "http://10.0.60.20:8080"
+	ab, ba := newRing([]string{a, b}), newRing([]string{b, a})
+	if len(ab.points) != 2*replicas-1 {
+		t.Fatalf("%d points, want %d", len(ab.points), 2*replicas-1)
+	}
+	if owner := ab.owners[1372318963]; owner != a {
+		t.Errorf("shared point owned by %s, want %s", owner, a)
+	}
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("key%d", i)
+		if ab.owner(key) != ba.owner(key) {
+			t.Fatalf("rings disagree on the owner of %s", key)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math/rand"
 	"net/http"
 	"sync"
 	"testing"
@@ -102,3 +103,25 @@ func Concurrent(t *testing.T, m M) {
 	n.Wait()
 	//!-conc
 }
+
+// Identity is a Func whose results cost nothing to compute, for
+// measuring the overhead of a memo itself.
+func Identity(key string) (interface{}, error) { return key, nil }
+
+// Benchmark measures the cost of Get from many goroutines at once,
+// for keys drawn from a small set whose results are soon all cached.
+// m should be a memoization of Identity.
+func Benchmark(b *testing.B, m M) {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := rand.Intn(len(keys))
+		for pb.Next() {
+			m.Get(keys[i%len(keys)])
+			i++
+		}
+	})
+}